@@ -196,6 +196,8 @@ func TestDo_globalConfiguration(t *testing.T) {
 	config.ForwardingTimeouts = &configuration.ForwardingTimeouts{
 		DialTimeout:           flaeg.Duration(666 * time.Second),
 		ResponseHeaderTimeout: flaeg.Duration(666 * time.Second),
+		UpgradeIdleTimeout:    flaeg.Duration(666 * time.Second),
+		UpgradeTimeout:        flaeg.Duration(666 * time.Second),
 	}
 	config.Docker = &docker.Provider{
 		BaseProvider: provider.BaseProvider{
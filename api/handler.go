@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/containous/mux"
+	"github.com/containous/traefik/healthcheck"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/middlewares"
 	"github.com/containous/traefik/safe"
@@ -22,6 +23,8 @@ type Handler struct {
 	Statistics            *types.Statistics          `description:"Enable more detailed statistics" export:"true"`
 	Stats                 *thoas_stats.Stats         `json:"-"`
 	StatsRecorder         *middlewares.StatsRecorder `json:"-"`
+	OSIOCache             *OSIOCacheHandler          `json:"-"`
+	HealthCheck           *healthcheck.HealthCheck   `json:"-"`
 }
 
 var (
@@ -48,6 +51,13 @@ func (p Handler) AddRoutes(router *mux.Router) {
 	router.Methods(http.MethodGet).Path("/api/providers/{provider}/frontends/{frontend}/routes").HandlerFunc(p.getRoutesHandler)
 	router.Methods(http.MethodGet).Path("/api/providers/{provider}/frontends/{frontend}/routes/{route}").HandlerFunc(p.getRouteHandler)
 
+	if p.OSIOCache != nil {
+		p.OSIOCache.AddRoutes(router)
+	}
+	if p.HealthCheck != nil {
+		router.Methods(http.MethodGet).Path("/api/healthcheck").HandlerFunc(p.getHealthCheckHandler)
+	}
+
 	// health route
 	router.Methods(http.MethodGet).Path("/health").HandlerFunc(p.getHealthHandler)
 
@@ -75,6 +85,14 @@ func (p Handler) getConfigHandler(response http.ResponseWriter, request *http.Re
 	}
 }
 
+// getHealthCheckHandler returns the status of the servers of the backends with an active health check.
+func (p Handler) getHealthCheckHandler(response http.ResponseWriter, request *http.Request) {
+	err := templatesRenderer.JSON(response, http.StatusOK, p.HealthCheck.Status())
+	if err != nil {
+		log.Error(err)
+	}
+}
+
 func (p Handler) getProviderHandler(response http.ResponseWriter, request *http.Request) {
 	providerID := getProviderIDFromVars(mux.Vars(request))
 
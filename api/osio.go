@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/mux"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/middlewares/osio"
+)
+
+const broadcastParam = "broadcast"
+
+// OSIOCache is the OSIO middleware cache administered by the OSIOCacheHandler.
+type OSIOCache interface {
+	CacheEntries() []osio.CacheEntry
+	EvictCache(filter osio.CacheFilter) int
+	FlushCache() int
+}
+
+// OSIOCacheHandler exposes routes to inspect and evict the OSIO middleware cache entries.
+// All routes require the admin token as a bearer token.
+type OSIOCacheHandler struct {
+	Cache OSIOCache
+	Token string
+	// Peers are the admin API URLs of the other replicas. Host names are resolved to all
+	// their addresses, so a headless service name reaches every replica.
+	Peers []string
+
+	client     *http.Client
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	// tlsConfig is the base TLS configuration of the https peers, verified against their host names
+	tlsConfig    *tls.Config
+	tlsClientsMu sync.Mutex
+	tlsClients   map[string]*http.Client
+}
+
+// osioCachePeer is a replica reached at one of the resolved addresses of a peer.
+type osioCachePeer struct {
+	url        *url.URL
+	host       string
+	serverName string
+}
+
+// osioCacheResult is the response of the eviction routes.
+type osioCacheResult struct {
+	Evicted int                        `json:"evicted"`
+	Peers   map[string]osioCacheResult `json:"peers,omitempty"`
+	Error   string                     `json:"error,omitempty"`
+}
+
+// NewOSIOCacheHandler creates an OSIOCacheHandler. peers is a comma separated list of admin API URLs.
+func NewOSIOCacheHandler(cache OSIOCache, token string, peers string) *OSIOCacheHandler {
+	h := &OSIOCacheHandler{
+		Cache:      cache,
+		Token:      token,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		lookupHost: net.DefaultResolver.LookupHost,
+		tlsClients: make(map[string]*http.Client),
+	}
+	for _, peer := range strings.Split(peers, ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			h.Peers = append(h.Peers, peer)
+		}
+	}
+	return h
+}
+
+// AddRoutes add OSIO cache routes on a router
+func (h *OSIOCacheHandler) AddRoutes(router *mux.Router) {
+	if h.Token == "" {
+		log.Warn("OSIO cache admin API disabled: no admin token configured")
+		return
+	}
+	router.Methods(http.MethodGet).Path("/api/osio/cache").HandlerFunc(h.authenticated(h.getEntriesHandler))
+	router.Methods(http.MethodDelete).Path("/api/osio/cache").HandlerFunc(h.authenticated(h.evictHandler))
+	router.Methods(http.MethodDelete).Path("/api/osio/cache/all").HandlerFunc(h.authenticated(h.flushHandler))
+}
+
+func (h *OSIOCacheHandler) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) != 1 {
+			http.Error(response, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next(response, request)
+	}
+}
+
+func (h *OSIOCacheHandler) getEntriesHandler(response http.ResponseWriter, request *http.Request) {
+	err := templatesRenderer.JSON(response, http.StatusOK, h.Cache.CacheEntries())
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h *OSIOCacheHandler) evictHandler(response http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	filter := osio.CacheFilter{
+		User:      query.Get("user"),
+		Namespace: query.Get("namespace"),
+		Cluster:   query.Get("cluster"),
+	}
+	if filter.IsEmpty() {
+		http.Error(response, "at least one of user, namespace or cluster is required", http.StatusBadRequest)
+		return
+	}
+	evicted := h.Cache.EvictCache(filter)
+	log.Infof("Evicted %d OSIO cache entries matching %+v", evicted, filter)
+	h.writeResult(response, request, evicted)
+}
+
+func (h *OSIOCacheHandler) flushHandler(response http.ResponseWriter, request *http.Request) {
+	evicted := h.Cache.FlushCache()
+	log.Infof("Flushed %d OSIO cache entries", evicted)
+	h.writeResult(response, request, evicted)
+}
+
+func (h *OSIOCacheHandler) writeResult(response http.ResponseWriter, request *http.Request, evicted int) {
+	result := osioCacheResult{Evicted: evicted}
+	if request.URL.Query().Get(broadcastParam) == "true" {
+		result.Peers = h.broadcast(request)
+	}
+	err := templatesRenderer.JSON(response, http.StatusOK, result)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// broadcast sends the request to every peer replica, without the broadcast parameter so that it is not sent again.
+func (h *OSIOCacheHandler) broadcast(request *http.Request) map[string]osioCacheResult {
+	query := request.URL.Query()
+	query.Del(broadcastParam)
+
+	results := make(map[string]osioCacheResult)
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	for _, peer := range h.resolvePeers(request.Context()) {
+		wg.Add(1)
+		go func(peer osioCachePeer) {
+			defer wg.Done()
+			peerURL := *peer.url
+			peerURL.Path = strings.TrimSuffix(peerURL.Path, "/") + request.URL.Path
+			peerURL.RawQuery = query.Encode()
+			result := h.sendToPeer(request, peer, peerURL.String())
+			mux.Lock()
+			results[peer.url.Host] = result
+			mux.Unlock()
+		}(peer)
+	}
+	wg.Wait()
+	return results
+}
+
+func (h *OSIOCacheHandler) sendToPeer(request *http.Request, peer osioCachePeer, peerURL string) osioCacheResult {
+	peerReq, err := http.NewRequest(request.Method, peerURL, nil)
+	if err != nil {
+		return osioCacheResult{Error: err.Error()}
+	}
+	peerReq.Host = peer.host
+	peerReq.Header.Set("Authorization", "Bearer "+h.Token)
+	resp, err := h.peerClient(peer).Do(peerReq.WithContext(request.Context()))
+	if err != nil {
+		log.Errorf("Error broadcasting OSIO cache eviction to %s: %v", peerURL, err)
+		return osioCacheResult{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return osioCacheResult{Error: fmt.Sprintf("unexpected status %d", resp.StatusCode)}
+	}
+	var result osioCacheResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return osioCacheResult{Error: err.Error()}
+	}
+	return result
+}
+
+// resolvePeers resolves the peers to one URL per address, keeping their host for the Host header
+// and the verification of their certificate.
+func (h *OSIOCacheHandler) resolvePeers(ctx context.Context) []osioCachePeer {
+	var peers []osioCachePeer
+	for _, peer := range h.Peers {
+		peerURL, err := url.Parse(peer)
+		if err != nil {
+			log.Errorf("Invalid OSIO cache peer %q: %v", peer, err)
+			continue
+		}
+		addrs, err := h.lookupHost(ctx, peerURL.Hostname())
+		if err != nil {
+			log.Errorf("Unable to resolve OSIO cache peer %q: %v", peer, err)
+			continue
+		}
+		for _, addr := range addrs {
+			u := *peerURL
+			u.Host = addr
+			if port := peerURL.Port(); port != "" {
+				u.Host = net.JoinHostPort(addr, port)
+			} else if strings.Contains(addr, ":") {
+				u.Host = "[" + addr + "]"
+			}
+			peers = append(peers, osioCachePeer{url: &u, host: peerURL.Host, serverName: peerURL.Hostname()})
+		}
+	}
+	return peers
+}
+
+// peerClient returns the client of the peer: an https peer is reached at its address, so its
+// certificate is verified against its host name by a client per host name.
+func (h *OSIOCacheHandler) peerClient(peer osioCachePeer) *http.Client {
+	if peer.url.Scheme != "https" {
+		return h.client
+	}
+	h.tlsClientsMu.Lock()
+	defer h.tlsClientsMu.Unlock()
+	if client, ok := h.tlsClients[peer.serverName]; ok {
+		return client
+	}
+	config := &tls.Config{}
+	if h.tlsConfig != nil {
+		config = h.tlsConfig.Clone()
+	}
+	config.ServerName = peer.serverName
+	client := &http.Client{
+		Timeout:   h.client.Timeout,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: config},
+	}
+	h.tlsClients[peer.serverName] = client
+	return client
+}
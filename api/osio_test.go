@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/containous/mux"
+	"github.com/containous/traefik/middlewares/osio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeOSIOCache struct {
+	entries []osio.CacheEntry
+	filter  osio.CacheFilter
+	flushed bool
+}
+
+func (c *fakeOSIOCache) CacheEntries() []osio.CacheEntry {
+	return c.entries
+}
+
+func (c *fakeOSIOCache) EvictCache(filter osio.CacheFilter) int {
+	c.filter = filter
+	return 2
+}
+
+func (c *fakeOSIOCache) FlushCache() int {
+	c.flushed = true
+	return 5
+}
+
+func newOSIOCacheTestServer(cache OSIOCache, peers string) *httptest.Server {
+	router := mux.NewRouter()
+	NewOSIOCacheHandler(cache, "admin", peers).AddRoutes(router)
+	return httptest.NewServer(router)
+}
+
+func doOSIOCacheRequest(t *testing.T, method, url, token string) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestOSIOCacheHandler(t *testing.T) {
+	cache := &fakeOSIOCache{entries: []osio.CacheEntry{{Key: "abc", User: "11111111", Namespace: "john-preview", Resolution: osio.ResolutionByToken}}}
+	server := newOSIOCacheTestServer(cache, "")
+	defer server.Close()
+
+	resp := doOSIOCacheRequest(t, http.MethodGet, server.URL+"/api/osio/cache", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = doOSIOCacheRequest(t, http.MethodGet, server.URL+"/api/osio/cache", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = doOSIOCacheRequest(t, http.MethodGet, server.URL+"/api/osio/cache", "admin")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var entries []osio.CacheEntry
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&entries))
+	assert.Equal(t, cache.entries, entries)
+
+	resp = doOSIOCacheRequest(t, http.MethodDelete, server.URL+"/api/osio/cache", "admin")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = doOSIOCacheRequest(t, http.MethodDelete, server.URL+"/api/osio/cache?user=11111111&cluster="+url.QueryEscape("https://api.cluster1.com"), "admin")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result osioCacheResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, osioCacheResult{Evicted: 2}, result)
+	assert.Equal(t, osio.CacheFilter{User: "11111111", Cluster: "https://api.cluster1.com"}, cache.filter)
+
+	resp = doOSIOCacheRequest(t, http.MethodDelete, server.URL+"/api/osio/cache/all", "admin")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, cache.flushed)
+}
+
+func TestOSIOCacheHandlerDisabled(t *testing.T) {
+	router := mux.NewRouter()
+	NewOSIOCacheHandler(&fakeOSIOCache{}, "", "").AddRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp := doOSIOCacheRequest(t, http.MethodGet, server.URL+"/api/osio/cache", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestOSIOCacheHandlerBroadcast(t *testing.T) {
+	peerCache := &fakeOSIOCache{}
+	peer := newOSIOCacheTestServer(peerCache, "")
+	defer peer.Close()
+	peerURL, err := url.Parse(peer.URL)
+	require.NoError(t, err)
+
+	cache := &fakeOSIOCache{}
+	handler := NewOSIOCacheHandler(cache, "admin", "http://oso-proxy-admin:"+peerURL.Port()+", http://unknown:8080")
+	handler.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if host == "oso-proxy-admin" {
+			return []string{peerURL.Hostname()}, nil
+		}
+		return nil, &url.Error{Op: "lookup", URL: host, Err: context.DeadlineExceeded}
+	}
+	router := mux.NewRouter()
+	handler.AddRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp := doOSIOCacheRequest(t, http.MethodDelete, server.URL+"/api/osio/cache?namespace=john-preview&broadcast=true", "admin")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result osioCacheResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+
+	assert.Equal(t, osioCacheResult{Evicted: 2, Peers: map[string]osioCacheResult{peerURL.Host: {Evicted: 2}}}, result)
+	assert.Equal(t, osio.CacheFilter{Namespace: "john-preview"}, cache.filter)
+	assert.Equal(t, osio.CacheFilter{Namespace: "john-preview"}, peerCache.filter)
+}
+
+func TestOSIOCacheHandlerBroadcastTLS(t *testing.T) {
+	peerCache := &fakeOSIOCache{}
+	peerRouter := mux.NewRouter()
+	NewOSIOCacheHandler(peerCache, "admin", "").AddRoutes(peerRouter)
+	var peerHost string
+	peer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		peerHost = req.Host
+		peerRouter.ServeHTTP(rw, req)
+	}))
+	defer peer.Close()
+	peerURL, err := url.Parse(peer.URL)
+	require.NoError(t, err)
+
+	// the certificate of the test server is valid for example.com
+	handler := NewOSIOCacheHandler(&fakeOSIOCache{}, "admin", "https://example.com:"+peerURL.Port())
+	handler.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{peerURL.Hostname()}, nil
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(peer.Certificate())
+	handler.tlsConfig = &tls.Config{RootCAs: roots}
+	router := mux.NewRouter()
+	handler.AddRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp := doOSIOCacheRequest(t, http.MethodDelete, server.URL+"/api/osio/cache?namespace=john-preview&broadcast=true", "admin")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result osioCacheResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+
+	assert.Equal(t, osioCacheResult{Evicted: 2, Peers: map[string]osioCacheResult{peerURL.Host: {Evicted: 2}}}, result)
+	assert.Equal(t, "example.com:"+peerURL.Port(), peerHost)
+	assert.Equal(t, osio.CacheFilter{Namespace: "john-preview"}, peerCache.filter)
+}
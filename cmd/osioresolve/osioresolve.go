@@ -0,0 +1,99 @@
+package osioresolve
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/containous/flaeg"
+	"github.com/containous/traefik/log"
+	osiomiddleware "github.com/containous/traefik/middlewares/osio"
+	"github.com/containous/traefik/provider/osio"
+	"github.com/sirupsen/logrus"
+)
+
+// Configuration holds the osio-resolve command configuration.
+type Configuration struct {
+	Token      string `description:"Token of the request, '-' to read it from the standard input"`
+	UserID     string `description:"User impersonated by a service token (e.g. Che)"`
+	Path       string `description:"Sample request path"`
+	Method     string `description:"Sample request method"`
+	ConfigFile string `description:"TOML configuration holding the [osio] routes, issuers and passthrough"`
+	LogLevel   string `description:"Log level"`
+}
+
+// NewCmd builds a new OSIO resolve command
+func NewCmd() *flaeg.Command {
+	config := &Configuration{
+		Token:    "-",
+		Path:     "/api/v1/namespaces",
+		Method:   http.MethodGet,
+		LogLevel: "error",
+	}
+	return &flaeg.Command{
+		Name: "osio-resolve",
+		Description: `Resolve a sample request as the osio middleware does, printing the calls to the auth, tenant and cluster services and the routing decision.
+It uses the TENANT_URL, AUTH_URL, SERVICE_ACCOUNT_ID, SERVICE_ACCOUNT_SECRET and AUTH_TOKEN_KEY (or AUTH_TOKEN_KEYRING) environment variables of the proxy.`,
+		Config:                config,
+		DefaultPointersConfig: &Configuration{},
+		Run:                   runCmd(config),
+	}
+}
+
+func runCmd(config *Configuration) func() error {
+	return func() error {
+		level, err := logrus.ParseLevel(strings.ToLower(config.LogLevel))
+		if err != nil {
+			return err
+		}
+		log.SetLevel(level)
+
+		token := config.Token
+		if token == "-" {
+			if token, err = bufio.NewReader(os.Stdin).ReadString('\n'); err != nil && token == "" {
+				return fmt.Errorf("error reading the token: %v", err)
+			}
+			token = strings.TrimSpace(token)
+		}
+
+		provider := &osio.Provider{}
+		if config.ConfigFile != "" {
+			file := struct{ OSIO *osio.Provider }{OSIO: provider}
+			if _, err := toml.DecodeFile(config.ConfigFile, &file); err != nil {
+				return fmt.Errorf("error reading TOML config file %s: %v", config.ConfigFile, err)
+			}
+		}
+
+		// every call of the locators goes through the default client
+		tracer := NewTracer(http.DefaultTransport)
+		http.DefaultClient.Transport = tracer
+
+		auth, err := newOSIOAuth(provider)
+		if err != nil {
+			return err
+		}
+		result, err := Resolve(auth, tracer, Request{Method: config.Method, Path: config.Path, Token: token, UserID: config.UserID})
+		if err != nil {
+			return err
+		}
+		result.Print(os.Stdout)
+		return nil
+	}
+}
+
+// newOSIOAuth creates the middleware as the proxy does, configured by the [osio] section.
+func newOSIOAuth(provider *osio.Provider) (*osiomiddleware.OSIOAuth, error) {
+	for _, name := range []string{"TENANT_URL", "AUTH_URL", "SERVICE_ACCOUNT_ID", "SERVICE_ACCOUNT_SECRET"} {
+		if os.Getenv(name) == "" {
+			return nil, fmt.Errorf("missing %s", name)
+		}
+	}
+	keyring, err := osio.NewPreConfiguredKeyring()
+	if err != nil {
+		return nil, err
+	}
+	return osiomiddleware.NewFromProvider(provider, keyring)
+}
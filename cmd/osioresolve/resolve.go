@@ -0,0 +1,177 @@
+package osioresolve
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	osiomiddleware "github.com/containous/traefik/middlewares/osio"
+	"github.com/containous/traefik/provider/osio"
+)
+
+// Request is the sample request to resolve.
+type Request struct {
+	Method string
+	Path   string
+	Token  string
+	// UserID is the user impersonated by a service token
+	UserID string
+}
+
+// Step is a call made to the auth, tenant or cluster services while resolving the request.
+type Step struct {
+	Method   string
+	URL      string
+	Status   string
+	Duration time.Duration
+	Err      error
+}
+
+// Tracer records the calls of the HTTP client it is the transport of.
+type Tracer struct {
+	next  http.RoundTripper
+	mux   sync.Mutex
+	steps []Step
+}
+
+// NewTracer creates a Tracer sending the requests with the given transport.
+func NewTracer(next http.RoundTripper) *Tracer {
+	return &Tracer{next: next}
+}
+
+// RoundTrip sends the request and records it.
+func (t *Tracer) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	step := Step{Method: req.Method, URL: req.URL.String(), Duration: time.Since(start), Err: err}
+	if resp != nil {
+		step.Status = resp.Status
+	}
+	t.mux.Lock()
+	t.steps = append(t.steps, step)
+	t.mux.Unlock()
+	return resp, err
+}
+
+// Steps returns and forgets the recorded calls.
+func (t *Tracer) Steps() []Step {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	steps := t.steps
+	t.steps = nil
+	return steps
+}
+
+// Result is the routing decision of the osio middleware for a request.
+type Result struct {
+	Steps []Step
+	// Status is the status of the response of the middleware, http.StatusOK when the request is forwarded
+	Status int
+	// Debug holds the debug response headers of the middleware
+	Debug http.Header
+	// Forwarded is the request forwarded to the cluster, nil when rejected or redirected
+	Forwarded *http.Request
+	// Location is the redirect location
+	Location string
+}
+
+// Resolve runs the request through the middleware, which resolves it as the proxy does:
+// token type, tenant, OSO token and, for service tokens, cluster token and secret.
+func Resolve(auth *osiomiddleware.OSIOAuth, tracer *Tracer, request Request) (*Result, error) {
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	debug := &osio.Debug{Secret: hex.EncodeToString(secret)}
+	if err := auth.SetDebug(debug); err != nil {
+		return nil, err
+	}
+
+	method := request.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req := httptest.NewRequest(method, request.Path, nil)
+	req.Header.Set(debug.Header, debug.Secret)
+	if request.Token != "" {
+		req.Header.Set(osiomiddleware.Authorization, "Bearer "+request.Token)
+	}
+	if request.UserID != "" {
+		req.Header.Set(osiomiddleware.UserIDHeader, request.UserID)
+	}
+
+	result := &Result{}
+	rw := httptest.NewRecorder()
+	auth.ServeHTTP(rw, req, func(rw http.ResponseWriter, req *http.Request) {
+		result.Forwarded = req
+		rw.WriteHeader(http.StatusOK)
+	})
+	result.Steps = tracer.Steps()
+	result.Status = rw.Code
+	result.Location = rw.Header().Get("Location")
+	result.Debug = make(http.Header)
+	for name, values := range rw.Header() {
+		if strings.HasPrefix(name, "X-Osio-Debug-") {
+			result.Debug[name] = values
+		}
+	}
+	return result, nil
+}
+
+// Print writes the calls and the routing decision, masking the tokens.
+func (r *Result) Print(w io.Writer) {
+	fmt.Fprintf(w, "Calls\n")
+	if len(r.Steps) == 0 {
+		fmt.Fprintf(w, "  none\n")
+	}
+	for i, step := range r.Steps {
+		if step.Err != nil {
+			fmt.Fprintf(w, "  %d. %s %s -> error: %v (%s)\n", i+1, step.Method, step.URL, step.Err, step.Duration)
+		} else {
+			fmt.Fprintf(w, "  %d. %s %s -> %s (%s)\n", i+1, step.Method, step.URL, step.Status, step.Duration)
+		}
+	}
+
+	fmt.Fprintf(w, "\nDecision\n")
+	for _, field := range []struct{ label, header string }{
+		{"auth", osiomiddleware.DebugAuthHeader},
+		{"token type", osiomiddleware.DebugTokenTypeHeader},
+		{"namespace", osiomiddleware.DebugNamespaceHeader},
+		{"request type", osiomiddleware.DebugRouteHeader},
+		{"target", osiomiddleware.DebugTargetHeader},
+		{"resolved in", osiomiddleware.DebugResolveDurationHeader},
+	} {
+		if value := r.Debug.Get(field.header); value != "" {
+			fmt.Fprintf(w, "  %-14s %s\n", field.label+":", value)
+		}
+	}
+	switch {
+	case r.Forwarded != nil:
+		path := r.Forwarded.URL.Path
+		if r.Forwarded.URL.RawQuery != "" {
+			path += "?" + r.Forwarded.URL.RawQuery
+		}
+		fmt.Fprintf(w, "  %-14s %s %s\n", "forwarded:", r.Forwarded.Method, path)
+		if auth := r.Forwarded.Header.Get(osiomiddleware.Authorization); auth != "" {
+			fmt.Fprintf(w, "  %-14s Bearer %s\n", "authorization:", maskToken(strings.TrimPrefix(auth, "Bearer ")))
+		}
+	case r.Location != "":
+		fmt.Fprintf(w, "  %-14s %d %s\n", "redirected:", r.Status, r.Location)
+	default:
+		fmt.Fprintf(w, "  %-14s %d %s\n", "rejected:", r.Status, http.StatusText(r.Status))
+	}
+}
+
+// maskToken keeps the first characters of the token, enough to tell tokens apart.
+func maskToken(token string) string {
+	if len(token) <= 8 {
+		return strings.Repeat("*", len(token))
+	}
+	return fmt.Sprintf("%s****** (%d characters)", token[:4], len(token))
+}
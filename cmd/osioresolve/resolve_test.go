@@ -0,0 +1,106 @@
+package osioresolve
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/containous/traefik/cmd/osiosandbox"
+	osiomiddleware "github.com/containous/traefik/middlewares/osio"
+	"github.com/containous/traefik/provider/osio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	johnID = "11111111-4c6d-498c-97d0-cc7f2abcaca6"
+	janeID = "22222222-1874-4de5-9c62-602634cb5cc2"
+)
+
+func stepURLs(steps []Step) []string {
+	var urls []string
+	for _, step := range steps {
+		urls = append(urls, step.Method+" "+step.URL+" "+step.Status)
+	}
+	return urls
+}
+
+func TestResolve(t *testing.T) {
+	fixture, err := osiosandbox.LoadFixture("../../osio/sandbox.yml")
+	require.NoError(t, err)
+	sandbox, err := osiosandbox.Start(fixture)
+	require.NoError(t, err)
+	defer sandbox.Close()
+	require.NoError(t, sandbox.Setenv())
+
+	tracer := NewTracer(http.DefaultTransport)
+	http.DefaultClient.Transport = tracer
+	defer func() { http.DefaultClient.Transport = nil }()
+
+	auth, err := newOSIOAuth(&osio.Provider{})
+	require.NoError(t, err)
+
+	t.Run("user token", func(t *testing.T) {
+		token := sandbox.UserToken(johnID)
+		result, err := Resolve(auth, tracer, Request{Path: "/api/v1/namespaces/john-preview/pods", Token: token})
+		require.NoError(t, err)
+
+		clusterURL := sandbox.ClusterURL("us-east-2")
+		assert.Equal(t, []string{
+			"GET " + sandbox.AuthURL() + "/token/keys 200 OK",
+			"GET " + sandbox.TenantURL() + "/tenant 200 OK",
+			"GET " + sandbox.AuthURL() + "/token?for=" + clusterURL + " 200 OK",
+		}, stepURLs(result.Steps))
+		require.NotNil(t, result.Forwarded)
+		assert.Equal(t, clusterURL, result.Forwarded.Header.Get("Target"))
+		assert.Equal(t, "john-preview", result.Debug.Get(osiomiddleware.DebugNamespaceHeader))
+
+		buf := new(bytes.Buffer)
+		result.Print(buf)
+		assert.Contains(t, buf.String(), "target:        "+clusterURL)
+		assert.Contains(t, buf.String(), "forwarded:     GET /api/v1/namespaces/john-preview/pods")
+		assert.NotContains(t, buf.String(), token)
+		forwardedToken := strings.TrimPrefix(result.Forwarded.Header.Get(osiomiddleware.Authorization), "Bearer ")
+		assert.NotContains(t, buf.String(), forwardedToken)
+	})
+
+	t.Run("che token", func(t *testing.T) {
+		result, err := Resolve(auth, tracer, Request{Path: "/api/v1/namespaces/jane-preview-che/pods", Token: sandbox.CheToken(), UserID: janeID})
+		require.NoError(t, err)
+
+		clusterURL := sandbox.ClusterURL("us-east-2a")
+		assert.Equal(t, []string{
+			"GET " + sandbox.TenantURL() + "/tenants/" + janeID + " 200 OK",
+			"POST " + sandbox.AuthURL() + "/token 200 OK",
+			"GET " + sandbox.AuthURL() + "/token?for=" + clusterURL + " 200 OK",
+			"GET " + clusterURL + "/api/v1/namespaces/jane-preview-che/serviceaccounts/che 200 OK",
+			"GET " + clusterURL + "/api/v1/namespaces/jane-preview-che/secrets/che-token-sandbox 200 OK",
+		}, stepURLs(result.Steps))
+		require.NotNil(t, result.Forwarded)
+		assert.Equal(t, clusterURL, result.Forwarded.Header.Get("Target"))
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		result, err := Resolve(auth, tracer, Request{Path: "/console/project/john-preview", Token: sandbox.UserToken(johnID)})
+		require.NoError(t, err)
+		assert.Nil(t, result.Forwarded)
+		assert.Equal(t, http.StatusTemporaryRedirect, result.Status)
+		assert.Equal(t, "https://console.us-east-2.sandbox/console/project/john-preview", result.Location)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		result, err := Resolve(auth, tracer, Request{Path: "/api/v1/namespaces/jane-preview-che/pods", Token: sandbox.CheToken(), UserID: "unknown"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"GET " + sandbox.TenantURL() + "/tenants/unknown 404 Not Found"}, stepURLs(result.Steps))
+
+		buf := new(bytes.Buffer)
+		result.Print(buf)
+		assert.Contains(t, buf.String(), "rejected:      401 Unauthorized")
+	})
+}
+
+func TestMaskToken(t *testing.T) {
+	assert.Equal(t, "****", maskToken("abcd"))
+	assert.Equal(t, "sand****** (16 characters)", maskToken("sandbox-12345678"))
+}
@@ -0,0 +1,95 @@
+package osiosandbox
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Fixture describes the users, namespaces and clusters served by the sandbox stubs.
+type Fixture struct {
+	Clusters []*FixtureCluster `yaml:"clusters"`
+	Users    []*FixtureUser    `yaml:"users"`
+}
+
+// FixtureCluster is a cluster served by a stub API server.
+type FixtureCluster struct {
+	Name string `yaml:"name"`
+}
+
+// FixtureUser is a user with its tenant namespaces.
+type FixtureUser struct {
+	Name       string              `yaml:"name"`
+	ID         string              `yaml:"id"`
+	Namespaces []*FixtureNamespace `yaml:"namespaces"`
+}
+
+// FixtureNamespace is a tenant namespace of a user, on one of the clusters.
+type FixtureNamespace struct {
+	Name    string `yaml:"name"`
+	Type    string `yaml:"type"`
+	Cluster string `yaml:"cluster"`
+}
+
+// LoadFixture reads and validates a YAML fixture.
+func LoadFixture(filename string) (*Fixture, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	fixture := &Fixture{}
+	if err := yaml.Unmarshal(content, fixture); err != nil {
+		return nil, fmt.Errorf("error parsing fixture %s: %v", filename, err)
+	}
+	if err := fixture.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %v", filename, err)
+	}
+	return fixture, nil
+}
+
+// Validate checks that the names are unique and that the namespaces are on known clusters,
+// the user IDs defaulting to the user names.
+func (f *Fixture) Validate() error {
+	if len(f.Clusters) == 0 {
+		return fmt.Errorf("no cluster")
+	}
+	clusters := make(map[string]bool)
+	for _, cluster := range f.Clusters {
+		if cluster.Name == "" {
+			return fmt.Errorf("cluster without name")
+		}
+		if clusters[cluster.Name] {
+			return fmt.Errorf("duplicate cluster %q", cluster.Name)
+		}
+		clusters[cluster.Name] = true
+	}
+
+	users := make(map[string]bool)
+	namespaces := make(map[string]bool)
+	for _, user := range f.Users {
+		if user.Name == "" {
+			return fmt.Errorf("user without name")
+		}
+		if user.ID == "" {
+			user.ID = user.Name
+		}
+		if users[user.ID] {
+			return fmt.Errorf("duplicate user %q", user.ID)
+		}
+		users[user.ID] = true
+		for _, ns := range user.Namespaces {
+			if ns.Name == "" || ns.Type == "" {
+				return fmt.Errorf("user %q: namespace without name or type", user.Name)
+			}
+			if namespaces[ns.Name] {
+				return fmt.Errorf("duplicate namespace %q", ns.Name)
+			}
+			namespaces[ns.Name] = true
+			if !clusters[ns.Cluster] {
+				return fmt.Errorf("namespace %q: unknown cluster %q", ns.Name, ns.Cluster)
+			}
+		}
+	}
+	return nil
+}
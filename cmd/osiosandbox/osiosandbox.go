@@ -0,0 +1,110 @@
+package osiosandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/containous/flaeg"
+	"github.com/containous/staert"
+	"github.com/containous/traefik/cmd"
+	"github.com/containous/traefik/configuration"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/provider/osio"
+	"github.com/containous/traefik/server"
+	"github.com/sirupsen/logrus"
+)
+
+// Configuration holds the osio-sandbox command configuration.
+type Configuration struct {
+	Fixture    string `description:"YAML fixture of the sandbox users, namespaces and clusters"`
+	ConfigFile string `description:"TOML configuration of the proxy (entry points, [osio] section...), the OSIO provider being always enabled"`
+	Address    string `description:"Address of the proxy entry point, when the TOML configuration defines none"`
+	LogLevel   string `description:"Log level"`
+}
+
+// NewCmd builds a new OSIO sandbox command
+func NewCmd() *flaeg.Command {
+	config := &Configuration{
+		Fixture:  "sandbox.yml",
+		Address:  ":8080",
+		LogLevel: "info",
+	}
+	return &flaeg.Command{
+		Name:                  "osio-sandbox",
+		Description:           `Run the proxy against local stubs of the OSIO tenant, auth and cluster services described by a YAML fixture`,
+		Config:                config,
+		DefaultPointersConfig: &Configuration{},
+		Run:                   runCmd(config),
+	}
+}
+
+func runCmd(config *Configuration) func() error {
+	return func() error {
+		level, err := logrus.ParseLevel(strings.ToLower(config.LogLevel))
+		if err != nil {
+			return err
+		}
+		log.SetLevel(level)
+
+		fixture, err := LoadFixture(config.Fixture)
+		if err != nil {
+			return err
+		}
+		sandbox, err := Start(fixture)
+		if err != nil {
+			return err
+		}
+		defer sandbox.Close()
+
+		if err := sandbox.Setenv(); err != nil {
+			return err
+		}
+		globalConfiguration, err := proxyConfiguration(config)
+		if err != nil {
+			return err
+		}
+		sandbox.Print(os.Stdout, globalConfiguration.EntryPoints[globalConfiguration.DefaultEntryPoints[0]].Address)
+
+		globalConfiguration.SetEffectiveConfiguration(config.ConfigFile)
+		globalConfiguration.ValidateConfiguration()
+		svr := server.NewServer(*globalConfiguration, configuration.NewProviderAggregator(globalConfiguration))
+		svr.StartWithContext(cmd.ContextWithSignal(context.Background()))
+		defer svr.Close()
+		svr.Wait()
+		log.Info("Shutting down")
+		return nil
+	}
+}
+
+// proxyConfiguration loads the TOML configuration, if any, and enables the OSIO provider.
+func proxyConfiguration(config *Configuration) (*configuration.GlobalConfiguration, error) {
+	traefikConfiguration := cmd.NewTraefikConfiguration()
+	traefikConfiguration.CheckNewVersion = false
+	if config.ConfigFile != "" {
+		traefikCmd := &flaeg.Command{
+			Name:                  "traefik",
+			Config:                traefikConfiguration,
+			DefaultPointersConfig: cmd.NewTraefikDefaultPointersConfiguration(),
+		}
+		s := staert.NewStaert(traefikCmd)
+		s.AddSource(staert.NewTomlSource("traefik", []string{config.ConfigFile}))
+		if _, err := s.LoadConfig(); err != nil {
+			return nil, fmt.Errorf("error reading TOML config file %s: %v", config.ConfigFile, err)
+		}
+	}
+
+	globalConfiguration := &traefikConfiguration.GlobalConfiguration
+	if len(globalConfiguration.EntryPoints) == 0 {
+		globalConfiguration.EntryPoints = configuration.EntryPoints{"http": {Address: config.Address}}
+		globalConfiguration.DefaultEntryPoints = configuration.DefaultEntryPoints{"http"}
+	}
+	if len(globalConfiguration.DefaultEntryPoints) == 0 || globalConfiguration.EntryPoints[globalConfiguration.DefaultEntryPoints[0]] == nil {
+		return nil, fmt.Errorf("default entry point is not defined")
+	}
+	if globalConfiguration.OSIO == nil {
+		globalConfiguration.OSIO = &osio.Provider{}
+	}
+	return globalConfiguration, nil
+}
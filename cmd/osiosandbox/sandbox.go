@@ -0,0 +1,438 @@
+package osiosandbox
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/containous/traefik/cmd/osiosandbox/stubs"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/provider/osio"
+	jwt "github.com/dgrijalva/jwt-go"
+	"golang.org/x/crypto/openpgp"
+)
+
+const (
+	// cheServiceAccount is the service_accountname of the Che token, see osio.TokenTypeMap
+	cheServiceAccount = "rh-che"
+	cheSecretName     = "che-token-sandbox"
+	anonymousUser     = "system:anonymous"
+)
+
+// Sandbox runs the stub auth, tenant and cluster services of a fixture, the auth stub and the
+// responses being the ones of the integration tests.
+type Sandbox struct {
+	fixture *Fixture
+	tokens  *stubs.TokenManager
+
+	// ServiceAccountID and ServiceAccountSecret are the credentials of the proxy service account,
+	// TokenKey the passphrase encrypting the cluster tokens returned to it.
+	ServiceAccountID     string
+	ServiceAccountSecret string
+	TokenKey             string
+
+	auth     *httptest.Server
+	tenant   *httptest.Server
+	clusters map[string]*sandboxCluster
+
+	saToken    string
+	cheToken   string
+	userTokens map[string]string
+
+	// clusterTokens are the tokens accepted by the cluster stubs, by token
+	mux           sync.Mutex
+	clusterTokens map[string]clusterIdentity
+}
+
+type sandboxCluster struct {
+	*FixtureCluster
+	api     *httptest.Server
+	metrics *httptest.Server
+}
+
+// clusterIdentity is the user a token authenticates on a cluster.
+type clusterIdentity struct {
+	cluster string
+	user    string
+}
+
+// Start starts the stubs of the fixture on local ports.
+func Start(fixture *Fixture) (*Sandbox, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sandbox{
+		fixture:              fixture,
+		tokens:               stubs.NewTokenManager(key),
+		ServiceAccountID:     "sandbox-proxy",
+		ServiceAccountSecret: randomString(),
+		TokenKey:             randomString(),
+		clusters:             make(map[string]*sandboxCluster),
+		userTokens:           make(map[string]string),
+		clusterTokens:        make(map[string]clusterIdentity),
+	}
+
+	if s.saToken, err = s.tokens.SignToken(jwt.MapClaims{"sub": s.ServiceAccountID, "service_accountname": s.ServiceAccountID}); err != nil {
+		return nil, err
+	}
+	if s.cheToken, err = s.tokens.SignToken(jwt.MapClaims{"sub": cheServiceAccount, "service_accountname": cheServiceAccount}); err != nil {
+		return nil, err
+	}
+	for _, user := range fixture.Users {
+		token, err := s.tokens.SignToken(jwt.MapClaims{"sub": user.ID, "preferred_username": user.Name})
+		if err != nil {
+			return nil, err
+		}
+		s.userTokens[user.ID] = token
+	}
+
+	for _, cluster := range fixture.Clusters {
+		c := &sandboxCluster{FixtureCluster: cluster}
+		c.api = httptest.NewServer(s.clusterHandler(cluster.Name, "api"))
+		c.metrics = httptest.NewServer(s.clusterHandler(cluster.Name, "metrics"))
+		s.clusters[cluster.Name] = c
+	}
+	s.auth = httptest.NewServer(&stubs.AuthStub{Tokens: s.tokens, Token: s.serveToken, Clusters: s.serveClusters})
+	s.tenant = httptest.NewServer(http.StripPrefix("/api", http.HandlerFunc(s.serveTenant)))
+	return s, nil
+}
+
+// Close stops the stubs.
+func (s *Sandbox) Close() {
+	s.auth.Close()
+	s.tenant.Close()
+	for _, cluster := range s.clusters {
+		cluster.api.Close()
+		cluster.metrics.Close()
+	}
+}
+
+// AuthURL returns the base URL of the auth stub, i.e. the AUTH_URL of the proxy.
+func (s *Sandbox) AuthURL() string {
+	return s.auth.URL + "/api"
+}
+
+// TenantURL returns the base URL of the tenant stub, i.e. the TENANT_URL of the proxy.
+func (s *Sandbox) TenantURL() string {
+	return s.tenant.URL + "/api"
+}
+
+// ClusterURL returns the API URL of the named cluster stub.
+func (s *Sandbox) ClusterURL(name string) string {
+	return s.clusters[name].api.URL
+}
+
+// UserToken returns the token of the user with the given ID.
+func (s *Sandbox) UserToken(userID string) string {
+	return s.userTokens[userID]
+}
+
+// CheToken returns the Che service token, impersonating the user of the Impersonate-User header.
+func (s *Sandbox) CheToken() string {
+	return s.cheToken
+}
+
+// claims returns the claims of the bearer token of the request signed by the sandbox, nil otherwise.
+func (s *Sandbox) claims(r *http.Request) jwt.MapClaims {
+	token := bearerToken(r)
+	if token == "" {
+		return nil
+	}
+	jwtToken, err := s.tokens.ParseToken(token)
+	if err != nil {
+		return nil
+	}
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	return claims
+}
+
+// serveToken returns the proxy service account token, and the cluster tokens.
+func (s *Sandbox) serveToken(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.serveClusterToken(rw, r)
+		return
+	}
+	var tokenReq osio.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&tokenReq); err != nil || tokenReq.ClientID != s.ServiceAccountID || tokenReq.ClientSecret != s.ServiceAccountSecret {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	stubs.WriteJSON(rw, osio.TokenResponse{AccessToken: s.saToken, TokenType: "bearer"})
+}
+
+// serveClusterToken returns the encrypted admin token of the cluster to the proxy service account,
+// and the OSO token of the user on the cluster to the users having a namespace there.
+func (s *Sandbox) serveClusterToken(rw http.ResponseWriter, r *http.Request) {
+	cluster := s.clusterByURL(r.URL.Query().Get("for"))
+	if cluster == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if bearerToken(r) == s.saToken {
+		encrypted, err := encryptToken(s.clusterToken(cluster.Name, "system:admin"), s.TokenKey)
+		if err != nil {
+			log.Errorf("Error encrypting cluster token: %v", err)
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		stubs.WriteJSON(rw, osio.TokenResponse{AccessToken: encrypted, TokenType: "bearer"})
+		return
+	}
+	user := s.tokenUser(r)
+	if user == nil || !user.hasNamespaceOn(cluster.Name) {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	stubs.WriteJSON(rw, osio.TokenResponse{AccessToken: s.clusterToken(cluster.Name, user.Name), TokenType: "bearer"})
+}
+
+func (s *Sandbox) serveClusters(rw http.ResponseWriter, r *http.Request) {
+	if bearerToken(r) != s.saToken {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var clusters []stubs.Cluster
+	for _, cluster := range s.fixture.Clusters {
+		c := s.clusters[cluster.Name]
+		clusters = append(clusters, stubs.Cluster{
+			Name:       cluster.Name,
+			APIURL:     c.api.URL + "/",
+			MetricsURL: c.metrics.URL + "/",
+			ConsoleURL: fmt.Sprintf("https://console.%s.sandbox/console/", cluster.Name),
+			LoggingURL: fmt.Sprintf("https://console.%s.sandbox/logs/", cluster.Name),
+			AppDNS:     fmt.Sprintf("apps.%s.sandbox", cluster.Name),
+		})
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write([]byte(stubs.ClusterData(clusters)))
+}
+
+// serveTenant returns the namespaces of the token user on /tenant, and of the given user
+// on /tenants/<id> for the proxy service account and the Che token.
+func (s *Sandbox) serveTenant(rw http.ResponseWriter, r *http.Request) {
+	var user *FixtureUser
+	switch {
+	case r.URL.Path == "/tenant":
+		user = s.tokenUser(r)
+		if user == nil {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	case strings.HasPrefix(r.URL.Path, "/tenants/"):
+		if token := bearerToken(r); token != s.saToken && token != s.cheToken {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		user = s.user(strings.TrimPrefix(r.URL.Path, "/tenants/"))
+	}
+	if user == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var namespaces []stubs.TenantNamespace
+	for _, ns := range user.Namespaces {
+		c := s.clusters[ns.Cluster]
+		namespaces = append(namespaces, stubs.TenantNamespace{
+			Name:              ns.Name,
+			Type:              ns.Type,
+			ClusterURL:        c.api.URL,
+			ClusterMetricsURL: c.metrics.URL,
+			ClusterConsoleURL: fmt.Sprintf("https://console.%s.sandbox/console", ns.Cluster),
+			ClusterLoggingURL: fmt.Sprintf("https://console.%s.sandbox/logs", ns.Cluster),
+			ClusterAppDomain:  fmt.Sprintf("apps.%s.sandbox", ns.Cluster),
+		})
+	}
+	stubs.WriteTenant(rw, namespaces)
+}
+
+// clusterResponse is returned by the cluster stubs, describing the request as the cluster sees it.
+type clusterResponse struct {
+	Cluster     string `json:"cluster"`
+	Endpoint    string `json:"endpoint"`
+	User        string `json:"user"`
+	Impersonate string `json:"impersonate,omitempty"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Query       string `json:"query,omitempty"`
+}
+
+func (s *Sandbox) clusterHandler(clusterName, endpoint string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		user := anonymousUser
+		if token := bearerToken(r); token != "" {
+			identity, ok := s.clusterIdentity(token)
+			if !ok || identity.cluster != clusterName {
+				// e.g. the OSO token of another cluster
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			user = identity.user
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if endpoint == "api" && len(parts) == 6 && parts[0] == "api" && parts[2] == "namespaces" {
+			namespace := parts[3]
+			switch {
+			case parts[4] == "serviceaccounts" && parts[5] == "che":
+				stubs.WriteJSON(rw, map[string]interface{}{"secrets": []map[string]string{{"name": cheSecretName}}})
+				return
+			case parts[4] == "secrets" && parts[5] == cheSecretName:
+				token := s.clusterToken(clusterName, "system:serviceaccount:"+namespace+":che")
+				stubs.WriteJSON(rw, map[string]interface{}{"data": map[string]string{"token": base64.StdEncoding.EncodeToString([]byte(token))}})
+				return
+			}
+		}
+
+		stubs.WriteJSON(rw, clusterResponse{
+			Cluster:     clusterName,
+			Endpoint:    endpoint,
+			User:        user,
+			Impersonate: r.Header.Get("Impersonate-User"),
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Query:       r.URL.RawQuery,
+		})
+	})
+}
+
+// clusterToken returns a token authenticating the user on the cluster.
+func (s *Sandbox) clusterToken(clusterName, user string) string {
+	token := fmt.Sprintf("sandbox-%s-%s", clusterName, randomString())
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.clusterTokens[token] = clusterIdentity{cluster: clusterName, user: user}
+	return token
+}
+
+func (s *Sandbox) clusterIdentity(token string) (clusterIdentity, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	identity, ok := s.clusterTokens[token]
+	return identity, ok
+}
+
+func (s *Sandbox) clusterByURL(clusterURL string) *sandboxCluster {
+	clusterURL = strings.TrimSuffix(clusterURL, "/")
+	for _, cluster := range s.clusters {
+		if cluster.api.URL == clusterURL || cluster.metrics.URL == clusterURL {
+			return cluster
+		}
+	}
+	return nil
+}
+
+func (s *Sandbox) tokenUser(r *http.Request) *FixtureUser {
+	claims := s.claims(r)
+	if claims == nil || claims["service_accountname"] != nil {
+		return nil
+	}
+	sub, _ := claims["sub"].(string)
+	return s.user(sub)
+}
+
+func (s *Sandbox) user(id string) *FixtureUser {
+	for _, user := range s.fixture.Users {
+		if user.ID == id {
+			return user
+		}
+	}
+	return nil
+}
+
+func (u *FixtureUser) hasNamespaceOn(clusterName string) bool {
+	for _, ns := range u.Namespaces {
+		if ns.Cluster == clusterName {
+			return true
+		}
+	}
+	return false
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") && !strings.HasPrefix(auth, "bearer ") {
+		return ""
+	}
+	return auth[len("Bearer "):]
+}
+
+// encryptToken encrypts the token with the passphrase, as the auth service does with AUTH_TOKEN_KEY.
+func encryptToken(token, passphrase string) (string, error) {
+	buf := new(bytes.Buffer)
+	w, err := openpgp.SymmetricallyEncrypt(buf, []byte(passphrase), nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write([]byte(token)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Setenv sets the environment variables configuring the proxy to use the stubs.
+func (s *Sandbox) Setenv() error {
+	env := map[string]string{
+		"AUTH_URL":               s.AuthURL(),
+		"TENANT_URL":             s.TenantURL(),
+		"SERVICE_ACCOUNT_ID":     s.ServiceAccountID,
+		"SERVICE_ACCOUNT_SECRET": s.ServiceAccountSecret,
+		"AUTH_TOKEN_KEY":         s.TokenKey,
+	}
+	for name, value := range env {
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+	return os.Unsetenv("AUTH_TOKEN_KEYRING")
+}
+
+// Print writes the stub URLs and the tokens of the users.
+func (s *Sandbox) Print(w io.Writer, address string) {
+	fmt.Fprintf(w, "OSIO sandbox\n")
+	fmt.Fprintf(w, "  auth:    %s\n", s.AuthURL())
+	fmt.Fprintf(w, "  tenant:  %s\n", s.TenantURL())
+	for _, cluster := range s.fixture.Clusters {
+		fmt.Fprintf(w, "  cluster: %s %s\n", cluster.Name, s.ClusterURL(cluster.Name))
+	}
+
+	fmt.Fprintf(w, "\nUser tokens\n")
+	for _, user := range s.fixture.Users {
+		fmt.Fprintf(w, "  %s (%s): %s\n", user.Name, user.ID, s.UserToken(user.ID))
+	}
+	fmt.Fprintf(w, "\nChe token, impersonating the user of the Impersonate-User header\n  %s\n", s.CheToken())
+
+	proxyURL := "http://localhost"
+	if _, port, err := net.SplitHostPort(address); err == nil {
+		proxyURL += ":" + port
+	}
+	for _, user := range s.fixture.Users {
+		if len(user.Namespaces) > 0 {
+			fmt.Fprintf(w, "\nExample\n  curl -H \"Authorization: Bearer %s\" %s/api/v1/namespaces/%s/pods\n", s.UserToken(user.ID), proxyURL, user.Namespaces[0].Name)
+			break
+		}
+	}
+}
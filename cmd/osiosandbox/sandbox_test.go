@@ -0,0 +1,150 @@
+package osiosandbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	osiomiddleware "github.com/containous/traefik/middlewares/osio"
+	"github.com/containous/traefik/provider/osio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	johnID = "11111111-4c6d-498c-97d0-cc7f2abcaca6"
+	janeID = "22222222-1874-4de5-9c62-602634cb5cc2"
+)
+
+func startSandbox(t *testing.T) *Sandbox {
+	fixture, err := LoadFixture("../../osio/sandbox.yml")
+	require.NoError(t, err)
+	sandbox, err := Start(fixture)
+	require.NoError(t, err)
+	return sandbox
+}
+
+func getCluster(t *testing.T, url, token string) (int, clusterResponse) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var res clusterResponse
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	}
+	return resp.StatusCode, res
+}
+
+func TestFixtureValidate(t *testing.T) {
+	cluster := []*FixtureCluster{{Name: "us-east-2"}}
+	invalid := map[string]*Fixture{
+		"no cluster":        {},
+		"duplicate cluster": {Clusters: []*FixtureCluster{{Name: "us-east-2"}, {Name: "us-east-2"}}},
+		"duplicate user":    {Clusters: cluster, Users: []*FixtureUser{{Name: "john"}, {Name: "jane", ID: "john"}}},
+		"unknown cluster": {Clusters: cluster, Users: []*FixtureUser{
+			{Name: "john", Namespaces: []*FixtureNamespace{{Name: "john-preview", Type: "user", Cluster: "us-east-2a"}}},
+		}},
+		"namespace type": {Clusters: cluster, Users: []*FixtureUser{
+			{Name: "john", Namespaces: []*FixtureNamespace{{Name: "john-preview", Cluster: "us-east-2"}}},
+		}},
+	}
+	for desc, fixture := range invalid {
+		assert.Error(t, fixture.Validate(), desc)
+	}
+
+	fixture := &Fixture{Clusters: cluster, Users: []*FixtureUser{{Name: "john"}}}
+	require.NoError(t, fixture.Validate())
+	assert.Equal(t, "john", fixture.Users[0].ID)
+}
+
+func TestSandboxUserToken(t *testing.T) {
+	sandbox := startSandbox(t)
+	defer sandbox.Close()
+	token := sandbox.UserToken(johnID)
+
+	tokenType, err := osiomiddleware.CreateTokenTypeLocator(http.DefaultClient, sandbox.AuthURL())(token)
+	require.NoError(t, err)
+	assert.Equal(t, osiomiddleware.UserToken, tokenType)
+
+	ns, err := osiomiddleware.CreateTenantLocator(http.DefaultClient, sandbox.TenantURL()).GetTenant(token, tokenType)
+	require.NoError(t, err)
+	assert.Equal(t, "john-preview", ns.Name)
+	assert.Equal(t, sandbox.ClusterURL("us-east-2"), ns.ClusterURL)
+
+	tokens := osiomiddleware.CreateTenantTokenLocator(http.DefaultClient, sandbox.AuthURL())
+	osoToken, err := tokens.GetTokenWithUserToken(token, ns.ClusterURL)
+	require.NoError(t, err)
+	_, err = tokens.GetTokenWithUserToken(token, sandbox.ClusterURL("us-east-2a"))
+	assert.Error(t, err, "no namespace on the cluster")
+
+	status, res := getCluster(t, ns.ClusterURL+"/api/v1/namespaces/john-preview/pods", osoToken)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, clusterResponse{Cluster: "us-east-2", Endpoint: "api", User: "john", Method: http.MethodGet, Path: "/api/v1/namespaces/john-preview/pods"}, res)
+
+	status, _ = getCluster(t, sandbox.ClusterURL("us-east-2a")+"/api/v1/namespaces/john-preview/pods", osoToken)
+	assert.Equal(t, http.StatusUnauthorized, status, "token of another cluster")
+
+	status, res = getCluster(t, ns.ClusterURL+"/version", "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, anonymousUser, res.User)
+}
+
+func TestSandboxServiceAccount(t *testing.T) {
+	sandbox := startSandbox(t)
+	defer sandbox.Close()
+	client := osio.NewClient()
+
+	_, err := client.GetToken(sandbox.AuthURL()+"/token", &osio.TokenRequest{GrantType: "client_credentials", ClientID: sandbox.ServiceAccountID, ClientSecret: "wrong"})
+	assert.Error(t, err)
+	tokenResp, err := client.GetToken(sandbox.AuthURL()+"/token", &osio.TokenRequest{GrantType: "client_credentials", ClientID: sandbox.ServiceAccountID, ClientSecret: sandbox.ServiceAccountSecret})
+	require.NoError(t, err)
+
+	clusters, err := client.GetClusters(sandbox.AuthURL()+"/clusters", tokenResp)
+	require.NoError(t, err)
+	require.Len(t, clusters.Clusters, 2)
+	assert.Equal(t, sandbox.ClusterURL("us-east-2a")+"/", clusters.Clusters[1].APIURL)
+
+	encrypted, err := client.GetClusterToken(sandbox.AuthURL()+"/token", clusters.Clusters[1].APIURL, tokenResp)
+	require.NoError(t, err)
+	clusterToken, _, err := osio.NewPassphraseKeyring(sandbox.TokenKey).Decrypt(encrypted)
+	require.NoError(t, err)
+
+	// the Che token impersonates a user, its requests use the che service account token of the namespace
+	ns, err := osiomiddleware.CreateTenantLocator(http.DefaultClient, sandbox.TenantURL()).GetTenantById(sandbox.CheToken(), osiomiddleware.CheToken, janeID)
+	require.NoError(t, err)
+	assert.Equal(t, "jane-preview-che", ns.Name)
+
+	secrets := osiomiddleware.CreateSecretLocator(http.DefaultClient)
+	secretName, err := secrets.GetName(ns.ClusterURL, clusterToken, ns.Name, ns.Type)
+	require.NoError(t, err)
+	cheToken, err := secrets.GetSecret(ns.ClusterURL, clusterToken, ns.Name, secretName)
+	require.NoError(t, err)
+
+	status, res := getCluster(t, ns.ClusterURL+"/api/v1/namespaces/jane-preview-che/pods", cheToken)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "system:serviceaccount:jane-preview-che:che", res.User)
+}
+
+func TestSandboxPrint(t *testing.T) {
+	sandbox := startSandbox(t)
+	defer sandbox.Close()
+
+	buf := new(bytes.Buffer)
+	sandbox.Print(buf, ":8080")
+	assert.Contains(t, buf.String(), sandbox.UserToken(johnID))
+	assert.Contains(t, buf.String(), sandbox.UserToken(janeID))
+	assert.Contains(t, buf.String(), sandbox.CheToken())
+	assert.Contains(t, buf.String(), "http://localhost:8080/api/v1/namespaces/john-preview/pods")
+
+	// an address without port is the default HTTP port
+	buf.Reset()
+	sandbox.Print(buf, "localhost")
+	assert.Contains(t, buf.String(), "http://localhost/api/v1/namespaces/john-preview/pods")
+}
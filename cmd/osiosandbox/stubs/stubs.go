@@ -0,0 +1,150 @@
+// Package stubs serves the auth and tenant service responses of the OSIO sandbox and integration tests.
+package stubs
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+
+	"github.com/containous/traefik/log"
+	jwt "github.com/dgrijalva/jwt-go"
+	jose "gopkg.in/square/go-jose.v1"
+)
+
+const keyID = "test-key"
+
+// TenantNamespace is a namespace of the tenant service responses.
+type TenantNamespace struct {
+	Name              string `json:"name"`
+	Type              string `json:"type"`
+	ClusterURL        string `json:"cluster-url"`
+	ClusterMetricsURL string `json:"cluster-metrics-url,omitempty"`
+	ClusterConsoleURL string `json:"cluster-console-url,omitempty"`
+	ClusterLoggingURL string `json:"cluster-logging-url,omitempty"`
+	ClusterAppDomain  string `json:"cluster-app-domain,omitempty"`
+}
+
+// WriteTenant writes the tenant service response holding the namespaces.
+func WriteTenant(rw http.ResponseWriter, namespaces []TenantNamespace) {
+	WriteJSON(rw, map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{"namespaces": namespaces},
+		},
+	})
+}
+
+// Cluster is a cluster of the auth service /clusters responses.
+type Cluster struct {
+	Name       string `json:"name"`
+	APIURL     string `json:"api-url"`
+	MetricsURL string `json:"metrics-url"`
+	ConsoleURL string `json:"console-url"`
+	LoggingURL string `json:"logging-url,omitempty"`
+	AppDNS     string `json:"app-dns"`
+}
+
+// ClusterData returns the auth service /clusters response holding the clusters.
+func ClusterData(clusters []Cluster) string {
+	content, err := json.Marshal(map[string]interface{}{"data": clusters})
+	if err != nil {
+		panic(err)
+	}
+	return string(content)
+}
+
+// AuthStub serves the endpoints of the auth service used by the proxy: /api/token, /api/token/keys
+// with the key of its token manager, and /api/clusters.
+type AuthStub struct {
+	Tokens *TokenManager
+	// Token serves the service account tokens (POST) and the cluster tokens (GET),
+	// a constant service account token by default
+	Token http.HandlerFunc
+	// Clusters serves the clusters
+	Clusters http.HandlerFunc
+}
+
+func (a *AuthStub) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/api/token":
+		if a.Token != nil {
+			a.Token(rw, req)
+			return
+		}
+		tokenAPIResponse := `{"access_token": "1111","token_type": "bearer"}`
+		rw.Write([]byte(tokenAPIResponse))
+	case "/api/clusters":
+		a.Clusters(rw, req)
+	case "/api/token/keys":
+		keyJSON, err := a.Tokens.GetPublicKeyJSON()
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(keyJSON)
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// WriteJSON writes the value as the JSON response of a stub.
+func WriteJSON(rw http.ResponseWriter, value interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(value); err != nil {
+		log.Errorf("Error writing stub response: %v", err)
+	}
+}
+
+// TokenManager signs and parses the tokens of the stub auth service.
+type TokenManager struct {
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+}
+
+// NewTokenManager creates a token manager signing with the key.
+func NewTokenManager(privateKey *rsa.PrivateKey) *TokenManager {
+	return &TokenManager{privateKey: privateKey, publicKey: &privateKey.PublicKey}
+}
+
+// SignToken returns the token of the claims signed by the token manager.
+func (t *TokenManager) SignToken(c jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	token.Header["kid"] = keyID
+	return token.SignedString(t.privateKey)
+}
+
+// ParseToken parses the token, returning an error unless it is signed by the token manager.
+func (t *TokenManager) ParseToken(tokenStr string) (*jwt.Token, error) {
+	return jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		return t.publicKey, nil
+	})
+}
+
+// GetPublicKeyJSON returns the auth service /token/keys response holding the key of the token manager.
+func (t *TokenManager) GetPublicKeyJSON() ([]byte, error) {
+	jsonKey, err := toJSONWebKeys(keyID, t.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonKey)
+}
+
+type jsonKeys struct {
+	Keys []interface{} `json:"keys"`
+}
+
+func toJSONWebKeys(keyID string, key *rsa.PublicKey) (*jsonKeys, error) {
+	var result []interface{}
+	jwkey := jose.JsonWebKey{Key: key, KeyID: keyID, Algorithm: "RS256", Use: "sig"}
+	keyData, err := jwkey.MarshalJSON()
+	if err != nil {
+		return &jsonKeys{}, err
+	}
+	var raw interface{}
+	err = json.Unmarshal(keyData, &raw)
+	if err != nil {
+		return &jsonKeys{}, err
+	}
+	result = append(result, raw)
+	return &jsonKeys{Keys: result}, nil
+}
@@ -17,6 +17,8 @@ import (
 	"github.com/containous/traefik/cmd"
 	"github.com/containous/traefik/cmd/bug"
 	"github.com/containous/traefik/cmd/healthcheck"
+	"github.com/containous/traefik/cmd/osioresolve"
+	"github.com/containous/traefik/cmd/osiosandbox"
 	"github.com/containous/traefik/cmd/storeconfig"
 	cmdVersion "github.com/containous/traefik/cmd/version"
 	"github.com/containous/traefik/collector"
@@ -79,6 +81,8 @@ Complete documentation is available at https://traefik.io`,
 	f.AddCommand(bug.NewCmd(traefikConfiguration, traefikPointersConfiguration))
 	f.AddCommand(storeConfigCmd)
 	f.AddCommand(healthcheck.NewCmd(traefikConfiguration, traefikPointersConfiguration))
+	f.AddCommand(osiosandbox.NewCmd())
+	f.AddCommand(osioresolve.NewCmd())
 
 	usedCmd, err := f.GetCommand()
 	if err != nil {
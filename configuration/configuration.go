@@ -520,6 +520,8 @@ type RespondingTimeouts struct {
 type ForwardingTimeouts struct {
 	DialTimeout           flaeg.Duration `description:"The amount of time to wait until a connection to a backend server can be established. Defaults to 30 seconds. If zero, no timeout exists" export:"true"`
 	ResponseHeaderTimeout flaeg.Duration `description:"The amount of time to wait for a server's response headers after fully writing the request (including its body, if any). If zero, no timeout exists" export:"true"`
+	UpgradeIdleTimeout    flaeg.Duration `description:"The amount of time an upgraded connection (SPDY, WebSocket, ...) may stay without traffic before it is closed. If zero, no timeout exists" export:"true"`
+	UpgradeTimeout        flaeg.Duration `description:"The maximum lifetime of an upgraded connection (SPDY, WebSocket, ...). If zero, no timeout exists" export:"true"`
 }
 
 // LifeCycle contains configurations relevant to the lifecycle (such as the
@@ -20,6 +20,7 @@ type EntryPoint struct {
 	Compress             bool              `export:"true"`
 	ProxyProtocol        *ProxyProtocol    `export:"true"`
 	ForwardedHeaders     *ForwardedHeaders `export:"true"`
+	CORS                 *types.CORS       `export:"true"`
 }
 
 // ProxyProtocol contains Proxy-Protocol configuration
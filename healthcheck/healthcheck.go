@@ -2,16 +2,19 @@ package healthcheck
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
 	"github.com/go-kit/kit/metrics"
 	"github.com/vulcand/oxy/roundrobin"
 )
@@ -27,17 +30,47 @@ func GetHealthCheck(metrics metricsRegistry) *HealthCheck {
 	return singleton
 }
 
+// DefaultRequestTimeout is the default timeout of the health check requests.
+const DefaultRequestTimeout = 5 * time.Second
+
 // Options are the public health check options.
 type Options struct {
 	Path      string
 	Port      int
 	Transport http.RoundTripper
 	Interval  time.Duration
-	LB        LoadBalancer
+	// Timeout of the health check requests, DefaultRequestTimeout when zero
+	Timeout time.Duration
+	// Method of the health check requests, GET when empty
+	Method string
+	// Hostname overrides the Host header of the health check requests
+	Hostname string
+	Headers  map[string]string
+	// Status are the status codes of a healthy server, 200 only when empty
+	Status types.HTTPCodeRanges
+	// HealthyThreshold and UnhealthyThreshold are the consecutive checks changing the status of a server, 1 when zero
+	HealthyThreshold   int
+	UnhealthyThreshold int
+	// TLSServerName overrides the server name verified by the TLS health checks
+	TLSServerName string
+	LB            LoadBalancer
+	// Passive is the passive health check of the backend, if any, the active one being disabled without Path
+	Passive *PassiveHealthCheck
 }
 
 func (opt Options) String() string {
-	return fmt.Sprintf("[Path: %s Port: %d Interval: %s]", opt.Path, opt.Port, opt.Interval)
+	// the header values, which may be credentials, are not logged
+	var headers []string
+	for name := range opt.Headers {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	active := fmt.Sprintf("Path: %s Port: %d Interval: %s Timeout: %s Method: %s Hostname: %s Headers: %v Status: %v HealthyThreshold: %d UnhealthyThreshold: %d TLSServerName: %s",
+		opt.Path, opt.Port, opt.Interval, opt.Timeout, opt.Method, opt.Hostname, headers, opt.Status, opt.HealthyThreshold, opt.UnhealthyThreshold, opt.TLSServerName)
+	if opt.Passive != nil {
+		return fmt.Sprintf("[%s Passive: %s]", active, opt.Passive.PassiveOptions)
+	}
+	return fmt.Sprintf("[%s]", active)
 }
 
 // BackendHealthCheck HealthCheck configuration for a backend
@@ -46,13 +79,41 @@ type BackendHealthCheck struct {
 	name           string
 	disabledURLs   []*url.URL
 	requestTimeout time.Duration
+
+	mux     sync.Mutex
+	servers map[string]*serverState
 }
 
+// serverState is the active health check state of a server.
+type serverState struct {
+	up        bool
+	successes int
+	failures  int
+	lastError string
+	// lastTransition is the time of the last status change, or of the first check
+	lastTransition time.Time
+}
+
+// ServerStatus is the active health check status of a server.
+type ServerStatus struct {
+	URL            string    `json:"url"`
+	Status         string    `json:"status"`
+	LastError      string    `json:"lastError,omitempty"`
+	LastTransition time.Time `json:"lastTransition"`
+}
+
+// Server statuses.
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
 //HealthCheck struct
 type HealthCheck struct {
 	Backends map[string]*BackendHealthCheck
 	metrics  metricsRegistry
 	cancel   context.CancelFunc
+	mux      sync.RWMutex
 }
 
 // LoadBalancer includes functionality for load-balancing management.
@@ -77,16 +138,71 @@ type metricsRegistry interface {
 
 // NewBackendHealthCheck Instantiate a new BackendHealthCheck
 func NewBackendHealthCheck(options Options, backendName string) *BackendHealthCheck {
+	requestTimeout := options.Timeout
+	if requestTimeout <= 0 {
+		requestTimeout = DefaultRequestTimeout
+	}
+	if options.Method == "" {
+		options.Method = http.MethodGet
+	}
+	if options.HealthyThreshold <= 0 {
+		options.HealthyThreshold = 1
+	}
+	if options.UnhealthyThreshold <= 0 {
+		options.UnhealthyThreshold = 1
+	}
+	if options.TLSServerName != "" {
+		options.Transport = withTLSServerName(options.Transport, options.TLSServerName, backendName)
+	}
 	return &BackendHealthCheck{
 		Options:        options,
 		name:           backendName,
-		requestTimeout: 5 * time.Second,
+		requestTimeout: requestTimeout,
+		servers:        make(map[string]*serverState),
+	}
+}
+
+// withTLSServerName returns a transport with the settings of the given one, verifying the given server name.
+// The health checks of the returned transport are sent with HTTP/1.1.
+func withTLSServerName(rt http.RoundTripper, serverName string, backendName string) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		log.Warnf("Health check TLS server name ignored, unsupported transport %T. Backend: %q", rt, backendName)
+		return rt
+	}
+	tlsConfig := &tls.Config{}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	}
+	tlsConfig.ServerName = serverName
+	// HTTP/2 may have been configured on the given transport only
+	tlsConfig.NextProtos = nil
+	return &http.Transport{
+		Proxy:                  transport.Proxy,
+		DialContext:            transport.DialContext,
+		Dial:                   transport.Dial,
+		TLSClientConfig:        tlsConfig,
+		TLSHandshakeTimeout:    transport.TLSHandshakeTimeout,
+		DisableKeepAlives:      transport.DisableKeepAlives,
+		DisableCompression:     transport.DisableCompression,
+		MaxIdleConns:           transport.MaxIdleConns,
+		MaxIdleConnsPerHost:    transport.MaxIdleConnsPerHost,
+		IdleConnTimeout:        transport.IdleConnTimeout,
+		ResponseHeaderTimeout:  transport.ResponseHeaderTimeout,
+		ExpectContinueTimeout:  transport.ExpectContinueTimeout,
+		ProxyConnectHeader:     transport.ProxyConnectHeader,
+		MaxResponseHeaderBytes: transport.MaxResponseHeaderBytes,
 	}
 }
 
 //SetBackendsConfiguration set backends configuration
 func (hc *HealthCheck) SetBackendsConfiguration(parentCtx context.Context, backends map[string]*BackendHealthCheck) {
+	hc.mux.Lock()
 	hc.Backends = backends
+	hc.mux.Unlock()
 	if hc.cancel != nil {
 		hc.cancel()
 	}
@@ -102,6 +218,17 @@ func (hc *HealthCheck) SetBackendsConfiguration(parentCtx context.Context, backe
 }
 
 func (hc *HealthCheck) execute(ctx context.Context, backend *BackendHealthCheck) {
+	if backend.Passive != nil {
+		probe := func(u *url.URL) error { return probeServer(u, backend) }
+		if backend.Path != "" {
+			probe = func(u *url.URL) error { return checkHealth(u, backend) }
+		}
+		backend.Passive.start(ctx, backend.LB, probe, hc.metrics)
+	}
+	if backend.Path == "" {
+		return
+	}
+
 	log.Debugf("Initial health check for backend: %q", backend.name)
 	hc.checkBackend(backend)
 	ticker := time.NewTicker(backend.Interval)
@@ -118,18 +245,57 @@ func (hc *HealthCheck) execute(ctx context.Context, backend *BackendHealthCheck)
 	}
 }
 
+// Status returns the status of the servers of the backends with an active health check, by backend name.
+func (hc *HealthCheck) Status() map[string][]ServerStatus {
+	hc.mux.RLock()
+	defer hc.mux.RUnlock()
+	status := make(map[string][]ServerStatus)
+	for _, backend := range hc.Backends {
+		if backend.Path == "" {
+			continue
+		}
+		status[backend.name] = append(status[backend.name], backend.status()...)
+	}
+	return status
+}
+
+func (backend *BackendHealthCheck) status() []ServerStatus {
+	backend.mux.Lock()
+	defer backend.mux.Unlock()
+	var status []ServerStatus
+	for u, server := range backend.servers {
+		s := ServerStatus{
+			URL:            u,
+			Status:         StatusDown,
+			LastError:      server.lastError,
+			LastTransition: server.lastTransition,
+		}
+		if server.up {
+			s.Status = StatusUp
+		}
+		status = append(status, s)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].URL < status[j].URL })
+	return status
+}
+
 func (hc *HealthCheck) checkBackend(backend *BackendHealthCheck) {
 	enabledURLs := backend.LB.Servers()
 	var newDisabledURLs []*url.URL
 	for _, url := range backend.disabledURLs {
 		serverUpMetricValue := float64(0)
-		if err := checkHealth(url, backend); err == nil {
+		err := checkHealth(url, backend)
+		switch {
+		case backend.record(url, false, err):
 			log.Warnf("Health check up: Returning to server list. Backend: %q URL: %q", backend.name, url.String())
 			backend.LB.UpsertServer(url, roundrobin.Weight(1))
 			serverUpMetricValue = 1
-		} else {
+		case err != nil:
 			log.Warnf("Health check still failing. Backend: %q URL: %q Reason: %s", backend.name, url.String(), err)
 			newDisabledURLs = append(newDisabledURLs, url)
+		default:
+			log.Debugf("Health check up, below the healthy threshold. Backend: %q URL: %q", backend.name, url.String())
+			newDisabledURLs = append(newDisabledURLs, url)
 		}
 		labelValues := []string{"backend", backend.name, "url", url.String()}
 		hc.metrics.BackendServerUpGauge().With(labelValues...).Set(serverUpMetricValue)
@@ -138,29 +304,79 @@ func (hc *HealthCheck) checkBackend(backend *BackendHealthCheck) {
 
 	for _, url := range enabledURLs {
 		serverUpMetricValue := float64(1)
-		if err := checkHealth(url, backend); err != nil {
+		err := checkHealth(url, backend)
+		switch {
+		case backend.record(url, true, err):
 			log.Warnf("Health check failed: Remove from server list. Backend: %q URL: %q Reason: %s", backend.name, url.String(), err)
 			backend.LB.RemoveServer(url)
 			backend.disabledURLs = append(backend.disabledURLs, url)
 			serverUpMetricValue = 0
+		case err != nil:
+			log.Warnf("Health check failed, below the unhealthy threshold. Backend: %q URL: %q Reason: %s", backend.name, url.String(), err)
 		}
 		labelValues := []string{"backend", backend.name, "url", url.String()}
 		hc.metrics.BackendServerUpGauge().With(labelValues...).Set(serverUpMetricValue)
 	}
 }
 
-func (backend *BackendHealthCheck) newRequest(serverURL *url.URL) (*http.Request, error) {
-	if backend.Port == 0 {
-		return http.NewRequest(http.MethodGet, serverURL.String()+backend.Path, nil)
+// record records the result of the check of a server, up when it is in the load balancer,
+// and tells whether the server reached the threshold changing its status.
+func (backend *BackendHealthCheck) record(u *url.URL, up bool, err error) bool {
+	backend.mux.Lock()
+	defer backend.mux.Unlock()
+	server, ok := backend.servers[u.String()]
+	if !ok || server.up != up {
+		// new server, or status changed out of the health check (e.g. by the passive one)
+		server = &serverState{up: up, lastTransition: time.Now()}
+		backend.servers[u.String()] = server
+	}
+
+	if err != nil {
+		server.lastError = err.Error()
+		server.successes = 0
+		server.failures++
+	} else {
+		server.successes++
+		server.failures = 0
+	}
+	switch {
+	case up && server.failures >= backend.UnhealthyThreshold,
+		!up && server.successes >= backend.HealthyThreshold:
+		server.up = !up
+		server.successes = 0
+		server.failures = 0
+		server.lastTransition = time.Now()
+		return true
 	}
+	return false
+}
 
-	// copy the url and add the port to the host
-	u := &url.URL{}
-	*u = *serverURL
-	u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(backend.Port))
-	u.Path = u.Path + backend.Path
+func (backend *BackendHealthCheck) newRequest(serverURL *url.URL) (*http.Request, error) {
+	u := serverURL.String() + backend.Path
+	if backend.Port != 0 {
+		// copy the url and add the port to the host
+		portURL := &url.URL{}
+		*portURL = *serverURL
+		portURL.Host = net.JoinHostPort(portURL.Hostname(), strconv.Itoa(backend.Port))
+		portURL.Path = portURL.Path + backend.Path
+		u = portURL.String()
+	}
 
-	return http.NewRequest(http.MethodGet, u.String(), nil)
+	method := backend.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if backend.Hostname != "" {
+		req.Host = backend.Hostname
+	}
+	for name, value := range backend.Headers {
+		req.Header.Set(name, value)
+	}
+	return req, nil
 }
 
 // checkHealth returns a nil error in case it was successful and otherwise
@@ -183,8 +399,10 @@ func checkHealth(serverURL *url.URL, backend *BackendHealthCheck) error {
 	switch {
 	case err != nil:
 		return fmt.Errorf("HTTP request failed: %s", err)
-	case resp.StatusCode != http.StatusOK:
+	case len(backend.Status) == 0 && resp.StatusCode != http.StatusOK:
 		return fmt.Errorf("received non-200 status code: %v", resp.StatusCode)
+	case len(backend.Status) > 0 && !backend.Status.Contains(resp.StatusCode):
+		return fmt.Errorf("received unexpected status code: %v", resp.StatusCode)
 	}
 	return nil
 }
@@ -2,6 +2,7 @@ package healthcheck
 
 import (
 	"context"
+	"crypto/tls"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -10,6 +11,9 @@ import (
 	"time"
 
 	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/vulcand/oxy/roundrobin"
 )
 
@@ -270,3 +274,196 @@ func (th *testHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		th.done()
 	}
 }
+
+func TestNewRequestOptions(t *testing.T) {
+	backend := NewBackendHealthCheck(
+		Options{
+			Path:     "/healthz",
+			Method:   http.MethodHead,
+			Hostname: "cluster.example.com",
+			Headers:  map[string]string{"Authorization": "Bearer token", "X-Check": "true"},
+		}, "backendName")
+
+	req, err := backend.newRequest(testhelpers.MustParseURL("http://10.0.0.1:8443"))
+	if err != nil {
+		t.Fatalf("failed to create new backend request: %s", err)
+	}
+
+	if req.Method != http.MethodHead {
+		t.Errorf("got %s method, want %s", req.Method, http.MethodHead)
+	}
+	if req.URL.String() != "http://10.0.0.1:8443/healthz" {
+		t.Errorf("got %s for healthcheck URL, want http://10.0.0.1:8443/healthz", req.URL)
+	}
+	if req.Host != "cluster.example.com" {
+		t.Errorf("got %s host, want cluster.example.com", req.Host)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("got %q Authorization header, want %q", got, "Bearer token")
+	}
+	if got := req.Header.Get("X-Check"); got != "true" {
+		t.Errorf("got %q X-Check header, want %q", got, "true")
+	}
+}
+
+func TestCheckHealthStatus(t *testing.T) {
+	tests := []struct {
+		desc        string
+		status      []string
+		statusCode  int
+		wantHealthy bool
+	}{
+		{
+			desc:        "default status",
+			statusCode:  http.StatusOK,
+			wantHealthy: true,
+		},
+		{
+			desc:        "default status with no content",
+			statusCode:  http.StatusNoContent,
+			wantHealthy: false,
+		},
+		{
+			desc:        "status range",
+			status:      []string{"200-299"},
+			statusCode:  http.StatusNoContent,
+			wantHealthy: true,
+		},
+		{
+			desc:        "several statuses",
+			status:      []string{"200", "401"},
+			statusCode:  http.StatusUnauthorized,
+			wantHealthy: true,
+		},
+		{
+			desc:        "status out of the ranges",
+			status:      []string{"200-299"},
+			statusCode:  http.StatusServiceUnavailable,
+			wantHealthy: false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.statusCode)
+			}))
+			defer ts.Close()
+
+			status, err := types.NewHTTPCodeRanges(test.status)
+			if err != nil {
+				t.Fatal(err)
+			}
+			backend := NewBackendHealthCheck(Options{Path: "/path", Status: status}, "backendName")
+
+			err = checkHealth(testhelpers.MustParseURL(ts.URL), backend)
+			if healthy := err == nil; healthy != test.wantHealthy {
+				t.Errorf("got healthy %t (%v), want %t", healthy, err, test.wantHealthy)
+			}
+		})
+	}
+}
+
+func TestCheckHealthTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	backend := NewBackendHealthCheck(Options{Path: "/path", Timeout: 50 * time.Millisecond}, "backendName")
+	if err := checkHealth(testhelpers.MustParseURL(ts.URL), backend); err == nil {
+		t.Error("got no error, want a timeout")
+	}
+}
+
+func TestCheckBackendThresholds(t *testing.T) {
+	var healthy bool
+	var mux sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	setHealthy := func(value bool) {
+		mux.Lock()
+		defer mux.Unlock()
+		healthy = value
+	}
+
+	serverURL := testhelpers.MustParseURL(ts.URL)
+	lb := &testLoadBalancer{RWMutex: &sync.RWMutex{}, servers: []*url.URL{serverURL}}
+	backend := NewBackendHealthCheck(Options{
+		Path:               "/path",
+		HealthyThreshold:   3,
+		UnhealthyThreshold: 2,
+		LB:                 lb,
+	}, "backendName")
+	check := HealthCheck{
+		Backends: map[string]*BackendHealthCheck{"backendName": backend},
+		metrics:  testhelpers.NewCollectingHealthCheckMetrics(),
+	}
+
+	steps := []struct {
+		healthy     bool
+		wantRemoved int
+		wantUpsert  int
+		wantStatus  string
+	}{
+		{healthy: false, wantRemoved: 0, wantUpsert: 0, wantStatus: StatusUp},
+		{healthy: false, wantRemoved: 1, wantUpsert: 0, wantStatus: StatusDown},
+		{healthy: true, wantRemoved: 1, wantUpsert: 0, wantStatus: StatusDown},
+		{healthy: true, wantRemoved: 1, wantUpsert: 0, wantStatus: StatusDown},
+		{healthy: true, wantRemoved: 1, wantUpsert: 1, wantStatus: StatusUp},
+	}
+	for i, step := range steps {
+		setHealthy(step.healthy)
+		check.checkBackend(backend)
+
+		lb.RLock()
+		removed, upserted := lb.numRemovedServers, lb.numUpsertedServers
+		lb.RUnlock()
+		if removed != step.wantRemoved || upserted != step.wantUpsert {
+			t.Errorf("check %d: got %d removed and %d upserted servers, want %d and %d", i+1, removed, upserted, step.wantRemoved, step.wantUpsert)
+		}
+
+		status := check.Status()["backendName"]
+		if len(status) != 1 {
+			t.Fatalf("check %d: got %d server statuses, want 1", i+1, len(status))
+		}
+		if status[0].URL != ts.URL || status[0].Status != step.wantStatus {
+			t.Errorf("check %d: got %s %s, want %s %s", i+1, status[0].URL, status[0].Status, ts.URL, step.wantStatus)
+		}
+	}
+
+	status := check.Status()["backendName"][0]
+	if status.LastError != "received non-200 status code: 503" {
+		t.Errorf("got last error %q, want the one of the failed checks", status.LastError)
+	}
+	if status.LastTransition.IsZero() {
+		t.Error("got no last transition time")
+	}
+}
+
+func TestWithTLSServerName(t *testing.T) {
+	transport := &http.Transport{
+		ResponseHeaderTimeout: time.Second,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2", "http/1.1"}},
+	}
+
+	rt := withTLSServerName(transport, "backend.example.com", "backend1")
+	healthTransport, ok := rt.(*http.Transport)
+	require.True(t, ok)
+	assert.Equal(t, time.Second, healthTransport.ResponseHeaderTimeout)
+	assert.Equal(t, "backend.example.com", healthTransport.TLSClientConfig.ServerName)
+	assert.True(t, healthTransport.TLSClientConfig.InsecureSkipVerify)
+	assert.Empty(t, healthTransport.TLSClientConfig.NextProtos)
+
+	// the transport of the backend is left untouched
+	assert.Empty(t, transport.TLSClientConfig.ServerName)
+	assert.Equal(t, []string{"h2", "http/1.1"}, transport.TLSClientConfig.NextProtos)
+}
@@ -0,0 +1,291 @@
+package healthcheck
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+const (
+	// DefaultPassiveConsecutiveFailures is the default number of consecutive failures ejecting a server.
+	DefaultPassiveConsecutiveFailures = 5
+	// DefaultPassiveBaseEjectionTime is the default duration of the first ejection of a server.
+	DefaultPassiveBaseEjectionTime = 30 * time.Second
+	// DefaultPassiveMaxEjectionTime is the default maximum duration of an ejection.
+	DefaultPassiveMaxEjectionTime = 5 * time.Minute
+	// DefaultPassiveMaxEjectedPercent is the default maximum percentage of ejected servers of a backend.
+	DefaultPassiveMaxEjectedPercent = 50
+)
+
+// PassiveOptions are the passive health check options.
+type PassiveOptions struct {
+	// ConsecutiveFailures are the consecutive 5xx responses or network errors ejecting a server
+	ConsecutiveFailures int
+	// BaseEjectionTime is doubled at each consecutive ejection of a server, up to MaxEjectionTime
+	BaseEjectionTime  time.Duration
+	MaxEjectionTime   time.Duration
+	MaxEjectedPercent int
+}
+
+func (opt PassiveOptions) String() string {
+	return fmt.Sprintf("[ConsecutiveFailures: %d BaseEjectionTime: %s MaxEjectionTime: %s MaxEjectedPercent: %d]",
+		opt.ConsecutiveFailures, opt.BaseEjectionTime, opt.MaxEjectionTime, opt.MaxEjectedPercent)
+}
+
+// PassiveHealthCheck ejects from the load balancer the servers failing consecutive requests,
+// and re-admits them after a successful probe once their ejection period elapsed.
+type PassiveHealthCheck struct {
+	PassiveOptions
+	name string
+
+	mux     sync.Mutex
+	servers map[string]*passiveServer
+	// set when the health check of the backend starts
+	ctx     context.Context
+	lb      LoadBalancer
+	probe   func(*url.URL) error
+	metrics metricsRegistry
+}
+
+type passiveServer struct {
+	url       *url.URL
+	weight    int
+	failures  int
+	ejected   bool
+	ejections int
+	// readmitted is the time of the last re-admission, the ejections escalating until it is old enough
+	readmitted time.Time
+}
+
+// NewPassiveHealthCheck creates the passive health check of a backend, the defaults applying to the unset options.
+func NewPassiveHealthCheck(options PassiveOptions, backendName string) (*PassiveHealthCheck, error) {
+	if options.ConsecutiveFailures == 0 {
+		options.ConsecutiveFailures = DefaultPassiveConsecutiveFailures
+	}
+	if options.BaseEjectionTime == 0 {
+		options.BaseEjectionTime = DefaultPassiveBaseEjectionTime
+	}
+	if options.MaxEjectionTime == 0 {
+		options.MaxEjectionTime = DefaultPassiveMaxEjectionTime
+	}
+	if options.MaxEjectedPercent == 0 {
+		options.MaxEjectedPercent = DefaultPassiveMaxEjectedPercent
+	}
+	switch {
+	case options.ConsecutiveFailures < 0:
+		return nil, fmt.Errorf("invalid consecutive failures %d", options.ConsecutiveFailures)
+	case options.BaseEjectionTime < 0 || options.MaxEjectionTime < options.BaseEjectionTime:
+		return nil, fmt.Errorf("invalid ejection times %s to %s", options.BaseEjectionTime, options.MaxEjectionTime)
+	case options.MaxEjectedPercent < 0 || options.MaxEjectedPercent > 100:
+		return nil, fmt.Errorf("invalid max ejected percent %d", options.MaxEjectedPercent)
+	}
+
+	return &PassiveHealthCheck{
+		PassiveOptions: options,
+		name:           backendName,
+		servers:        make(map[string]*passiveServer),
+	}, nil
+}
+
+// start enables the ejections from the load balancer, until the context is done.
+func (p *PassiveHealthCheck) start(ctx context.Context, lb LoadBalancer, probe func(*url.URL) error, metrics metricsRegistry) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.ctx = ctx
+	p.lb = lb
+	p.probe = probe
+	p.metrics = metrics
+}
+
+// Handler records the responses of the servers forwarded to by next, the request URL being the server one.
+func (p *PassiveHealthCheck) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		recorder := &passiveResponseWriter{ResponseWriter: rw, status: http.StatusOK}
+		next.ServeHTTP(recorder, req)
+		// the requests canceled by the clients tell nothing about the server
+		if req.Context().Err() == nil {
+			p.record(req.URL, recorder.status >= http.StatusInternalServerError)
+		}
+	})
+}
+
+func (p *PassiveHealthCheck) record(u *url.URL, failed bool) {
+	serverURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}
+	key := serverURL.String()
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	server, ok := p.servers[key]
+	if !ok {
+		if !failed {
+			return
+		}
+		server = &passiveServer{url: serverURL}
+		p.servers[key] = server
+	}
+	// the requests in flight when the server was ejected are ignored
+	if server.ejected {
+		return
+	}
+	if !failed {
+		server.failures = 0
+		return
+	}
+	server.failures++
+	if server.failures < p.ConsecutiveFailures || p.lb == nil || p.ctx.Err() != nil {
+		return
+	}
+	p.eject(server)
+}
+
+// eject removes the server from the load balancer, unless too many servers are already ejected.
+func (p *PassiveHealthCheck) eject(server *passiveServer) {
+	var ejected int
+	for _, s := range p.servers {
+		if s.ejected {
+			ejected++
+		}
+	}
+	remaining := len(p.lb.Servers())
+	if remaining <= 1 || (ejected > 0 && (ejected+1)*100 > (remaining+ejected)*p.MaxEjectedPercent) {
+		log.Warnf("Passive health check failing, too many servers ejected to eject it. Backend: %q URL: %q Failures: %d", p.name, server.url, server.failures)
+		return
+	}
+
+	server.weight = 1
+	if weighted, ok := p.lb.(interface {
+		ServerWeight(u *url.URL) (int, bool)
+	}); ok {
+		if weight, ok := weighted.ServerWeight(server.url); ok {
+			server.weight = weight
+		}
+	}
+	if err := p.lb.RemoveServer(server.url); err != nil {
+		log.Errorf("Passive health check failed to eject server. Backend: %q URL: %q Reason: %s", p.name, server.url, err)
+		return
+	}
+
+	if time.Since(server.readmitted) > p.MaxEjectionTime {
+		server.ejections = 0
+	}
+	server.ejected = true
+	server.ejections++
+	ejection := p.ejectionTime(server.ejections)
+	log.Warnf("Passive health check failed: Remove from server list. Backend: %q URL: %q Failures: %d Ejection: %s", p.name, server.url, server.failures, ejection)
+	p.setServerUp(server, 0)
+	p.scheduleProbe(server, ejection)
+}
+
+func (p *PassiveHealthCheck) ejectionTime(ejections int) time.Duration {
+	ejection := p.MaxEjectionTime
+	if ejections <= 32 {
+		if d := p.BaseEjectionTime << uint(ejections-1); d > 0 && d < ejection {
+			ejection = d
+		}
+	}
+	return ejection
+}
+
+func (p *PassiveHealthCheck) scheduleProbe(server *passiveServer, ejection time.Duration) {
+	ctx := p.ctx
+	time.AfterFunc(ejection, func() {
+		if ctx.Err() != nil {
+			return
+		}
+		err := p.probe(server.url)
+
+		p.mux.Lock()
+		defer p.mux.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			server.ejections++
+			ejection := p.ejectionTime(server.ejections)
+			log.Warnf("Passive health check probe still failing. Backend: %q URL: %q Reason: %s Ejection: %s", p.name, server.url, err, ejection)
+			p.scheduleProbe(server, ejection)
+			return
+		}
+		if err := p.lb.UpsertServer(server.url, roundrobin.Weight(server.weight)); err != nil {
+			log.Errorf("Passive health check failed to re-admit server. Backend: %q URL: %q Reason: %s", p.name, server.url, err)
+			p.scheduleProbe(server, p.ejectionTime(server.ejections))
+			return
+		}
+		log.Warnf("Passive health check probe up: Returning to server list. Backend: %q URL: %q", p.name, server.url)
+		server.ejected = false
+		server.failures = 0
+		server.readmitted = time.Now()
+		p.setServerUp(server, 1)
+	})
+}
+
+func (p *PassiveHealthCheck) setServerUp(server *passiveServer, value float64) {
+	if p.metrics != nil {
+		p.metrics.BackendServerUpGauge().With("backend", p.name, "url", server.url.String()).Set(value)
+	}
+}
+
+// probeServer checks that a server, without health check path, answers requests without server error.
+func probeServer(serverURL *url.URL, backend *BackendHealthCheck) error {
+	client := http.Client{
+		Timeout:   backend.requestTimeout,
+		Transport: backend.Options.Transport,
+	}
+	resp, err := client.Get(serverURL.String() + "/")
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("received server error status code: %v", resp.StatusCode)
+	}
+	return nil
+}
+
+type passiveResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rw *passiveResponseWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.status = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *passiveResponseWriter) Write(buf []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(buf)
+}
+
+func (rw *passiveResponseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rw *passiveResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T is not a http.Hijacker", rw.ResponseWriter)
+	}
+	return hijacker.Hijack()
+}
+
+func (rw *passiveResponseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := rw.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
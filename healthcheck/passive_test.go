@@ -0,0 +1,144 @@
+package healthcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+// switchableServer answers 200 when healthy, 503 otherwise.
+func switchableServer(healthy *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.LoadInt32(healthy) == 0 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+}
+
+// forwardStatus forwards the request to the server of its URL, the load balancer one, and writes its status.
+var forwardStatus = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+	resp, err := http.Get(req.URL.String())
+	if err != nil {
+		rw.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	resp.Body.Close()
+	rw.WriteHeader(resp.StatusCode)
+})
+
+func waitFor(t *testing.T, desc string, condition func() bool) {
+	for deadline := time.Now().Add(2 * time.Second); !condition(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", desc)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func sendRequests(lb http.Handler, count int) {
+	for i := 0; i < count; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://backend/api", nil))
+	}
+}
+
+func TestPassiveHealthCheck(t *testing.T) {
+	var failingHealthy, healthy int32 = 0, 1
+	failing := switchableServer(&failingHealthy)
+	defer failing.Close()
+	other := switchableServer(&healthy)
+	defer other.Close()
+	failingURL := testhelpers.MustParseURL(failing.URL)
+
+	passive, err := NewPassiveHealthCheck(PassiveOptions{ConsecutiveFailures: 2, BaseEjectionTime: 50 * time.Millisecond}, "backend")
+	require.NoError(t, err)
+	lb, err := roundrobin.New(passive.Handler(forwardStatus))
+	require.NoError(t, err)
+	require.NoError(t, lb.UpsertServer(failingURL, roundrobin.Weight(3)))
+	require.NoError(t, lb.UpsertServer(testhelpers.MustParseURL(other.URL), roundrobin.Weight(1)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	metrics := testhelpers.NewCollectingHealthCheckMetrics()
+	check := HealthCheck{Backends: make(map[string]*BackendHealthCheck), metrics: metrics}
+	check.execute(ctx, NewBackendHealthCheck(Options{LB: lb, Passive: passive}, "backend"))
+
+	// 3 requests of 4 reach the failing server
+	sendRequests(lb, 4)
+	require.Len(t, lb.Servers(), 1, "failing server ejected")
+	assert.Equal(t, other.URL, lb.Servers()[0].String())
+	assert.Equal(t, float64(0), metrics.Gauge.GaugeValue)
+
+	// the remaining server is never ejected
+	atomic.StoreInt32(&healthy, 0)
+	sendRequests(lb, 4)
+	require.Len(t, lb.Servers(), 1)
+	atomic.StoreInt32(&healthy, 1)
+
+	atomic.StoreInt32(&failingHealthy, 1)
+	waitFor(t, "the re-admission", func() bool {
+		passive.mux.Lock()
+		defer passive.mux.Unlock()
+		return len(lb.Servers()) == 2
+	})
+	weight, ok := lb.ServerWeight(failingURL)
+	require.True(t, ok)
+	assert.Equal(t, 3, weight)
+	assert.Equal(t, float64(1), metrics.Gauge.GaugeValue)
+}
+
+func TestPassiveHealthCheckProbeFailing(t *testing.T) {
+	passive, err := NewPassiveHealthCheck(PassiveOptions{ConsecutiveFailures: 1, BaseEjectionTime: 10 * time.Millisecond, MaxEjectionTime: 40 * time.Millisecond}, "backend")
+	require.NoError(t, err)
+	lb := &testLoadBalancer{RWMutex: &sync.RWMutex{}}
+	failingURL := testhelpers.MustParseURL("http://10.0.0.1:80")
+	lb.servers = []*url.URL{failingURL, testhelpers.MustParseURL("http://10.0.0.2:80")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	passive.start(ctx, lb, func(u *url.URL) error {
+		return assert.AnError
+	}, testhelpers.NewCollectingHealthCheckMetrics())
+
+	passive.record(failingURL, true)
+	// escalated at each failed probe, up to the max ejection time
+	waitFor(t, "3 failed probes", func() bool {
+		passive.mux.Lock()
+		defer passive.mux.Unlock()
+		return passive.servers[failingURL.String()].ejections == 4
+	})
+
+	lb.Lock()
+	defer lb.Unlock()
+	assert.Equal(t, 1, lb.numRemovedServers)
+	assert.Equal(t, 0, lb.numUpsertedServers)
+}
+
+func TestPassiveEjectionTime(t *testing.T) {
+	passive, err := NewPassiveHealthCheck(PassiveOptions{}, "backend")
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultPassiveBaseEjectionTime, passive.ejectionTime(1))
+	assert.Equal(t, 2*DefaultPassiveBaseEjectionTime, passive.ejectionTime(2))
+	assert.Equal(t, 4*DefaultPassiveBaseEjectionTime, passive.ejectionTime(3))
+	assert.Equal(t, DefaultPassiveMaxEjectionTime, passive.ejectionTime(5))
+	assert.Equal(t, DefaultPassiveMaxEjectionTime, passive.ejectionTime(100))
+
+	for desc, options := range map[string]PassiveOptions{
+		"failures": {ConsecutiveFailures: -1},
+		"times":    {BaseEjectionTime: time.Minute, MaxEjectionTime: time.Second},
+		"percent":  {MaxEjectedPercent: 101},
+	} {
+		_, err := NewPassiveHealthCheck(options, "backend")
+		assert.Error(t, err, desc)
+	}
+}
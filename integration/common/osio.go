@@ -2,7 +2,6 @@ package common
 
 import (
 	"crypto/rsa"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -11,9 +10,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/containous/traefik/cmd/osiosandbox/stubs"
 	"github.com/containous/traefik/log"
 	jwt "github.com/dgrijalva/jwt-go"
-	jose "gopkg.in/square/go-jose.v1"
 )
 
 const (
@@ -21,8 +20,6 @@ const (
 	AuthURL   = "http://127.0.0.1:9091/api"
 )
 
-var keyID = "test-key"
-
 var TestTokenManager = NewTestTokenManager()
 
 func StartServer(port int, handler func(w http.ResponseWriter, r *http.Request)) (ts *httptest.Server) {
@@ -109,19 +106,13 @@ func ServeTenantRequest(rw http.ResponseWriter, req *http.Request) {
 }
 
 func ServerAuthRequest(serverClusterAPI func() string) func(rw http.ResponseWriter, req *http.Request) {
-	return func(rw http.ResponseWriter, req *http.Request) {
-		path := req.URL.Path
-		if path == "/api/token" {
-			tokenAPIResponse := `{"access_token": "1111","token_type": "bearer"}`
-			rw.Write([]byte(tokenAPIResponse))
-		} else if path == "/api/clusters" {
-			clustersAPIResponse := serverClusterAPI()
-			rw.Write([]byte(clustersAPIResponse))
-		} else if path == "/api/token/keys" {
-			keyJSON, _ := TestTokenManager.GetPublicKeyJSON()
-			rw.Write(keyJSON)
-		}
+	auth := &stubs.AuthStub{
+		Tokens: TestTokenManager.TokenManager,
+		Clusters: func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(serverClusterAPI()))
+		},
 	}
+	return auth.ServeHTTP
 }
 
 func TwoClusterData() string {
@@ -165,20 +156,16 @@ func OneClusterData() string {
 }
 
 type testTokenManager struct {
-	privateKey *rsa.PrivateKey
-	publicKey  *rsa.PublicKey
+	*stubs.TokenManager
 }
 
 func NewTestTokenManager() *testTokenManager {
 	priKey := loadRSAPrivateKeyFromDisk("./common/sample_key")
-	pubKey := loadRSAPublicKeyFromDisk("./common/sample_key.pub")
-	return &testTokenManager{privateKey: priKey, publicKey: pubKey}
+	return &testTokenManager{TokenManager: stubs.NewTokenManager(priKey)}
 }
 
 func (t *testTokenManager) ToTokenString(c jwt.Claims) string {
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
-	token.Header["kid"] = keyID
-	s, e := token.SignedString(t.privateKey)
+	s, e := t.SignToken(c)
 	if e != nil {
 		panic(e.Error())
 	}
@@ -186,20 +173,13 @@ func (t *testTokenManager) ToTokenString(c jwt.Claims) string {
 }
 
 func (t *testTokenManager) ToJwtToken(tokenStr string) *jwt.Token {
-	jwtToken, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return t.publicKey, nil
-	})
+	jwtToken, err := t.ParseToken(tokenStr)
 	if err != nil {
 		panic(err)
 	}
 	return jwtToken
 }
 
-func (t *testTokenManager) GetPublicKeyJSON() ([]byte, error) {
-	jsonKey, _ := toJSONWebKeys(keyID, t.publicKey)
-	return json.Marshal(jsonKey)
-}
-
 func loadRSAPrivateKeyFromDisk(location string) *rsa.PrivateKey {
 	keyData, e := ioutil.ReadFile(location)
 	if e != nil {
@@ -211,35 +191,3 @@ func loadRSAPrivateKeyFromDisk(location string) *rsa.PrivateKey {
 	}
 	return key
 }
-
-func loadRSAPublicKeyFromDisk(location string) *rsa.PublicKey {
-	keyData, e := ioutil.ReadFile(location)
-	if e != nil {
-		panic(e.Error())
-	}
-	key, e := jwt.ParseRSAPublicKeyFromPEM(keyData)
-	if e != nil {
-		panic(e.Error())
-	}
-	return key
-}
-
-type JSONKeys struct {
-	Keys []interface{} `json:"keys"`
-}
-
-func toJSONWebKeys(keyID string, key *rsa.PublicKey) (*JSONKeys, error) {
-	var result []interface{}
-	jwkey := jose.JsonWebKey{Key: key, KeyID: keyID, Algorithm: "RS256", Use: "sig"}
-	keyData, err := jwkey.MarshalJSON()
-	if err != nil {
-		return &JSONKeys{}, err
-	}
-	var raw interface{}
-	err = json.Unmarshal(keyData, &raw)
-	if err != nil {
-		return &JSONKeys{}, err
-	}
-	result = append(result, raw)
-	return &JSONKeys{Keys: result}, nil
-}
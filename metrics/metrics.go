@@ -26,6 +26,18 @@ type Registry interface {
 	BackendOpenConnsGauge() metrics.Gauge
 	BackendRetriesCounter() metrics.Counter
 	BackendServerUpGauge() metrics.Gauge
+	BackendUpgradedConnsGauge() metrics.Gauge
+	BackendUpgradedConnDurationHistogram() metrics.Histogram
+	BackendMirrorReqsCounter() metrics.Counter
+	BackendMirrorReqDurationHistogram() metrics.Histogram
+
+	// frontend metrics
+	FrontendSplitReqsCounter() metrics.Counter
+	FrontendCacheReqsCounter() metrics.Counter
+
+	// osio metrics
+	OSIOTokenDecryptionsCounter() metrics.Counter
+	OSIORequestsCounter() metrics.Counter
 }
 
 // NewVoidRegistry is a noop implementation of metrics.Registry.
@@ -50,6 +62,14 @@ func NewMultiRegistry(registries []Registry) Registry {
 	backendOpenConnsGauge := []metrics.Gauge{}
 	backendRetriesCounter := []metrics.Counter{}
 	backendServerUpGauge := []metrics.Gauge{}
+	backendUpgradedConnsGauge := []metrics.Gauge{}
+	backendUpgradedConnDurationHistogram := []metrics.Histogram{}
+	backendMirrorReqsCounter := []metrics.Counter{}
+	backendMirrorReqDurationHistogram := []metrics.Histogram{}
+	frontendSplitReqsCounter := []metrics.Counter{}
+	frontendCacheReqsCounter := []metrics.Counter{}
+	osioTokenDecryptionsCounter := []metrics.Counter{}
+	osioRequestsCounter := []metrics.Counter{}
 
 	for _, r := range registries {
 		if r.ConfigReloadsCounter() != nil {
@@ -88,39 +108,79 @@ func NewMultiRegistry(registries []Registry) Registry {
 		if r.BackendServerUpGauge() != nil {
 			backendServerUpGauge = append(backendServerUpGauge, r.BackendServerUpGauge())
 		}
+		if r.BackendUpgradedConnsGauge() != nil {
+			backendUpgradedConnsGauge = append(backendUpgradedConnsGauge, r.BackendUpgradedConnsGauge())
+		}
+		if r.BackendUpgradedConnDurationHistogram() != nil {
+			backendUpgradedConnDurationHistogram = append(backendUpgradedConnDurationHistogram, r.BackendUpgradedConnDurationHistogram())
+		}
+		if r.BackendMirrorReqsCounter() != nil {
+			backendMirrorReqsCounter = append(backendMirrorReqsCounter, r.BackendMirrorReqsCounter())
+		}
+		if r.BackendMirrorReqDurationHistogram() != nil {
+			backendMirrorReqDurationHistogram = append(backendMirrorReqDurationHistogram, r.BackendMirrorReqDurationHistogram())
+		}
+		if r.FrontendSplitReqsCounter() != nil {
+			frontendSplitReqsCounter = append(frontendSplitReqsCounter, r.FrontendSplitReqsCounter())
+		}
+		if r.FrontendCacheReqsCounter() != nil {
+			frontendCacheReqsCounter = append(frontendCacheReqsCounter, r.FrontendCacheReqsCounter())
+		}
+		if r.OSIOTokenDecryptionsCounter() != nil {
+			osioTokenDecryptionsCounter = append(osioTokenDecryptionsCounter, r.OSIOTokenDecryptionsCounter())
+		}
+		if r.OSIORequestsCounter() != nil {
+			osioRequestsCounter = append(osioRequestsCounter, r.OSIORequestsCounter())
+		}
 	}
 
 	return &standardRegistry{
-		enabled:                        len(registries) > 0,
-		configReloadsCounter:           multi.NewCounter(configReloadsCounter...),
-		configReloadsFailureCounter:    multi.NewCounter(configReloadsFailureCounter...),
-		lastConfigReloadSuccessGauge:   multi.NewGauge(lastConfigReloadSuccessGauge...),
-		lastConfigReloadFailureGauge:   multi.NewGauge(lastConfigReloadFailureGauge...),
-		entrypointReqsCounter:          multi.NewCounter(entrypointReqsCounter...),
-		entrypointReqDurationHistogram: multi.NewHistogram(entrypointReqDurationHistogram...),
-		entrypointOpenConnsGauge:       multi.NewGauge(entrypointOpenConnsGauge...),
-		backendReqsCounter:             multi.NewCounter(backendReqsCounter...),
-		backendReqDurationHistogram:    multi.NewHistogram(backendReqDurationHistogram...),
-		backendOpenConnsGauge:          multi.NewGauge(backendOpenConnsGauge...),
-		backendRetriesCounter:          multi.NewCounter(backendRetriesCounter...),
-		backendServerUpGauge:           multi.NewGauge(backendServerUpGauge...),
+		enabled:                              len(registries) > 0,
+		configReloadsCounter:                 multi.NewCounter(configReloadsCounter...),
+		configReloadsFailureCounter:          multi.NewCounter(configReloadsFailureCounter...),
+		lastConfigReloadSuccessGauge:         multi.NewGauge(lastConfigReloadSuccessGauge...),
+		lastConfigReloadFailureGauge:         multi.NewGauge(lastConfigReloadFailureGauge...),
+		entrypointReqsCounter:                multi.NewCounter(entrypointReqsCounter...),
+		entrypointReqDurationHistogram:       multi.NewHistogram(entrypointReqDurationHistogram...),
+		entrypointOpenConnsGauge:             multi.NewGauge(entrypointOpenConnsGauge...),
+		backendReqsCounter:                   multi.NewCounter(backendReqsCounter...),
+		backendReqDurationHistogram:          multi.NewHistogram(backendReqDurationHistogram...),
+		backendOpenConnsGauge:                multi.NewGauge(backendOpenConnsGauge...),
+		backendRetriesCounter:                multi.NewCounter(backendRetriesCounter...),
+		backendServerUpGauge:                 multi.NewGauge(backendServerUpGauge...),
+		backendUpgradedConnsGauge:            multi.NewGauge(backendUpgradedConnsGauge...),
+		backendUpgradedConnDurationHistogram: multi.NewHistogram(backendUpgradedConnDurationHistogram...),
+		backendMirrorReqsCounter:             multi.NewCounter(backendMirrorReqsCounter...),
+		backendMirrorReqDurationHistogram:    multi.NewHistogram(backendMirrorReqDurationHistogram...),
+		frontendSplitReqsCounter:             multi.NewCounter(frontendSplitReqsCounter...),
+		frontendCacheReqsCounter:             multi.NewCounter(frontendCacheReqsCounter...),
+		osioTokenDecryptionsCounter:          multi.NewCounter(osioTokenDecryptionsCounter...),
+		osioRequestsCounter:                  multi.NewCounter(osioRequestsCounter...),
 	}
 }
 
 type standardRegistry struct {
-	enabled                        bool
-	configReloadsCounter           metrics.Counter
-	configReloadsFailureCounter    metrics.Counter
-	lastConfigReloadSuccessGauge   metrics.Gauge
-	lastConfigReloadFailureGauge   metrics.Gauge
-	entrypointReqsCounter          metrics.Counter
-	entrypointReqDurationHistogram metrics.Histogram
-	entrypointOpenConnsGauge       metrics.Gauge
-	backendReqsCounter             metrics.Counter
-	backendReqDurationHistogram    metrics.Histogram
-	backendOpenConnsGauge          metrics.Gauge
-	backendRetriesCounter          metrics.Counter
-	backendServerUpGauge           metrics.Gauge
+	enabled                              bool
+	configReloadsCounter                 metrics.Counter
+	configReloadsFailureCounter          metrics.Counter
+	lastConfigReloadSuccessGauge         metrics.Gauge
+	lastConfigReloadFailureGauge         metrics.Gauge
+	entrypointReqsCounter                metrics.Counter
+	entrypointReqDurationHistogram       metrics.Histogram
+	entrypointOpenConnsGauge             metrics.Gauge
+	backendReqsCounter                   metrics.Counter
+	backendReqDurationHistogram          metrics.Histogram
+	backendOpenConnsGauge                metrics.Gauge
+	backendRetriesCounter                metrics.Counter
+	backendServerUpGauge                 metrics.Gauge
+	backendUpgradedConnsGauge            metrics.Gauge
+	backendUpgradedConnDurationHistogram metrics.Histogram
+	backendMirrorReqsCounter             metrics.Counter
+	backendMirrorReqDurationHistogram    metrics.Histogram
+	frontendSplitReqsCounter             metrics.Counter
+	frontendCacheReqsCounter             metrics.Counter
+	osioTokenDecryptionsCounter          metrics.Counter
+	osioRequestsCounter                  metrics.Counter
 }
 
 func (r *standardRegistry) IsEnabled() bool {
@@ -174,3 +234,35 @@ func (r *standardRegistry) BackendRetriesCounter() metrics.Counter {
 func (r *standardRegistry) BackendServerUpGauge() metrics.Gauge {
 	return r.backendServerUpGauge
 }
+
+func (r *standardRegistry) BackendUpgradedConnsGauge() metrics.Gauge {
+	return r.backendUpgradedConnsGauge
+}
+
+func (r *standardRegistry) BackendUpgradedConnDurationHistogram() metrics.Histogram {
+	return r.backendUpgradedConnDurationHistogram
+}
+
+func (r *standardRegistry) BackendMirrorReqsCounter() metrics.Counter {
+	return r.backendMirrorReqsCounter
+}
+
+func (r *standardRegistry) BackendMirrorReqDurationHistogram() metrics.Histogram {
+	return r.backendMirrorReqDurationHistogram
+}
+
+func (r *standardRegistry) FrontendSplitReqsCounter() metrics.Counter {
+	return r.frontendSplitReqsCounter
+}
+
+func (r *standardRegistry) FrontendCacheReqsCounter() metrics.Counter {
+	return r.frontendCacheReqsCounter
+}
+
+func (r *standardRegistry) OSIOTokenDecryptionsCounter() metrics.Counter {
+	return r.osioTokenDecryptionsCounter
+}
+
+func (r *standardRegistry) OSIORequestsCounter() metrics.Counter {
+	return r.osioRequestsCounter
+}
@@ -29,11 +29,23 @@ const (
 	entrypointOpenConnsName   = metricNamePrefix + "entrypoint_open_connections"
 
 	// backend level
-	backendReqsTotalName    = metricNamePrefix + "backend_requests_total"
-	backendReqDurationName  = metricNamePrefix + "backend_request_duration_seconds"
-	backendOpenConnsName    = metricNamePrefix + "backend_open_connections"
-	backendRetriesTotalName = metricNamePrefix + "backend_retries_total"
-	backendServerUpName     = metricNamePrefix + "backend_server_up"
+	backendReqsTotalName            = metricNamePrefix + "backend_requests_total"
+	backendReqDurationName          = metricNamePrefix + "backend_request_duration_seconds"
+	backendOpenConnsName            = metricNamePrefix + "backend_open_connections"
+	backendRetriesTotalName         = metricNamePrefix + "backend_retries_total"
+	backendServerUpName             = metricNamePrefix + "backend_server_up"
+	backendUpgradedConnsName        = metricNamePrefix + "backend_upgraded_connections"
+	backendUpgradedConnDurationName = metricNamePrefix + "backend_upgraded_connection_duration_seconds"
+	backendMirrorReqsTotalName      = metricNamePrefix + "backend_mirror_requests_total"
+	backendMirrorReqDurationName    = metricNamePrefix + "backend_mirror_request_duration_seconds"
+
+	// frontend level
+	frontendSplitReqsTotalName = metricNamePrefix + "frontend_split_requests_total"
+	frontendCacheReqsTotalName = metricNamePrefix + "frontend_cache_requests_total"
+
+	// osio
+	osioTokenDecryptionsTotalName = metricNamePrefix + "osio_token_decryptions_total"
+	osioRequestsTotalName         = metricNamePrefix + "osio_requests_total"
 )
 
 // promState holds all metric state internally and acts as the only Collector we register for Prometheus.
@@ -122,6 +134,40 @@ func RegisterPrometheus(config *types.Prometheus) Registry {
 		Name: backendServerUpName,
 		Help: "Backend server is up, described by gauge value of 0 or 1.",
 	}, []string{"backend", "url"})
+	backendUpgradedConns := newGaugeFrom(promState.collectors, stdprometheus.GaugeOpts{
+		Name: backendUpgradedConnsName,
+		Help: "How many upgraded (SPDY, WebSocket, ...) connections are open on a backend, partitioned by protocol.",
+	}, []string{"backend", "protocol"})
+	backendUpgradedConnDurations := newHistogramFrom(promState.collectors, stdprometheus.HistogramOpts{
+		Name:    backendUpgradedConnDurationName,
+		Help:    "How long upgraded connections to a backend lasted, partitioned by protocol and close reason.",
+		Buckets: []float64{1, 10, 60, 300, 1800, 3600},
+	}, []string{"backend", "protocol", "reason"})
+	backendMirrorReqs := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: backendMirrorReqsTotalName,
+		Help: "How many requests were mirrored to a shadow backend, partitioned by status code (dropped when too many were in flight).",
+	}, []string{"backend", "code"})
+	backendMirrorReqDurations := newHistogramFrom(promState.collectors, stdprometheus.HistogramOpts{
+		Name:    backendMirrorReqDurationName,
+		Help:    "How long it took to process the mirrored requests of a shadow backend.",
+		Buckets: buckets,
+	}, []string{"backend"})
+	frontendSplitReqs := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: frontendSplitReqsTotalName,
+		Help: "How many requests of a split frontend were forwarded to each of its backends.",
+	}, []string{"frontend", "backend"})
+	frontendCacheReqs := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: frontendCacheReqsTotalName,
+		Help: "How many requests the cache of a frontend processed, partitioned by result (hit, miss, revalidated, bypass).",
+	}, []string{"frontend", "result"})
+	osioTokenDecryptions := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: osioTokenDecryptionsTotalName,
+		Help: "How many cluster tokens were decrypted, partitioned by key generation (none when no key could decrypt the token).",
+	}, []string{"generation"})
+	osioRequests := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: osioRequestsTotalName,
+		Help: "How many requests the osio middleware processed, partitioned by authentication kind (token, passthrough, anonymous, preflight).",
+	}, []string{"auth"})
 
 	promState.describers = []func(chan<- *stdprometheus.Desc){
 		configReloads.cv.Describe,
@@ -136,23 +182,39 @@ func RegisterPrometheus(config *types.Prometheus) Registry {
 		backendOpenConns.gv.Describe,
 		backendRetries.cv.Describe,
 		backendServerUp.gv.Describe,
+		backendUpgradedConns.gv.Describe,
+		backendUpgradedConnDurations.hv.Describe,
+		backendMirrorReqs.cv.Describe,
+		backendMirrorReqDurations.hv.Describe,
+		frontendSplitReqs.cv.Describe,
+		frontendCacheReqs.cv.Describe,
+		osioTokenDecryptions.cv.Describe,
+		osioRequests.cv.Describe,
 	}
 	stdprometheus.MustRegister(promState)
 
 	return &standardRegistry{
-		enabled:                        true,
-		configReloadsCounter:           configReloads,
-		configReloadsFailureCounter:    configReloadsFailures,
-		lastConfigReloadSuccessGauge:   lastConfigReloadSuccess,
-		lastConfigReloadFailureGauge:   lastConfigReloadFailure,
-		entrypointReqsCounter:          entrypointReqs,
-		entrypointReqDurationHistogram: entrypointReqDurations,
-		entrypointOpenConnsGauge:       entrypointOpenConns,
-		backendReqsCounter:             backendReqs,
-		backendReqDurationHistogram:    backendReqDurations,
-		backendOpenConnsGauge:          backendOpenConns,
-		backendRetriesCounter:          backendRetries,
-		backendServerUpGauge:           backendServerUp,
+		enabled:                              true,
+		configReloadsCounter:                 configReloads,
+		configReloadsFailureCounter:          configReloadsFailures,
+		lastConfigReloadSuccessGauge:         lastConfigReloadSuccess,
+		lastConfigReloadFailureGauge:         lastConfigReloadFailure,
+		entrypointReqsCounter:                entrypointReqs,
+		entrypointReqDurationHistogram:       entrypointReqDurations,
+		entrypointOpenConnsGauge:             entrypointOpenConns,
+		backendReqsCounter:                   backendReqs,
+		backendReqDurationHistogram:          backendReqDurations,
+		backendOpenConnsGauge:                backendOpenConns,
+		backendRetriesCounter:                backendRetries,
+		backendServerUpGauge:                 backendServerUp,
+		backendUpgradedConnsGauge:            backendUpgradedConns,
+		backendUpgradedConnDurationHistogram: backendUpgradedConnDurations,
+		backendMirrorReqsCounter:             backendMirrorReqs,
+		backendMirrorReqDurationHistogram:    backendMirrorReqDurations,
+		frontendSplitReqsCounter:             frontendSplitReqs,
+		frontendCacheReqsCounter:             frontendCacheReqs,
+		osioTokenDecryptionsCounter:          osioTokenDecryptions,
+		osioRequestsCounter:                  osioRequests,
 	}
 }
 
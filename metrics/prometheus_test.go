@@ -62,6 +62,38 @@ func TestPrometheus(t *testing.T) {
 		BackendServerUpGauge().
 		With("backend", "backend1", "url", "http://127.0.0.10:80").
 		Set(1)
+	prometheusRegistry.
+		BackendUpgradedConnsGauge().
+		With("backend", "backend1", "protocol", "spdy").
+		Set(1)
+	prometheusRegistry.
+		BackendUpgradedConnDurationHistogram().
+		With("backend", "backend1", "protocol", "spdy", "reason", "closed").
+		Observe(10)
+	prometheusRegistry.
+		BackendMirrorReqsCounter().
+		With("backend", "shadow", "code", strconv.Itoa(http.StatusOK)).
+		Add(1)
+	prometheusRegistry.
+		BackendMirrorReqDurationHistogram().
+		With("backend", "shadow").
+		Observe(1)
+	prometheusRegistry.
+		FrontendSplitReqsCounter().
+		With("frontend", "frontend1", "backend", "canary").
+		Add(1)
+	prometheusRegistry.
+		FrontendCacheReqsCounter().
+		With("frontend", "frontend1", "result", "hit").
+		Add(1)
+	prometheusRegistry.
+		OSIOTokenDecryptionsCounter().
+		With("generation", "2018-06").
+		Add(1)
+	prometheusRegistry.
+		OSIORequestsCounter().
+		With("auth", "anonymous").
+		Add(1)
 
 	delayForTrackingCompletion()
 
@@ -161,6 +193,68 @@ func TestPrometheus(t *testing.T) {
 			},
 			assert: buildGaugeAssert(t, backendServerUpName, 1),
 		},
+		{
+			name: backendUpgradedConnsName,
+			labels: map[string]string{
+				"backend":  "backend1",
+				"protocol": "spdy",
+			},
+			assert: buildGaugeAssert(t, backendUpgradedConnsName, 1),
+		},
+		{
+			name: backendUpgradedConnDurationName,
+			labels: map[string]string{
+				"backend":  "backend1",
+				"protocol": "spdy",
+				"reason":   "closed",
+			},
+			assert: buildHistogramAssert(t, backendUpgradedConnDurationName, 1),
+		},
+		{
+			name: backendMirrorReqsTotalName,
+			labels: map[string]string{
+				"backend": "shadow",
+				"code":    "200",
+			},
+			assert: buildCounterAssert(t, backendMirrorReqsTotalName, 1),
+		},
+		{
+			name: backendMirrorReqDurationName,
+			labels: map[string]string{
+				"backend": "shadow",
+			},
+			assert: buildHistogramAssert(t, backendMirrorReqDurationName, 1),
+		},
+		{
+			name: frontendSplitReqsTotalName,
+			labels: map[string]string{
+				"frontend": "frontend1",
+				"backend":  "canary",
+			},
+			assert: buildCounterAssert(t, frontendSplitReqsTotalName, 1),
+		},
+		{
+			name: frontendCacheReqsTotalName,
+			labels: map[string]string{
+				"frontend": "frontend1",
+				"result":   "hit",
+			},
+			assert: buildCounterAssert(t, frontendCacheReqsTotalName, 1),
+		},
+		{
+			name: osioTokenDecryptionsTotalName,
+			labels: map[string]string{
+				"generation": "2018-06",
+			},
+			assert: buildCounterAssert(t, osioTokenDecryptionsTotalName, 1),
+		},
+		{
+			name: osioRequestsTotalName,
+			labels: map[string]string{
+				"auth": "anonymous",
+			},
+			assert: buildCounterAssert(t, osioRequestsTotalName, 1),
+		},
 	}
 
 	for _, test := range tests {
@@ -74,6 +74,8 @@ const (
 	Overhead = "Overhead"
 	// RetryAttempts is the map key used for the amount of attempts the request was retried.
 	RetryAttempts = "RetryAttempts"
+	// RejectionReason is the map key used for the limit exceeded by a request or its response, if any.
+	RejectionReason = "RejectionReason"
 )
 
 // These are written out in the default case when no config is provided to specify keys of interest.
@@ -117,6 +119,7 @@ func init() {
 	allCoreKeys[StartLocal] = struct{}{}
 	allCoreKeys[Overhead] = struct{}{}
 	allCoreKeys[RetryAttempts] = struct{}{}
+	allCoreKeys[RejectionReason] = struct{}{}
 }
 
 // CoreLogData holds the fields computed from the request/response.
@@ -0,0 +1,14 @@
+package accesslog
+
+import (
+	"net/http"
+)
+
+// SaveRejection is an implementation of SizeLimitListener that stores the RejectionReason in the LogDataTable.
+type SaveRejection struct{}
+
+// Rejected implements the SizeLimitListener interface and will be called for each request or response over a limit.
+func (s *SaveRejection) Rejected(req *http.Request, reason string) {
+	table := GetLogDataTable(req)
+	table.Core[RejectionReason] = reason
+}
@@ -0,0 +1,22 @@
+package accesslog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSaveRejection(t *testing.T) {
+	saveRejection := &SaveRejection{}
+
+	logDataTable := &LogData{Core: make(CoreLogData)}
+	req := httptest.NewRequest(http.MethodPut, "/some/path", nil)
+	reqWithDataTable := req.WithContext(context.WithValue(req.Context(), DataTableKey, logDataTable))
+
+	saveRejection.Rejected(reqWithDataTable, "RequestBodyTooLarge")
+
+	if logDataTable.Core[RejectionReason] != "RequestBodyTooLarge" {
+		t.Errorf("got %v in logDataTable, want %v", logDataTable.Core[RejectionReason], "RequestBodyTooLarge")
+	}
+}
@@ -0,0 +1,224 @@
+package balancer
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/vulcand/oxy/roundrobin"
+	"github.com/vulcand/oxy/utils"
+)
+
+var errNoServers = errors.New("no servers in the pool")
+
+// picker chooses the server of a request among the servers of the balancer, which has at least one.
+type picker interface {
+	pick(req *http.Request, servers []*server) *server
+	// update is called with the servers of the balancer each time they change
+	update(servers []*server)
+}
+
+// Balancer forwards the requests to the servers chosen by its method, implementing
+// the healthcheck.LoadBalancer interface like the oxy load balancers.
+type Balancer struct {
+	next          http.Handler
+	picker        picker
+	errHandler    utils.ErrorHandler
+	stickySession *roundrobin.StickySession
+	// trackLatency enables the latency measures of the servers
+	trackLatency bool
+
+	mux     sync.RWMutex
+	servers []*server
+}
+
+// server is a server of a balancer.
+type server struct {
+	url    *url.URL
+	weight int
+	// inflight is the number of requests in flight, updated atomically
+	inflight int64
+	// latency is nil unless the balancer tracks the latencies
+	latency *peakEWMA
+}
+
+// Option configures a Balancer.
+type Option func(*Balancer)
+
+// ErrorHandler sets the handler of the requests which cannot be forwarded, when the balancer has no servers.
+func ErrorHandler(h utils.ErrorHandler) Option {
+	return func(b *Balancer) {
+		b.errHandler = h
+	}
+}
+
+// EnableStickySession pins the clients to the server of their first request with a cookie,
+// as long as the server is in the balancer.
+func EnableStickySession(stickySession *roundrobin.StickySession) Option {
+	return func(b *Balancer) {
+		b.stickySession = stickySession
+	}
+}
+
+func newBalancer(next http.Handler, picker picker, options ...Option) *Balancer {
+	b := &Balancer{
+		next:   next,
+		picker: picker,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	if b.errHandler == nil {
+		b.errHandler = utils.DefaultHandler
+	}
+	return b
+}
+
+// ServeHTTP forwards the request to the next handler, with the URL of the chosen server.
+func (b *Balancer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	srv, err := b.nextServer(w, req)
+	if err != nil {
+		b.errHandler.ServeHTTP(w, req, err)
+		return
+	}
+
+	// make shallow copy of request before changing anything to avoid side effects
+	newReq := *req
+	newReq.URL = utils.CopyURL(srv.url)
+
+	atomic.AddInt64(&srv.inflight, 1)
+	defer atomic.AddInt64(&srv.inflight, -1)
+	if srv.latency != nil {
+		start := time.Now()
+		defer func() { srv.latency.observe(time.Since(start)) }()
+	}
+	b.next.ServeHTTP(w, &newReq)
+}
+
+func (b *Balancer) nextServer(w http.ResponseWriter, req *http.Request) (*server, error) {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	if len(b.servers) == 0 {
+		return nil, errNoServers
+	}
+
+	if b.stickySession != nil {
+		cookieURL, present, err := b.stickySession.GetBackend(req, b.urls())
+		if err != nil {
+			log.Warnf("Error using server from cookie: %v", err)
+		}
+		if present {
+			if srv, _ := b.findServer(cookieURL); srv != nil {
+				return srv, nil
+			}
+		}
+	}
+
+	srv := b.picker.pick(req, b.servers)
+	if b.stickySession != nil {
+		b.stickySession.StickBackend(srv.url, &w)
+	}
+	return srv, nil
+}
+
+// Servers returns the URLs of the servers of the balancer.
+func (b *Balancer) Servers() []*url.URL {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.urls()
+}
+
+func (b *Balancer) urls() []*url.URL {
+	urls := make([]*url.URL, len(b.servers))
+	for i, srv := range b.servers {
+		urls[i] = srv.url
+	}
+	return urls
+}
+
+// ServerWeight returns the weight of the server, if it is in the balancer.
+func (b *Balancer) ServerWeight(u *url.URL) (int, bool) {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	if srv, _ := b.findServer(u); srv != nil {
+		return srv.weight, true
+	}
+	return 0, false
+}
+
+// UpsertServer adds the server to the balancer, or updates its weight.
+func (b *Balancer) UpsertServer(u *url.URL, options ...roundrobin.ServerOption) error {
+	if u == nil {
+		return errors.New("server URL can't be nil")
+	}
+	weight, err := serverWeight(u, options)
+	if err != nil {
+		return err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if srv, _ := b.findServer(u); srv != nil {
+		srv.weight = weight
+	} else {
+		srv := &server{url: utils.CopyURL(u), weight: weight}
+		if b.trackLatency {
+			srv.latency = &peakEWMA{}
+		}
+		b.servers = append(b.servers, srv)
+	}
+	b.picker.update(b.servers)
+	return nil
+}
+
+// RemoveServer removes the server from the balancer.
+func (b *Balancer) RemoveServer(u *url.URL) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	_, index := b.findServer(u)
+	if index == -1 {
+		return errors.New("server not found")
+	}
+	b.servers = append(b.servers[:index], b.servers[index+1:]...)
+	b.picker.update(b.servers)
+	return nil
+}
+
+func (b *Balancer) findServer(u *url.URL) (*server, int) {
+	for i, srv := range b.servers {
+		if srv.url.Scheme == u.Scheme && srv.url.Host == u.Host && srv.url.Path == u.Path {
+			return srv, i
+		}
+	}
+	return nil, -1
+}
+
+// serverWeight returns the weight set by the options of the oxy load balancers, 1 by default.
+func serverWeight(u *url.URL, options []roundrobin.ServerOption) (int, error) {
+	// the oxy server options only apply to the servers of an oxy load balancer
+	rr, err := roundrobin.New(nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := rr.UpsertServer(u, options...); err != nil {
+		return 0, err
+	}
+	weight, _ := rr.ServerWeight(u)
+	if weight <= 0 {
+		weight = 1
+	}
+	return weight, nil
+}
+
+// rotation spreads the picks of the servers with the same cost.
+type rotation struct {
+	counter uint32
+}
+
+func (r *rotation) start(n int) int {
+	return int(atomic.AddUint32(&r.counter, 1) % uint32(n))
+}
@@ -0,0 +1,304 @@
+package balancer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+	"github.com/vulcand/oxy/utils"
+)
+
+var _ healthcheck.LoadBalancer = &Balancer{}
+
+// recorder is the next handler of the balancers, recording the servers of the requests.
+type recorder struct {
+	mux     sync.Mutex
+	servers []string
+	// block, if set, holds the requests until it is closed
+	block chan struct{}
+}
+
+func (r *recorder) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	r.mux.Lock()
+	r.servers = append(r.servers, req.URL.Host)
+	block := r.block
+	r.mux.Unlock()
+	if block != nil {
+		<-block
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (r *recorder) counts() map[string]int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	counts := make(map[string]int)
+	for _, server := range r.servers {
+		counts[server]++
+	}
+	return counts
+}
+
+func serve(b *Balancer, req *http.Request) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	b.ServeHTTP(rw, req)
+	return rw
+}
+
+func TestBalancerServers(t *testing.T) {
+	b := NewLeastConn(&recorder{})
+	a := testhelpers.MustParseURL("http://a")
+
+	require.NoError(t, b.UpsertServer(a, roundrobin.Weight(3)))
+	require.NoError(t, b.UpsertServer(testhelpers.MustParseURL("http://b")))
+	assert.Equal(t, []*url.URL{a, testhelpers.MustParseURL("http://b")}, b.Servers())
+
+	weight, ok := b.ServerWeight(a)
+	assert.True(t, ok)
+	assert.Equal(t, 3, weight)
+	weight, ok = b.ServerWeight(testhelpers.MustParseURL("http://b"))
+	assert.True(t, ok)
+	assert.Equal(t, 1, weight)
+
+	require.NoError(t, b.UpsertServer(a, roundrobin.Weight(2)))
+	weight, _ = b.ServerWeight(a)
+	assert.Equal(t, 2, weight)
+	assert.Len(t, b.Servers(), 2)
+
+	assert.Error(t, b.UpsertServer(a, roundrobin.Weight(-1)))
+
+	require.NoError(t, b.RemoveServer(a))
+	assert.Equal(t, []*url.URL{testhelpers.MustParseURL("http://b")}, b.Servers())
+	assert.Error(t, b.RemoveServer(a))
+}
+
+func TestBalancerNoServers(t *testing.T) {
+	for name, b := range map[string]*Balancer{
+		"leastconn":      NewLeastConn(&recorder{}),
+		"ewma":           NewEWMA(&recorder{}),
+		"consistenthash": NewConsistentHash(&recorder{}, mustExtractor(t, "client.ip")),
+	} {
+		// as the oxy load balancers, wrapped by the EmptyBackendHandler in the proxy
+		rw := serve(b, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusInternalServerError, rw.Code, name)
+	}
+}
+
+func TestLeastConn(t *testing.T) {
+	next := &recorder{block: make(chan struct{})}
+	b := NewLeastConn(next)
+	require.NoError(t, b.UpsertServer(testhelpers.MustParseURL("http://a"), roundrobin.Weight(2)))
+	require.NoError(t, b.UpsertServer(testhelpers.MustParseURL("http://b")))
+
+	// the blocked requests stay in flight: a takes two requests for each one of b
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(b, httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+		waitFor(t, func() bool { return len(next.counts()) > 0 && sum(next.counts()) == i+1 })
+	}
+	assert.Equal(t, map[string]int{"a": 4, "b": 2}, next.counts())
+
+	close(next.block)
+	wg.Wait()
+}
+
+func TestLeastConnRotation(t *testing.T) {
+	next := &recorder{}
+	b := NewLeastConn(next)
+	for _, host := range []string{"a", "b", "c"} {
+		require.NoError(t, b.UpsertServer(testhelpers.MustParseURL("http://"+host)))
+	}
+
+	for i := 0; i < 30; i++ {
+		serve(b, httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	assert.Equal(t, map[string]int{"a": 10, "b": 10, "c": 10}, next.counts())
+}
+
+func TestEWMA(t *testing.T) {
+	next := &recorder{}
+	b := NewEWMA(next)
+	require.NoError(t, b.UpsertServer(testhelpers.MustParseURL("http://fast")))
+	require.NoError(t, b.UpsertServer(testhelpers.MustParseURL("http://slow")))
+
+	b.mux.RLock()
+	for _, srv := range b.servers {
+		latency := 10 * time.Millisecond
+		if srv.url.Host == "slow" {
+			latency = time.Second
+		}
+		srv.latency.observe(latency)
+	}
+	b.mux.RUnlock()
+
+	for i := 0; i < 20; i++ {
+		serve(b, httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	assert.Equal(t, map[string]int{"fast": 20}, next.counts())
+}
+
+func TestPeakEWMA(t *testing.T) {
+	p := &peakEWMA{}
+	p.observe(100 * time.Millisecond)
+	assert.Equal(t, float64(100*time.Millisecond), p.value())
+
+	// the peaks are followed at once
+	p.observe(time.Second)
+	assert.Equal(t, float64(time.Second), p.value())
+
+	// the lower latencies are averaged
+	p.stamp = p.stamp.Add(-ewmaDecay)
+	p.observe(0)
+	assert.InDelta(t, float64(time.Second)/2.718281828, p.value(), float64(time.Millisecond))
+}
+
+func TestConsistentHash(t *testing.T) {
+	next := &recorder{}
+	b := NewConsistentHash(next, mustExtractor(t, "request.header.X-User"))
+	for _, host := range []string{"a", "b", "c"} {
+		require.NoError(t, b.UpsertServer(testhelpers.MustParseURL("http://"+host)))
+	}
+
+	route := func(user string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-User", user)
+		serve(b, req)
+		next.mux.Lock()
+		defer next.mux.Unlock()
+		return next.servers[len(next.servers)-1]
+	}
+
+	routes := make(map[string]string)
+	for i := 0; i < 300; i++ {
+		user := fmt.Sprintf("user%d", i)
+		routes[user] = route(user)
+		assert.Equal(t, routes[user], route(user), "same user, same server")
+	}
+	for host, count := range next.counts() {
+		// each server takes its share of the users, twice
+		assert.InDelta(t, 200, count, 100, host)
+	}
+
+	// removing a server only moves its users
+	require.NoError(t, b.RemoveServer(testhelpers.MustParseURL("http://c")))
+	for user, host := range routes {
+		if host != "c" {
+			assert.Equal(t, host, route(user), user)
+		} else {
+			assert.NotEqual(t, "c", route(user), user)
+		}
+	}
+
+	// and adding it back restores them
+	require.NoError(t, b.UpsertServer(testhelpers.MustParseURL("http://c")))
+	for user, host := range routes {
+		assert.Equal(t, host, route(user), user)
+	}
+}
+
+func TestConsistentHashClientIP(t *testing.T) {
+	next := &recorder{}
+	b := NewConsistentHash(next, mustExtractor(t, "request.header.X-User"))
+	for _, host := range []string{"a", "b", "c"} {
+		require.NoError(t, b.UpsertServer(testhelpers.MustParseURL("http://"+host)))
+	}
+
+	// the requests without key are hashed by client IP, whatever the port
+	for port := 1000; port < 1010; port++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = fmt.Sprintf("10.0.0.1:%d", port)
+		serve(b, req)
+	}
+	assert.Len(t, next.counts(), 1)
+}
+
+func TestStickySession(t *testing.T) {
+	next := &recorder{}
+	b := NewLeastConn(next, EnableStickySession(roundrobin.NewStickySession("sticky")))
+	for _, host := range []string{"a", "b"} {
+		require.NoError(t, b.UpsertServer(testhelpers.MustParseURL("http://"+host)))
+	}
+
+	rw := serve(b, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := (&http.Response{Header: rw.Header()}).Cookies()
+	require.Len(t, cookies, 1)
+
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+		serve(b, req)
+	}
+	assert.Len(t, next.counts(), 1)
+}
+
+func BenchmarkLeastConn(b *testing.B) {
+	benchmarkBalancer(b, NewLeastConn(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+}
+
+func BenchmarkEWMA(b *testing.B) {
+	benchmarkBalancer(b, NewEWMA(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+}
+
+func BenchmarkConsistentHash(b *testing.B) {
+	key, err := utils.NewExtractor("request.header.X-User")
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkBalancer(b, NewConsistentHash(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), key))
+}
+
+func benchmarkBalancer(b *testing.B, balancer *Balancer) {
+	for i := 0; i < 10; i++ {
+		if err := balancer.UpsertServer(testhelpers.MustParseURL(fmt.Sprintf("http://10.0.0.%d", i))); err != nil {
+			b.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User", "john")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rw := httptest.NewRecorder()
+		for pb.Next() {
+			balancer.ServeHTTP(rw, req)
+		}
+	})
+}
+
+func mustExtractor(t *testing.T, variable string) utils.SourceExtractor {
+	extractor, err := utils.NewExtractor(variable)
+	require.NoError(t, err)
+	return extractor
+}
+
+func sum(counts map[string]int) int {
+	var total int
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,74 @@
+package balancer
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ewmaDecay is the time constant of the latency averages, the weight of a measure halving in about 7s.
+const ewmaDecay = 10 * time.Second
+
+// NewEWMA creates a balancer forwarding each request to the server with the lowest peak EWMA latency,
+// multiplied by its requests in flight and divided by its weight. The average jumps to the latencies
+// above it, so that a slowing server is quickly avoided, and decays over time otherwise.
+func NewEWMA(next http.Handler, options ...Option) *Balancer {
+	b := newBalancer(next, &ewma{}, options...)
+	b.trackLatency = true
+	return b
+}
+
+type ewma struct {
+	rotation
+}
+
+func (e *ewma) pick(req *http.Request, servers []*server) *server {
+	start := e.start(len(servers))
+	var best *server
+	bestCost := math.Inf(1)
+	for i := 0; i < len(servers); i++ {
+		srv := servers[(start+i)%len(servers)]
+		if cost := srv.cost(); cost < bestCost {
+			best, bestCost = srv, cost
+		}
+	}
+	return best
+}
+
+func (e *ewma) update(servers []*server) {}
+
+// cost is the expected latency of a new request to the server, relative to its weight.
+func (s *server) cost() float64 {
+	// the servers without measures yet cost as much as their requests in flight
+	latency := s.latency.value() + float64(time.Millisecond)
+	return latency * float64(atomic.LoadInt64(&s.inflight)+1) / float64(s.weight)
+}
+
+// peakEWMA is an exponentially weighted moving average of latencies, following the peaks.
+type peakEWMA struct {
+	mux     sync.Mutex
+	average float64
+	stamp   time.Time
+}
+
+func (p *peakEWMA) observe(latency time.Duration) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	now := time.Now()
+	rtt := float64(latency)
+	if rtt > p.average || p.stamp.IsZero() {
+		p.average = rtt
+	} else {
+		w := math.Exp(-float64(now.Sub(p.stamp)) / float64(ewmaDecay))
+		p.average = p.average*w + rtt*(1-w)
+	}
+	p.stamp = now
+}
+
+func (p *peakEWMA) value() float64 {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.average
+}
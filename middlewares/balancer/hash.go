@@ -0,0 +1,82 @@
+package balancer
+
+import (
+	"hash/fnv"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/containous/traefik/log"
+	"github.com/vulcand/oxy/utils"
+)
+
+// replicas are the points of a server of weight 1 on the hash ring.
+const replicas = 100
+
+// NewConsistentHash creates a balancer forwarding the requests with the same key to the same server,
+// as long as it is in the balancer. Adding or removing a server only moves the keys of this server,
+// so that the caches of the other servers stay warm. The requests without key are hashed by client IP.
+func NewConsistentHash(next http.Handler, key utils.SourceExtractor, options ...Option) *Balancer {
+	return newBalancer(next, &consistentHash{key: key}, options...)
+}
+
+type consistentHash struct {
+	key  utils.SourceExtractor
+	ring []ringPoint
+}
+
+type ringPoint struct {
+	hash   uint64
+	server *server
+}
+
+func (c *consistentHash) pick(req *http.Request, servers []*server) *server {
+	h := Hash(c.requestKey(req))
+	i := sort.Search(len(c.ring), func(i int) bool { return c.ring[i].hash >= h })
+	if i == len(c.ring) {
+		i = 0
+	}
+	return c.ring[i].server
+}
+
+func (c *consistentHash) requestKey(req *http.Request) string {
+	key, _, err := c.key.Extract(req)
+	if err != nil {
+		log.Debugf("Error extracting the hash key, using the client IP: %v", err)
+	}
+	if err == nil && key != "" {
+		return key
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
+// update rebuilds the ring, where each server has points proportional to its weight.
+func (c *consistentHash) update(servers []*server) {
+	var ring []ringPoint
+	for _, srv := range servers {
+		name := srv.url.String()
+		for i := 0; i < replicas*srv.weight; i++ {
+			ring = append(ring, ringPoint{hash: Hash(name + "#" + strconv.Itoa(i)), server: srv})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	c.ring = ring
+}
+
+// Hash hashes a key evenly over the uint64 values, e.g. to map it on a ring or on weights.
+func Hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	// the murmur3 finalizer spreads the keys differing by their last characters over the ring
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,33 @@
+package balancer
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// NewLeastConn creates a balancer forwarding each request to the server with the fewest requests
+// in flight relative to its weight, the servers with as many requests taking turns.
+func NewLeastConn(next http.Handler, options ...Option) *Balancer {
+	return newBalancer(next, &leastConn{}, options...)
+}
+
+type leastConn struct {
+	rotation
+}
+
+func (l *leastConn) pick(req *http.Request, servers []*server) *server {
+	start := l.start(len(servers))
+	best := servers[start]
+	bestInflight := atomic.LoadInt64(&best.inflight)
+	for i := 1; i < len(servers); i++ {
+		srv := servers[(start+i)%len(servers)]
+		inflight := atomic.LoadInt64(&srv.inflight)
+		// inflight / weight < bestInflight / best.weight
+		if inflight*int64(best.weight) < bestInflight*int64(srv.weight) {
+			best, bestInflight = srv, inflight
+		}
+	}
+	return best
+}
+
+func (l *leastConn) update(servers []*server) {}
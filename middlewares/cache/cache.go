@@ -0,0 +1,403 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/middlewares/osio"
+	"github.com/containous/traefik/types"
+	gokitmetrics "github.com/go-kit/kit/metrics"
+	"github.com/vulcand/oxy/utils"
+)
+
+const (
+	// DefaultMaxSize is the default size in bytes of the responses cached by a frontend.
+	DefaultMaxSize = 16 << 20
+	// DefaultMaxEntrySize is the default size in bytes of the largest response cached.
+	DefaultMaxEntrySize = 1 << 20
+)
+
+// Results of the requests, recorded by the metrics.
+const (
+	resultHit         = "hit"
+	resultMiss        = "miss"
+	resultRevalidated = "revalidated"
+	resultBypass      = "bypass"
+)
+
+// cacheMetrics is the part of metrics.Registry recording the requests of the frontend caches.
+type cacheMetrics interface {
+	FrontendCacheReqsCounter() gokitmetrics.Counter
+}
+
+// Cache serves the GET and HEAD requests of a frontend with the responses stored from its backend,
+// as a shared HTTP cache following the Cache-Control, Expires, Vary, ETag and Last-Modified headers.
+type Cache struct {
+	frontend     string
+	key          []keyPart
+	defaultTTL   time.Duration
+	maxEntrySize int64
+	entries      *lru
+
+	reqsCounter gokitmetrics.Counter
+}
+
+// keyPart returns a part of the cache key of a request.
+type keyPart func(req *http.Request) string
+
+// New creates the cache of a frontend, the defaults applying to the unset options:
+// up to DefaultMaxSize bytes of responses of at most DefaultMaxEntrySize bytes, keyed by
+// method, path and query, and only the responses with explicit freshness.
+func New(frontendName string, config *types.Cache, registry cacheMetrics) (*Cache, error) {
+	maxSize := config.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+	maxEntrySize := config.MaxEntrySize
+	if maxEntrySize == 0 {
+		maxEntrySize = DefaultMaxEntrySize
+	}
+	if maxSize < 0 || maxEntrySize < 0 {
+		return nil, fmt.Errorf("invalid cache sizes %d and %d", config.MaxSize, config.MaxEntrySize)
+	}
+
+	var defaultTTL time.Duration
+	if config.DefaultTTL != "" {
+		var err error
+		if defaultTTL, err = time.ParseDuration(config.DefaultTTL); err != nil || defaultTTL < 0 {
+			return nil, fmt.Errorf("invalid cache default TTL %q", config.DefaultTTL)
+		}
+	}
+
+	variables := config.Key
+	if len(variables) == 0 {
+		variables = []string{"path", "query"}
+	}
+	var key []keyPart
+	for _, variable := range variables {
+		part, err := newKeyPart(variable)
+		if err != nil {
+			return nil, err
+		}
+		key = append(key, part)
+	}
+
+	return &Cache{
+		frontend:     frontendName,
+		key:          key,
+		defaultTTL:   defaultTTL,
+		maxEntrySize: maxEntrySize,
+		entries:      newLRU(maxSize),
+		reqsCounter:  registry.FrontendCacheReqsCounter(),
+	}, nil
+}
+
+// newKeyPart supports path, query and the variables of osio.NewExtractor, except client.ip.
+func newKeyPart(variable string) (keyPart, error) {
+	switch variable {
+	case "path":
+		return func(req *http.Request) string { return req.URL.EscapedPath() }, nil
+	case "query":
+		return func(req *http.Request) string { return req.URL.RawQuery }, nil
+	case "client.ip":
+		return nil, fmt.Errorf("unsupported cache key %s", variable)
+	}
+	extractor, err := osio.NewExtractor(variable)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported cache key %s: %v", variable, err)
+	}
+	return extractorKeyPart(variable, extractor), nil
+}
+
+func extractorKeyPart(variable string, extractor utils.SourceExtractor) keyPart {
+	return func(req *http.Request) string {
+		value, _, err := extractor.Extract(req)
+		if err != nil {
+			log.Debugf("Error extracting cache key %s: %v", variable, err)
+		}
+		return value
+	}
+}
+
+func (c *Cache) requestKey(req *http.Request, method string) string {
+	parts := []string{method}
+	for _, part := range c.key {
+		parts = append(parts, part(req))
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (c *Cache) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	cacheable, revalidate := requestDirectives(req)
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		// the unsafe methods invalidate the stored responses
+		if req.Method != http.MethodOptions && req.Method != http.MethodTrace {
+			c.entries.remove(c.requestKey(req, http.MethodGet))
+			c.entries.remove(c.requestKey(req, http.MethodHead))
+		}
+		cacheable = false
+	}
+	if !cacheable || req.Header.Get("Upgrade") != "" {
+		c.count(resultBypass)
+		next.ServeHTTP(rw, req)
+		return
+	}
+
+	key := c.requestKey(req, req.Method)
+	stored := c.entries.get(key)
+	if stored != nil && !stored.matches(req) {
+		stored = nil
+	}
+	now := time.Now()
+	if stored != nil && !revalidate && stored.fresh(now) {
+		c.count(resultHit)
+		c.serve(rw, req, stored, now)
+		return
+	}
+
+	crw := &responseWriter{ResponseWriter: rw, header: make(http.Header), maxSize: c.maxEntrySize}
+	if stored != nil && hasValidator(stored.header) {
+		crw.revalidating = true
+		next.ServeHTTP(crw, conditionalRequest(req, stored))
+	} else {
+		next.ServeHTTP(crw, req)
+	}
+
+	if crw.notModified {
+		c.count(resultRevalidated)
+		refreshed, ok := c.refresh(stored, crw.header, now, isAuthorized(req))
+		if ok {
+			c.entries.add(refreshed)
+		} else {
+			c.entries.remove(key)
+		}
+		c.serve(rw, req, refreshed, time.Now())
+		return
+	}
+	c.count(resultMiss)
+	if !crw.wroteHeader {
+		crw.WriteHeader(http.StatusOK)
+	}
+	c.store(key, req, crw, now)
+}
+
+// conditionalRequest copies the request asking the backend to only send a response other than the stored one.
+func conditionalRequest(req *http.Request, stored *entry) *http.Request {
+	conditional := *req
+	conditional.Header = make(http.Header, len(req.Header)+2)
+	for name, values := range req.Header {
+		conditional.Header[name] = values
+	}
+	conditional.Header.Del("If-None-Match")
+	conditional.Header.Del("If-Modified-Since")
+	if etag := stored.header.Get("ETag"); etag != "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := stored.header.Get("Last-Modified"); lastModified != "" {
+		conditional.Header.Set("If-Modified-Since", lastModified)
+	}
+	return &conditional
+}
+
+// refresh returns the stored response updated with the headers of the Not Modified response revalidating it,
+// and whether it may still be stored.
+func (c *Cache) refresh(stored *entry, header http.Header, now time.Time, authorized bool) (*entry, bool) {
+	refreshed := *stored
+	refreshed.header = make(http.Header, len(stored.header))
+	for name, values := range stored.header {
+		refreshed.header[name] = values
+	}
+	for name, values := range header {
+		// the Not Modified responses may only differ by their metadata, not their payload
+		if name != "Content-Length" && name != "Content-Encoding" && name != "Transfer-Encoding" {
+			refreshed.header[name] = values
+		}
+	}
+	refreshed.stored = now
+	refreshed.age = initialAge(refreshed.header)
+	ttl, ok := freshness(stored.status, refreshed.header, c.defaultTTL, authorized)
+	refreshed.freshness = ttl
+	refreshed.size = entrySize(&refreshed)
+	return &refreshed, ok
+}
+
+func (c *Cache) store(key string, req *http.Request, crw *responseWriter, now time.Time) {
+	if crw.overflow || (req.Method != http.MethodHead && !crw.complete()) {
+		return
+	}
+	ttl, ok := freshness(crw.code, crw.header, c.defaultTTL, isAuthorized(req))
+	if !ok {
+		return
+	}
+	e := &entry{
+		key:       key,
+		status:    crw.code,
+		header:    crw.header,
+		body:      crw.body.Bytes(),
+		vary:      make(map[string]string),
+		stored:    now,
+		age:       initialAge(crw.header),
+		freshness: ttl,
+	}
+	for _, value := range crw.header["Vary"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+				e.vary[name] = req.Header.Get(name)
+			}
+		}
+	}
+	e.size = entrySize(e)
+	c.entries.add(e)
+}
+
+// isAuthorized returns whether the request holds credentials, such as the token of an OSIO user.
+func isAuthorized(req *http.Request) bool {
+	return req.Header.Get("Authorization") != ""
+}
+
+// serve writes the stored response, or Not Modified if it matches the conditional headers of the request.
+func (c *Cache) serve(rw http.ResponseWriter, req *http.Request, stored *entry, now time.Time) {
+	header := rw.Header()
+	copyHeader(header, stored.header)
+	header.Set("Age", strconv.FormatInt(int64(stored.currentAge(now)/time.Second), 10))
+
+	if stored.status == http.StatusOK && notModified(req, stored.header) {
+		header.Del("Content-Length")
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+	rw.WriteHeader(stored.status)
+	if req.Method != http.MethodHead {
+		if _, err := rw.Write(stored.body); err != nil {
+			log.Debugf("Error writing cached response of frontend %s: %v", c.frontend, err)
+		}
+	}
+}
+
+// notModified evaluates the conditional headers of a request against a response.
+func notModified(req *http.Request, header http.Header) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+		return err == nil && !lastModified.After(since)
+	}
+	return false
+}
+
+// copyHeader copies the header values, which the next handlers may append to.
+func copyHeader(dst, src http.Header) {
+	for name, values := range src {
+		dst[name] = append([]string(nil), values...)
+	}
+}
+
+func (c *Cache) count(result string) {
+	c.reqsCounter.With("frontend", c.frontend, "result", result).Add(1)
+}
+
+// responseWriter writes the response of the backend while recording it to be stored,
+// except a Not Modified response to a revalidation which is kept from the client.
+type responseWriter struct {
+	http.ResponseWriter
+	header       http.Header
+	code         int
+	wroteHeader  bool
+	revalidating bool
+	notModified  bool
+	body         bytes.Buffer
+	maxSize      int64
+	overflow     bool
+	written      int64
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.code = code
+	if w.revalidating && code == http.StatusNotModified {
+		w.notModified = true
+		return
+	}
+	copyHeader(w.ResponseWriter.Header(), w.header)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(buf []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notModified {
+		return len(buf), nil
+	}
+	w.written += int64(len(buf))
+	if !w.overflow {
+		if int64(w.body.Len()+len(buf)) > w.maxSize {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(buf)
+		}
+	}
+	return w.ResponseWriter.Write(buf)
+}
+
+// complete returns whether the whole body announced by the response was written.
+func (w *responseWriter) complete() bool {
+	contentLength := w.header.Get("Content-Length")
+	if contentLength == "" {
+		return true
+	}
+	length, err := strconv.ParseInt(contentLength, 10, 64)
+	return err == nil && length == w.written
+}
+
+// Hijack hijacks the connection
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (w *responseWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush sends any buffered data to the client.
+func (w *responseWriter) Flush() {
+	if w.notModified {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
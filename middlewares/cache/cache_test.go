@@ -0,0 +1,341 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backend serves the discovery documents, recording the requests it gets.
+type backend struct {
+	mux      sync.Mutex
+	requests []*http.Request
+	header   http.Header
+	body     string
+}
+
+func (b *backend) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	b.mux.Lock()
+	b.requests = append(b.requests, req)
+	header, body := b.header, b.body
+	b.mux.Unlock()
+
+	for name, values := range header {
+		rw.Header()[name] = values
+	}
+	if etag := header.Get("ETag"); etag != "" && req.Header.Get("If-None-Match") == etag {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+	body += req.URL.RawQuery
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		body += " for " + auth
+	}
+	rw.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte(body))
+}
+
+func (b *backend) count() int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return len(b.requests)
+}
+
+func (b *backend) last() *http.Request {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.requests[len(b.requests)-1]
+}
+
+func serve(c *Cache, b *backend, req *http.Request) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	c.ServeHTTP(rw, req, b.ServeHTTP)
+	return rw
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []types.Cache{
+		{MaxSize: -1},
+		{MaxEntrySize: -1},
+		{DefaultTTL: "soon"},
+		{DefaultTTL: "-1s"},
+		{Key: []string{"client.ip"}},
+		{Key: []string{"osio.tenant"}},
+	} {
+		_, err := New("frontend", &config, &resultCounter{})
+		assert.Error(t, err, "%+v", config)
+	}
+}
+
+func TestCacheHit(t *testing.T) {
+	counter := &resultCounter{}
+	c, err := New("frontend", &types.Cache{}, counter)
+	require.NoError(t, err)
+	b := &backend{header: http.Header{"Cache-Control": {"max-age=60"}, "Content-Type": {"application/json"}}, body: "api"}
+
+	rw := serve(c, b, httptest.NewRequest(http.MethodGet, "/api", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "api", rw.Body.String())
+	assert.Empty(t, rw.Header().Get("Age"))
+
+	for i := 0; i < 3; i++ {
+		rw = serve(c, b, httptest.NewRequest(http.MethodGet, "/api", nil))
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "api", rw.Body.String())
+		assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+		assert.Equal(t, "0", rw.Header().Get("Age"))
+	}
+	assert.Equal(t, 1, b.count())
+
+	// the query is part of the key, and HEAD requests are cached apart
+	rw = serve(c, b, httptest.NewRequest(http.MethodGet, "/api?v=1", nil))
+	assert.Equal(t, "apiv=1", rw.Body.String())
+	serve(c, b, httptest.NewRequest(http.MethodHead, "/api", nil))
+	assert.Equal(t, 3, b.count())
+
+	assert.Equal(t, map[string]int{"hit": 3, "miss": 3}, counter.counts())
+}
+
+func TestCacheNotStored(t *testing.T) {
+	tests := []struct {
+		desc    string
+		config  types.Cache
+		header  http.Header
+		body    string
+		request http.Header
+	}{
+		{
+			desc:   "no freshness",
+			header: http.Header{},
+		},
+		{
+			desc:   "private",
+			config: types.Cache{DefaultTTL: "1m"},
+			header: http.Header{"Cache-Control": {"private"}},
+		},
+		{
+			desc:   "too large",
+			config: types.Cache{MaxEntrySize: 3},
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			body:   "apis",
+		},
+		{
+			desc:    "request no-store",
+			header:  http.Header{"Cache-Control": {"max-age=60"}},
+			request: http.Header{"Cache-Control": {"no-store"}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			c, err := New("frontend", &test.config, &resultCounter{})
+			require.NoError(t, err)
+			b := &backend{header: test.header, body: test.body}
+
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/apis", nil)
+				for name, values := range test.request {
+					req.Header[name] = values
+				}
+				rw := serve(c, b, req)
+				assert.Equal(t, test.body, rw.Body.String())
+			}
+			assert.Equal(t, 2, b.count())
+		})
+	}
+}
+
+func TestCacheAuthorization(t *testing.T) {
+	tests := []struct {
+		desc         string
+		config       types.Cache
+		cacheControl string
+		wantShared   bool
+	}{
+		{
+			desc:         "max-age",
+			cacheControl: "max-age=60",
+		},
+		{
+			desc:   "default TTL",
+			config: types.Cache{DefaultTTL: "1m"},
+		},
+		{
+			desc:         "public",
+			cacheControl: "public, max-age=60",
+			wantShared:   true,
+		},
+		{
+			desc:         "s-maxage",
+			cacheControl: "s-maxage=60",
+			wantShared:   true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			c, err := New("frontend", &test.config, &resultCounter{})
+			require.NoError(t, err)
+			b := &backend{header: http.Header{}, body: "user"}
+			if test.cacheControl != "" {
+				b.header.Set("Cache-Control", test.cacheControl)
+			}
+
+			request := func(token string) string {
+				req := httptest.NewRequest(http.MethodGet, "/apis/user.openshift.io/v1/users/~", nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				return serve(c, b, req).Body.String()
+			}
+			assert.Equal(t, "user for Bearer john_token", request("john_token"))
+			if test.wantShared {
+				assert.Equal(t, "user for Bearer john_token", request("jane_token"))
+				assert.Equal(t, 1, b.count())
+			} else {
+				assert.Equal(t, "user for Bearer jane_token", request("jane_token"))
+				assert.Equal(t, "user for Bearer john_token", request("john_token"))
+				assert.Equal(t, 3, b.count())
+			}
+		})
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	c, err := New("frontend", &types.Cache{Key: []string{"path", "osio.cluster"}}, &resultCounter{})
+	require.NoError(t, err)
+	b := &backend{header: http.Header{"Cache-Control": {"max-age=60"}}, body: "version"}
+
+	for _, target := range []string{"cluster1", "cluster2", "cluster1"} {
+		req := httptest.NewRequest(http.MethodGet, "/version?user="+target, nil)
+		req.Header.Set("Target", target)
+		serve(c, b, req)
+	}
+	// the query is not part of the key
+	assert.Equal(t, 2, b.count())
+}
+
+func TestCacheVary(t *testing.T) {
+	counter := &resultCounter{}
+	c, err := New("frontend", &types.Cache{}, counter)
+	require.NoError(t, err)
+	b := &backend{header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Encoding, accept"}}, body: "oapi"}
+
+	request := func(accept string) {
+		req := httptest.NewRequest(http.MethodGet, "/oapi", nil)
+		req.Header.Set("Accept", accept)
+		serve(c, b, req)
+	}
+	request("application/json")
+	request("application/json")
+	request("application/yaml")
+	request("application/yaml")
+	assert.Equal(t, map[string]int{"hit": 2, "miss": 2}, counter.counts())
+}
+
+func TestCacheRevalidation(t *testing.T) {
+	counter := &resultCounter{}
+	c, err := New("frontend", &types.Cache{}, counter)
+	require.NoError(t, err)
+	b := &backend{header: http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}}, body: "apis"}
+
+	rw := serve(c, b, httptest.NewRequest(http.MethodGet, "/apis", nil))
+	assert.Equal(t, "apis", rw.Body.String())
+
+	// the stored response is served once the backend confirms it
+	rw = serve(c, b, httptest.NewRequest(http.MethodGet, "/apis", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "apis", rw.Body.String())
+	assert.Equal(t, `"v1"`, b.last().Header.Get("If-None-Match"))
+
+	// and the conditional requests of the clients are answered by the cache
+	req := httptest.NewRequest(http.MethodGet, "/apis", nil)
+	req.Header.Set("If-None-Match", `"v0", "v1"`)
+	rw = serve(c, b, req)
+	assert.Equal(t, http.StatusNotModified, rw.Code)
+	assert.Empty(t, rw.Body.String())
+
+	// a changed response replaces the stored one
+	b.mux.Lock()
+	b.header = http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v2"`}}
+	b.body = "apis v2"
+	b.mux.Unlock()
+	rw = serve(c, b, httptest.NewRequest(http.MethodGet, "/apis", nil))
+	assert.Equal(t, "apis v2", rw.Body.String())
+	assert.Equal(t, `"v2"`, rw.Header().Get("ETag"))
+
+	assert.Equal(t, map[string]int{"miss": 2, "revalidated": 2}, counter.counts())
+	assert.Equal(t, 4, b.count())
+}
+
+func TestCacheRequestNoCache(t *testing.T) {
+	c, err := New("frontend", &types.Cache{}, &resultCounter{})
+	require.NoError(t, err)
+	b := &backend{header: http.Header{"Cache-Control": {"max-age=60"}}, body: "api"}
+
+	serve(c, b, httptest.NewRequest(http.MethodGet, "/api", nil))
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	serve(c, b, req)
+	assert.Equal(t, 2, b.count())
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	counter := &resultCounter{}
+	c, err := New("frontend", &types.Cache{}, counter)
+	require.NoError(t, err)
+	b := &backend{header: http.Header{"Cache-Control": {"max-age=60"}}, body: "namespaces"}
+
+	serve(c, b, httptest.NewRequest(http.MethodGet, "/api/v1/namespaces", nil))
+	serve(c, b, httptest.NewRequest(http.MethodPost, "/api/v1/namespaces", strings.NewReader("{}")))
+	serve(c, b, httptest.NewRequest(http.MethodGet, "/api/v1/namespaces", nil))
+	assert.Equal(t, 3, b.count())
+	assert.Equal(t, map[string]int{"miss": 2, "bypass": 1}, counter.counts())
+}
+
+// resultCounter is a cacheMetrics counting the requests by result.
+type resultCounter struct {
+	mux     sync.Mutex
+	results map[string]*generic.Counter
+}
+
+func (c *resultCounter) FrontendCacheReqsCounter() metrics.Counter {
+	return c
+}
+
+func (c *resultCounter) With(labelValues ...string) metrics.Counter {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.results == nil {
+		c.results = make(map[string]*generic.Counter)
+	}
+	result := labelValues[len(labelValues)-1]
+	if c.results[result] == nil {
+		c.results[result] = generic.NewCounter(result)
+	}
+	return c.results[result]
+}
+
+func (c *resultCounter) Add(delta float64) {}
+
+func (c *resultCounter) counts() map[string]int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	counts := make(map[string]int)
+	for result, counter := range c.results {
+		counts[result] = int(counter.Value())
+	}
+	return counts
+}
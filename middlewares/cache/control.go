@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheableStatus are the status codes of the responses which may be stored.
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// cacheControl holds the directives of the Cache-Control headers, by lower case name.
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, value := range header["Cache-Control"] {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg := directive, ""
+			if i := strings.Index(directive, "="); i >= 0 {
+				name, arg = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+			}
+			cc[strings.ToLower(strings.TrimSpace(name))] = arg
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds returns the value of a delta-seconds directive, if valid.
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	arg, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// requestDirectives returns whether the request may use the cache, and whether it requires
+// the stored response to be revalidated.
+func requestDirectives(req *http.Request) (cacheable bool, revalidate bool) {
+	cc := parseCacheControl(req.Header)
+	if cc.has("no-store") {
+		return false, false
+	}
+	if cc.has("no-cache") || (len(cc) == 0 && req.Header.Get("Pragma") == "no-cache") {
+		return true, true
+	}
+	if maxAge, ok := cc.seconds("max-age"); ok && maxAge == 0 {
+		return true, true
+	}
+	return true, false
+}
+
+// freshness returns how long a response stays fresh in a shared cache, and whether it may be stored:
+// a response which must be revalidated before each use is stored only if it has a validator, and the
+// response to an authorized request only if it allows it explicitly (RFC 7234 section 3.2).
+func freshness(status int, header http.Header, defaultTTL time.Duration, authorized bool) (time.Duration, bool) {
+	if !cacheableStatus[status] || header.Get("Set-Cookie") != "" || header.Get("Vary") == "*" {
+		return 0, false
+	}
+	cc := parseCacheControl(header)
+	if cc.has("no-store") || cc.has("private") {
+		return 0, false
+	}
+	if authorized && !cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+		return 0, false
+	}
+
+	var ttl time.Duration
+	explicit := true
+	if sMaxAge, ok := cc.seconds("s-maxage"); ok {
+		ttl = sMaxAge
+	} else if maxAge, ok := cc.seconds("max-age"); ok {
+		ttl = maxAge
+	} else if expires := header.Get("Expires"); expires != "" {
+		// an invalid date, such as 0, means already expired
+		if expiresAt, err := http.ParseTime(expires); err == nil {
+			date, err := http.ParseTime(header.Get("Date"))
+			if err != nil {
+				date = time.Now()
+			}
+			ttl = expiresAt.Sub(date)
+		}
+	} else {
+		ttl = defaultTTL
+		explicit = defaultTTL > 0 || cc.has("no-cache")
+	}
+	if cc.has("no-cache") || ttl < 0 {
+		ttl = 0
+	}
+
+	if ttl == 0 {
+		return 0, explicit && hasValidator(header)
+	}
+	return ttl, true
+}
+
+// initialAge returns the age of a response when it was received, from its Age header.
+func initialAge(header http.Header) time.Duration {
+	age, err := strconv.ParseInt(header.Get("Age"), 10, 64)
+	if err != nil || age < 0 {
+		return 0
+	}
+	return time.Duration(age) * time.Second
+}
+
+func hasValidator(header http.Header) bool {
+	return header.Get("ETag") != "" || header.Get("Last-Modified") != ""
+}
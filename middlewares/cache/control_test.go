@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	header := http.Header{"Cache-Control": {`public, Max-Age=60`, `no-cache="Set-Cookie"`}}
+	cc := parseCacheControl(header)
+	assert.Equal(t, cacheControl{"public": "", "max-age": "60", "no-cache": "Set-Cookie"}, cc)
+
+	maxAge, ok := cc.seconds("max-age")
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, maxAge)
+	_, ok = cc.seconds("s-maxage")
+	assert.False(t, ok)
+}
+
+func TestRequestDirectives(t *testing.T) {
+	tests := []struct {
+		desc           string
+		header         http.Header
+		wantCacheable  bool
+		wantRevalidate bool
+	}{
+		{desc: "no directives", wantCacheable: true},
+		{desc: "no-store", header: http.Header{"Cache-Control": {"no-store"}}},
+		{desc: "no-cache", header: http.Header{"Cache-Control": {"no-cache"}}, wantCacheable: true, wantRevalidate: true},
+		{desc: "max-age=0", header: http.Header{"Cache-Control": {"max-age=0"}}, wantCacheable: true, wantRevalidate: true},
+		{desc: "max-age", header: http.Header{"Cache-Control": {"max-age=60"}}, wantCacheable: true},
+		{desc: "pragma", header: http.Header{"Pragma": {"no-cache"}}, wantCacheable: true, wantRevalidate: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "/api", nil)
+			req.Header = test.header
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			cacheable, revalidate := requestDirectives(req)
+			assert.Equal(t, test.wantCacheable, cacheable)
+			assert.Equal(t, test.wantRevalidate, revalidate)
+		})
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	date := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc       string
+		status     int
+		header     http.Header
+		defaultTTL time.Duration
+		authorized bool
+		wantTTL    time.Duration
+		wantStore  bool
+	}{
+		{
+			desc:      "max-age",
+			header:    http.Header{"Cache-Control": {"max-age=60"}},
+			wantTTL:   time.Minute,
+			wantStore: true,
+		},
+		{
+			desc:      "s-maxage over max-age",
+			header:    http.Header{"Cache-Control": {"max-age=60, s-maxage=10"}},
+			wantTTL:   10 * time.Second,
+			wantStore: true,
+		},
+		{
+			desc: "expires",
+			header: http.Header{
+				"Date":    {date.Format(http.TimeFormat)},
+				"Expires": {date.Add(time.Hour).Format(http.TimeFormat)},
+			},
+			wantTTL:   time.Hour,
+			wantStore: true,
+		},
+		{
+			desc:   "invalid expires",
+			header: http.Header{"Expires": {"0"}},
+		},
+		{
+			desc:       "default TTL",
+			defaultTTL: time.Minute,
+			wantTTL:    time.Minute,
+			wantStore:  true,
+		},
+		{
+			desc: "no explicit freshness",
+		},
+		{
+			desc:   "no explicit freshness with a validator",
+			header: http.Header{"Etag": {`"v1"`}},
+		},
+		{
+			desc:      "no-cache with a validator",
+			header:    http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}},
+			wantStore: true,
+		},
+		{
+			desc:   "no-cache without validator",
+			header: http.Header{"Cache-Control": {"no-cache"}},
+		},
+		{
+			desc:       "no-store",
+			header:     http.Header{"Cache-Control": {"no-store"}},
+			defaultTTL: time.Minute,
+		},
+		{
+			desc:       "private",
+			header:     http.Header{"Cache-Control": {"private, max-age=60"}},
+			defaultTTL: time.Minute,
+		},
+		{
+			desc:   "cookie",
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"session=abc"}},
+		},
+		{
+			desc:   "vary on everything",
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}},
+		},
+		{
+			desc:   "status not cacheable",
+			status: http.StatusInternalServerError,
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+		},
+		{
+			desc:       "authorized",
+			header:     http.Header{"Cache-Control": {"max-age=60"}},
+			defaultTTL: time.Minute,
+			authorized: true,
+		},
+		{
+			desc:       "authorized public",
+			header:     http.Header{"Cache-Control": {"public, max-age=60"}},
+			authorized: true,
+			wantTTL:    time.Minute,
+			wantStore:  true,
+		},
+		{
+			desc:       "authorized s-maxage",
+			header:     http.Header{"Cache-Control": {"s-maxage=60"}},
+			authorized: true,
+			wantTTL:    time.Minute,
+			wantStore:  true,
+		},
+		{
+			desc:       "authorized must-revalidate",
+			header:     http.Header{"Cache-Control": {"must-revalidate, max-age=60"}},
+			authorized: true,
+			wantTTL:    time.Minute,
+			wantStore:  true,
+		},
+		{
+			desc:      "not found",
+			status:    http.StatusNotFound,
+			header:    http.Header{"Cache-Control": {"max-age=60"}},
+			wantTTL:   time.Minute,
+			wantStore: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			status := test.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			header := test.header
+			if header == nil {
+				header = http.Header{}
+			}
+			ttl, store := freshness(status, header, test.defaultTTL, test.authorized)
+			assert.Equal(t, test.wantStore, store)
+			if test.wantStore {
+				assert.Equal(t, test.wantTTL, ttl)
+			}
+		})
+	}
+}
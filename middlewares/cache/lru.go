@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// entry is a stored response, never modified once in the cache.
+type entry struct {
+	key    string
+	status int
+	header http.Header
+	body   []byte
+	// vary holds the values of the request headers named by the Vary header of the response
+	vary map[string]string
+	// stored is when the response was received, and age its age at that time
+	stored    time.Time
+	age       time.Duration
+	freshness time.Duration
+	size      int64
+}
+
+// currentAge returns the age of the response.
+func (e *entry) currentAge(now time.Time) time.Duration {
+	return e.age + now.Sub(e.stored)
+}
+
+func (e *entry) fresh(now time.Time) bool {
+	return e.currentAge(now) < e.freshness
+}
+
+// matches returns whether the response was stored for a request with the same varying headers.
+func (e *entry) matches(req *http.Request) bool {
+	for name, value := range e.vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// entrySize estimates the memory used by a stored response.
+func entrySize(e *entry) int64 {
+	size := int64(len(e.key) + len(e.body))
+	for name, values := range e.header {
+		for _, value := range values {
+			size += int64(len(name) + len(value))
+		}
+	}
+	for name, value := range e.vary {
+		size += int64(len(name) + len(value))
+	}
+	return size
+}
+
+// lru holds the stored responses up to a size in bytes, evicting the least recently used ones.
+type lru struct {
+	mux     sync.Mutex
+	maxSize int64
+	size    int64
+	list    *list.List
+	entries map[string]*list.Element
+}
+
+func newLRU(maxSize int64) *lru {
+	return &lru{
+		maxSize: maxSize,
+		list:    list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *lru) get(key string) *entry {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.list.MoveToFront(element)
+		return element.Value.(*entry)
+	}
+	return nil
+}
+
+// add stores the entry in place of the one with the same key, if it fits in the cache.
+func (c *lru) add(e *entry) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.removeElement(c.entries[e.key])
+	if e.size > c.maxSize {
+		return
+	}
+	c.entries[e.key] = c.list.PushFront(e)
+	c.size += e.size
+	for c.size > c.maxSize {
+		c.removeElement(c.list.Back())
+	}
+}
+
+func (c *lru) remove(key string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.removeElement(c.entries[key])
+}
+
+func (c *lru) removeElement(element *list.Element) {
+	if element == nil {
+		return
+	}
+	e := c.list.Remove(element).(*entry)
+	delete(c.entries, e.key)
+	c.size -= e.size
+}
+
+// stats returns the number and size of the stored responses.
+func (c *lru) stats() (int, int64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.list.Len(), c.size
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEntry(key string, size int64) *entry {
+	return &entry{key: key, size: size}
+}
+
+func TestLRU(t *testing.T) {
+	c := newLRU(100)
+	c.add(newTestEntry("a", 40))
+	c.add(newTestEntry("b", 40))
+	count, size := c.stats()
+	assert.Equal(t, 2, count)
+	assert.EqualValues(t, 80, size)
+
+	// a is used, so b is evicted first
+	assert.NotNil(t, c.get("a"))
+	c.add(newTestEntry("c", 40))
+	assert.Nil(t, c.get("b"))
+	assert.NotNil(t, c.get("a"))
+	assert.NotNil(t, c.get("c"))
+
+	// replacing an entry updates the size
+	c.add(newTestEntry("a", 10))
+	_, size = c.stats()
+	assert.EqualValues(t, 50, size)
+
+	// an entry larger than the cache is not stored, and removes the previous one
+	c.add(newTestEntry("a", 200))
+	assert.Nil(t, c.get("a"))
+	count, size = c.stats()
+	assert.Equal(t, 1, count)
+	assert.EqualValues(t, 40, size)
+
+	c.remove("c")
+	c.remove("unknown")
+	count, size = c.stats()
+	assert.Zero(t, count)
+	assert.Zero(t, size)
+}
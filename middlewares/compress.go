@@ -15,7 +15,7 @@ type Compress struct{}
 // ServerHTTP is a function used by Negroni
 func (c *Compress) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	contentType := r.Header.Get("Content-Type")
-	if strings.HasPrefix(contentType, "application/grpc") {
+	if strings.HasPrefix(contentType, "application/grpc") || IsUpgradeRequest(r) {
 		next.ServeHTTP(rw, r)
 	} else {
 		gzipHandler(next).ServeHTTP(rw, r)
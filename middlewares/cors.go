@@ -0,0 +1,238 @@
+package middlewares
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+)
+
+var defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+// CORSOptions is a struct for specifying configuration options for the CORS middleware.
+type CORSOptions struct {
+	// AllowOrigins are the allowed origins, '*' matching any origin or any part of an origin (e.g. https://*.example.com), at most once per origin
+	AllowOrigins []string
+	// AllowMethods are the methods allowed by preflight requests, GET, HEAD and POST by default
+	AllowMethods []string
+	// AllowHeaders are the request headers allowed by preflight requests, '*' allowing any header
+	AllowHeaders []string
+	// ExposeHeaders are the response headers readable by the browser
+	ExposeHeaders []string
+	// AllowCredentials allows requests with cookies or authorization headers
+	AllowCredentials bool
+	// MaxAge is how long the result of a preflight request may be cached (in seconds)
+	MaxAge int64
+}
+
+// CORS is a middleware answering the preflight requests and setting the CORS headers of the responses,
+// replacing the ones returned by the backends so that they are consistent whatever the backend.
+type CORS struct {
+	opt CORSOptions
+}
+
+// NewCORS constructs a new CORS instance from the CORS configuration of an entry point.
+// The allowed origins may hold at most one '*'.
+func NewCORS(config *types.CORS) (*CORS, error) {
+	if config == nil || len(config.AllowOrigins) == 0 {
+		return nil, nil
+	}
+	for _, origin := range config.AllowOrigins {
+		if strings.Count(origin, "*") > 1 {
+			return nil, fmt.Errorf("invalid CORS origin %q: only one '*' is allowed", origin)
+		}
+	}
+
+	opt := CORSOptions{
+		AllowOrigins:     config.AllowOrigins,
+		AllowMethods:     config.AllowMethods,
+		AllowHeaders:     config.AllowHeaders,
+		ExposeHeaders:    config.ExposeHeaders,
+		AllowCredentials: config.AllowCredentials,
+		MaxAge:           config.MaxAge,
+	}
+	if len(opt.AllowMethods) == 0 {
+		opt.AllowMethods = defaultCORSMethods
+	}
+	return &CORS{opt: opt}, nil
+}
+
+// NewCORSFromStruct constructs a new CORS instance from supplied header struct.
+func NewCORSFromStruct(headers *types.Headers) (*CORS, error) {
+	if headers == nil || !headers.HasCORSHeadersDefined() {
+		return nil, nil
+	}
+
+	return NewCORS(&types.CORS{
+		AllowOrigins:     headers.AccessControlAllowOrigins,
+		AllowMethods:     headers.AccessControlAllowMethods,
+		AllowHeaders:     headers.AccessControlAllowHeaders,
+		ExposeHeaders:    headers.AccessControlExposeHeaders,
+		AllowCredentials: headers.AccessControlAllowCredentials,
+		MaxAge:           headers.AccessControlMaxAge,
+	})
+}
+
+func (c *CORS) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		next(w, r)
+		return
+	}
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		c.servePreflight(w, r, origin)
+		return
+	}
+	next(&corsResponseWriter{ResponseWriter: w, cors: c, origin: origin}, r)
+}
+
+// servePreflight answers the preflight request without forwarding it to a backend.
+func (c *CORS) servePreflight(w http.ResponseWriter, r *http.Request, origin string) {
+	method := r.Header.Get("Access-Control-Request-Method")
+	var requestHeaders []string
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			requestHeaders = append(requestHeaders, http.CanonicalHeaderKey(header))
+		}
+	}
+
+	if !c.allowedOrigin(origin) || !contains(c.opt.AllowMethods, method) || !c.allowedHeaders(requestHeaders) {
+		log.Debugf("Preflight request from origin %s for %s %v not allowed", origin, method, requestHeaders)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	c.setOriginHeaders(w.Header(), origin)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.opt.AllowMethods, ", "))
+	if len(requestHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
+	}
+	if c.opt.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.FormatInt(c.opt.MaxAge, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// modifyResponseHeaders replaces the CORS headers of the response by the configured ones
+func (c *CORS) modifyResponseHeaders(header http.Header, origin string) {
+	for name := range header {
+		if strings.HasPrefix(name, "Access-Control-") {
+			header.Del(name)
+		}
+	}
+	if !c.allowedOrigin(origin) {
+		return
+	}
+	c.setOriginHeaders(header, origin)
+	if len(c.opt.ExposeHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(c.opt.ExposeHeaders, ", "))
+	}
+}
+
+func (c *CORS) setOriginHeaders(header http.Header, origin string) {
+	// credentials are not allowed with the '*' origin
+	if contains(c.opt.AllowOrigins, "*") && !c.opt.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+	}
+	if c.opt.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *CORS) allowedOrigin(origin string) bool {
+	for _, allowed := range c.opt.AllowOrigins {
+		if matchOrigin(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) allowedHeaders(headers []string) bool {
+	if contains(c.opt.AllowHeaders, "*") {
+		return true
+	}
+	for _, header := range headers {
+		allowed := false
+		for _, allowedHeader := range c.opt.AllowHeaders {
+			if strings.EqualFold(header, allowedHeader) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// matchOrigin matches the origin with a pattern holding at most one '*'.
+func matchOrigin(pattern, origin string) bool {
+	i := strings.Index(pattern, "*")
+	if i == -1 {
+		return strings.EqualFold(pattern, origin)
+	}
+	prefix, suffix := strings.ToLower(pattern[:i]), strings.ToLower(pattern[i+1:])
+	origin = strings.ToLower(origin)
+	return len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// corsResponseWriter sets the CORS headers when the response headers are written.
+type corsResponseWriter struct {
+	http.ResponseWriter
+	cors        *CORS
+	origin      string
+	wroteHeader bool
+}
+
+func (w *corsResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.cors.modifyResponseHeaders(w.ResponseWriter.Header(), w.origin)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *corsResponseWriter) Write(buf []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(buf)
+}
+
+// Hijack hijacks the connection
+func (w *corsResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (w *corsResponseWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush sends any buffered data to the client.
+func (w *corsResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
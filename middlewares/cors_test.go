@@ -0,0 +1,205 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCORSFromStruct(t *testing.T) {
+	cors, err := NewCORSFromStruct(nil)
+	require.NoError(t, err)
+	assert.Nil(t, cors)
+	cors, err = NewCORSFromStruct(&types.Headers{AccessControlAllowMethods: []string{http.MethodGet}})
+	require.NoError(t, err)
+	assert.Nil(t, cors)
+
+	cors, err = NewCORSFromStruct(&types.Headers{AccessControlAllowOrigins: []string{"*"}})
+	require.NoError(t, err)
+	require.NotNil(t, cors)
+	assert.Equal(t, defaultCORSMethods, cors.opt.AllowMethods)
+
+	_, err = NewCORSFromStruct(&types.Headers{AccessControlAllowOrigins: []string{"https://openshift.io", "https://*.*.openshift.io"}})
+	assert.Error(t, err)
+}
+
+func TestNewCORS(t *testing.T) {
+	cors, err := NewCORS(nil)
+	require.NoError(t, err)
+	assert.Nil(t, cors)
+	cors, err = NewCORS(&types.CORS{AllowMethods: []string{http.MethodGet}})
+	require.NoError(t, err)
+	assert.Nil(t, cors)
+
+	cors, err = NewCORS(&types.CORS{AllowOrigins: []string{"https://openshift.io"}, AllowMethods: []string{http.MethodPut}, MaxAge: 600})
+	require.NoError(t, err)
+	require.NotNil(t, cors)
+	assert.Equal(t, CORSOptions{AllowOrigins: []string{"https://openshift.io"}, AllowMethods: []string{http.MethodPut}, MaxAge: 600}, cors.opt)
+
+	_, err = NewCORS(&types.CORS{AllowOrigins: []string{"**"}})
+	assert.Error(t, err)
+}
+
+func TestCORSPreflight(t *testing.T) {
+	cors, err := NewCORSFromStruct(&types.Headers{
+		AccessControlAllowOrigins:     []string{"https://*.openshift.io"},
+		AccessControlAllowMethods:     []string{http.MethodGet, http.MethodDelete},
+		AccessControlAllowHeaders:     []string{"Authorization", "Content-Type"},
+		AccessControlAllowCredentials: true,
+		AccessControlMaxAge:           600,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc           string
+		origin         string
+		method         string
+		headers        string
+		expectedStatus int
+		expectedHeader http.Header
+	}{
+		{
+			desc:           "origin not allowed",
+			origin:         "https://openshift.io.example.com",
+			method:         http.MethodDelete,
+			headers:        "authorization, content-type",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			desc:           "allowed origin, method and headers",
+			origin:         "https://che.openshift.io",
+			method:         http.MethodDelete,
+			headers:        "authorization, content-type",
+			expectedStatus: http.StatusNoContent,
+			expectedHeader: http.Header{
+				"Access-Control-Allow-Origin":      {"https://che.openshift.io"},
+				"Access-Control-Allow-Credentials": {"true"},
+				"Access-Control-Allow-Methods":     {"GET, DELETE"},
+				"Access-Control-Allow-Headers":     {"Authorization, Content-Type"},
+				"Access-Control-Max-Age":           {"600"},
+				"Vary":                             {"Origin"},
+			},
+		},
+		{
+			desc:           "method not allowed",
+			origin:         "https://che.openshift.io",
+			method:         http.MethodPut,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			desc:           "header not allowed",
+			origin:         "https://che.openshift.io",
+			method:         http.MethodGet,
+			headers:        "X-Custom",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			req := testhelpers.MustNewRequest(http.MethodOptions, "http://proxy.openshift.io/api/v1/namespaces", nil)
+			req.Header.Set("Origin", test.origin)
+			req.Header.Set("Access-Control-Request-Method", test.method)
+			if test.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", test.headers)
+			}
+
+			res := httptest.NewRecorder()
+			cors.ServeHTTP(res, req, func(w http.ResponseWriter, r *http.Request) {
+				t.Fatal("preflight request should not be forwarded")
+			})
+
+			assert.Equal(t, test.expectedStatus, res.Code)
+			if test.expectedHeader != nil {
+				assert.Equal(t, test.expectedHeader, res.Header())
+			} else {
+				assert.Empty(t, res.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+}
+
+func TestCORSResponse(t *testing.T) {
+	backend := func(w http.ResponseWriter, r *http.Request) {
+		// CORS headers returned by the backend are replaced
+		w.Header().Set("Access-Control-Allow-Origin", "https://console.starter-us-east-2.openshift.com")
+		w.Header().Set("Access-Control-Allow-Methods", "PATCH")
+		w.Write([]byte("bar"))
+	}
+
+	tests := []struct {
+		desc           string
+		headers        *types.Headers
+		origin         string
+		expectedHeader http.Header
+	}{
+		{
+			desc:    "any origin",
+			headers: &types.Headers{AccessControlAllowOrigins: []string{"*"}, AccessControlExposeHeaders: []string{"X-Retry-In"}},
+			origin:  "https://che.openshift.io",
+			expectedHeader: http.Header{
+				"Access-Control-Allow-Origin":   {"*"},
+				"Access-Control-Expose-Headers": {"X-Retry-In"},
+			},
+		},
+		{
+			desc:    "any origin with credentials",
+			headers: &types.Headers{AccessControlAllowOrigins: []string{"*"}, AccessControlAllowCredentials: true},
+			origin:  "https://che.openshift.io",
+			expectedHeader: http.Header{
+				"Access-Control-Allow-Origin":      {"https://che.openshift.io"},
+				"Access-Control-Allow-Credentials": {"true"},
+				"Vary":                             {"Origin"},
+			},
+		},
+		{
+			desc:           "origin not allowed",
+			headers:        &types.Headers{AccessControlAllowOrigins: []string{"https://openshift.io"}},
+			origin:         "https://che.openshift.io",
+			expectedHeader: http.Header{},
+		},
+		{
+			desc:    "no origin",
+			headers: &types.Headers{AccessControlAllowOrigins: []string{"*"}},
+			expectedHeader: http.Header{
+				"Access-Control-Allow-Origin":  {"https://console.starter-us-east-2.openshift.com"},
+				"Access-Control-Allow-Methods": {"PATCH"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			req := testhelpers.MustNewRequest(http.MethodGet, "http://proxy.openshift.io/api/v1/namespaces", nil)
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+
+			cors, err := NewCORSFromStruct(test.headers)
+			require.NoError(t, err)
+			res := httptest.NewRecorder()
+			cors.ServeHTTP(res, req, backend)
+
+			assert.Equal(t, http.StatusOK, res.Code)
+			assert.Equal(t, "bar", res.Body.String())
+			res.Header().Del("Content-Type")
+			assert.Equal(t, test.expectedHeader, res.Header())
+		})
+	}
+}
+
+func TestMatchOrigin(t *testing.T) {
+	assert.True(t, matchOrigin("*", "https://che.openshift.io"))
+	assert.True(t, matchOrigin("https://che.openshift.io", "https://CHE.openshift.io"))
+	assert.True(t, matchOrigin("https://*.openshift.io", "https://che.prod-preview.openshift.io"))
+	assert.False(t, matchOrigin("https://*.openshift.io", "https://openshift.io"))
+	assert.False(t, matchOrigin("https://*.openshift.io", "http://che.openshift.io"))
+	assert.False(t, matchOrigin("https://che.openshift.io", "https://che.openshift.io.example.com"))
+}
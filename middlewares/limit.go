@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vulcand/oxy/connlimit"
+	"github.com/vulcand/oxy/ratelimit"
+	"github.com/vulcand/oxy/utils"
+)
+
+const (
+	// StatusTooManyRequests is sent when a rate or connection limit is reached.
+	StatusTooManyRequests = 429
+	retryAfterHeader      = "Retry-After"
+	retryInPrefix         = "retry-in "
+)
+
+// RateLimitErrorHandler answers requests refused by the rate limiter with 429 and a Retry-After header
+// holding the number of seconds until the request would be accepted.
+type RateLimitErrorHandler struct{}
+
+func (RateLimitErrorHandler) ServeHTTP(w http.ResponseWriter, req *http.Request, err error) {
+	if _, ok := err.(*ratelimit.MaxRateError); !ok {
+		utils.DefaultHandler.ServeHTTP(w, req, err)
+		return
+	}
+	// the delay is not exported by oxy, it is only part of the error message
+	delay := time.Second
+	if ind := strings.LastIndex(err.Error(), retryInPrefix); ind != -1 {
+		if d, errParse := time.ParseDuration(err.Error()[ind+len(retryInPrefix):]); errParse == nil {
+			delay = d
+		}
+	}
+	w.Header().Set("X-Retry-In", delay.String())
+	w.Header().Set(retryAfterHeader, retryAfterSeconds(delay))
+	w.WriteHeader(StatusTooManyRequests)
+	w.Write([]byte(err.Error()))
+}
+
+// ConnLimitErrorHandler answers requests refused by the connection limiter with 429 and a Retry-After header.
+type ConnLimitErrorHandler struct {
+	RetryAfter time.Duration
+}
+
+func (h ConnLimitErrorHandler) ServeHTTP(w http.ResponseWriter, req *http.Request, err error) {
+	if _, ok := err.(*connlimit.MaxConnError); !ok {
+		utils.DefaultHandler.ServeHTTP(w, req, err)
+		return
+	}
+	w.Header().Set(retryAfterHeader, retryAfterSeconds(h.RetryAfter))
+	w.WriteHeader(StatusTooManyRequests)
+	w.Write([]byte(err.Error()))
+}
+
+// retryAfterSeconds rounds the delay up to whole seconds, as required by the Retry-After header.
+func retryAfterSeconds(delay time.Duration) string {
+	seconds := int64(math.Ceil(delay.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
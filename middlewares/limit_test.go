@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/connlimit"
+	"github.com/vulcand/oxy/ratelimit"
+	"github.com/vulcand/oxy/utils"
+)
+
+func TestRateLimitErrorHandler(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+	extractor, err := utils.NewExtractor("client.ip")
+	require.NoError(t, err)
+	rateSet := ratelimit.NewRateSet()
+	require.NoError(t, rateSet.Add(10*time.Second, 1, 1))
+	limiter, err := ratelimit.New(next, extractor, rateSet, ratelimit.ErrorHandler(RateLimitErrorHandler{}))
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	limiter.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	rw = httptest.NewRecorder()
+	limiter.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+	assert.Equal(t, StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "10", rw.Header().Get("Retry-After"))
+	assert.NotEmpty(t, rw.Header().Get("X-Retry-In"))
+}
+
+func TestConnLimitErrorHandler(t *testing.T) {
+	rw := httptest.NewRecorder()
+	ConnLimitErrorHandler{RetryAfter: 1500 * time.Millisecond}.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://localhost/", nil), &connlimit.MaxConnError{})
+	assert.Equal(t, StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "2", rw.Header().Get("Retry-After"))
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                       "1",
+		100 * time.Millisecond:  "1",
+		time.Second:             "1",
+		1001 * time.Millisecond: "2",
+		time.Minute:             "60",
+	}
+	for delay, expected := range tests {
+		assert.Equal(t, expected, retryAfterSeconds(delay), "delay=%s", delay)
+	}
+}
@@ -0,0 +1,264 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	gokitmetrics "github.com/go-kit/kit/metrics"
+)
+
+const (
+	// DefaultMirrorMaxBodySize is the default size of the largest request body mirrored.
+	DefaultMirrorMaxBodySize = 1 << 20
+	// maxMirroredRequests bounds the mirrored requests in flight, the next ones being dropped.
+	maxMirroredRequests = 100
+	// mirrorTimeout bounds the duration of a mirrored request.
+	mirrorTimeout = 30 * time.Second
+)
+
+// mirrorMetrics is the part of metrics.Registry recording the mirrored requests.
+type mirrorMetrics interface {
+	BackendMirrorReqsCounter() gokitmetrics.Counter
+	BackendMirrorReqDurationHistogram() gokitmetrics.Histogram
+}
+
+// Mirror sends a copy of a share of the requests to a shadow backend, asynchronously,
+// the responses of the shadow backend being discarded.
+type Mirror struct {
+	// BackendName is the name of the shadow backend handler given to PostLoad
+	BackendName string
+	// Fallback is the shadow backend handler used when there is no handler named BackendName
+	Fallback    http.Handler
+	shadow      http.Handler
+	backend     string
+	percent     int
+	methods     map[string]bool
+	maxBodySize int64
+	inflight    chan struct{}
+
+	reqsCounter       gokitmetrics.Counter
+	durationHistogram gokitmetrics.Histogram
+}
+
+// NewMirror creates the mirror of a frontend, the defaults applying to the unset options:
+// all the GET and HEAD requests with a body of at most DefaultMirrorMaxBodySize are mirrored.
+func NewMirror(config *types.Mirror, backendName string, registry mirrorMetrics) (*Mirror, error) {
+	if config.Backend == "" {
+		return nil, fmt.Errorf("missing mirror backend")
+	}
+	percent := config.Percent
+	if percent == 0 {
+		percent = 100
+	}
+	if percent < 0 || percent > 100 {
+		return nil, fmt.Errorf("invalid mirror percent %d", config.Percent)
+	}
+	maxBodySize := config.MaxBodySize
+	if maxBodySize == 0 {
+		maxBodySize = DefaultMirrorMaxBodySize
+	}
+	if maxBodySize < 0 {
+		return nil, fmt.Errorf("invalid mirror max body size %d", config.MaxBodySize)
+	}
+	methods := config.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead}
+	}
+
+	m := &Mirror{
+		BackendName:       backendName,
+		backend:           config.Backend,
+		percent:           percent,
+		methods:           make(map[string]bool),
+		maxBodySize:       maxBodySize,
+		inflight:          make(chan struct{}, maxMirroredRequests),
+		reqsCounter:       registry.BackendMirrorReqsCounter(),
+		durationHistogram: registry.BackendMirrorReqDurationHistogram(),
+	}
+	for _, method := range methods {
+		m.methods[strings.ToUpper(method)] = true
+	}
+	return m, nil
+}
+
+// PostLoad sets the shadow backend handler, the fallback one if nil.
+func (m *Mirror) PostLoad(shadow http.Handler) {
+	if shadow == nil {
+		shadow = m.Fallback
+	}
+	m.shadow = shadow
+}
+
+func (m *Mirror) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	if m.shadow == nil || !m.methods[req.Method] || req.Header.Get("Upgrade") != "" || rand.Intn(100) >= m.percent {
+		next.ServeHTTP(rw, req)
+		return
+	}
+	if req.ContentLength > m.maxBodySize {
+		log.Debugf("Request body too large to be mirrored to backend %s", m.backend)
+		next.ServeHTTP(rw, req)
+		return
+	}
+
+	select {
+	case m.inflight <- struct{}{}:
+	default:
+		log.Debugf("Too many requests mirrored to backend %s, dropping the request", m.backend)
+		m.reqsCounter.With("backend", m.backend, "code", "dropped").Add(1)
+		next.ServeHTTP(rw, req)
+		return
+	}
+
+	// the copy is made before the request goes through the next handlers, which may alter it
+	mirrored := newMirroredRequest(req)
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		m.send(mirrored, nil)
+		next.ServeHTTP(rw, req)
+		return
+	}
+
+	// the body is copied while the next handlers read it, the request being mirrored once it is read
+	body := &mirrorBody{ReadCloser: req.Body, size: req.ContentLength, maxSize: m.maxBodySize, buf: &bytes.Buffer{}, done: func(body []byte, ok bool) {
+		if !ok {
+			log.Debugf("Request body too large or not read to be mirrored to backend %s", m.backend)
+			<-m.inflight
+			return
+		}
+		m.send(mirrored, body)
+	}}
+	req.Body = body
+	next.ServeHTTP(rw, req)
+	body.finish()
+}
+
+// newMirroredRequest copies the request without its body, detached from the client connection
+// so that it outlives it.
+func newMirroredRequest(req *http.Request) *http.Request {
+	mirrored := req.WithContext(context.Background())
+	mirrored.Header = make(http.Header, len(req.Header))
+	for name, values := range req.Header {
+		mirrored.Header[name] = append([]string(nil), values...)
+	}
+	u := *req.URL
+	if req.URL.User != nil {
+		user := *req.URL.User
+		u.User = &user
+	}
+	mirrored.URL = &u
+	mirrored.Body = http.NoBody
+	return mirrored
+}
+
+// send mirrors the request with its body in the background, the slot of the in flight requests
+// being taken.
+func (m *Mirror) send(req *http.Request, body []byte) {
+	if body != nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	go m.mirror(req.WithContext(ctx), cancel)
+}
+
+func (m *Mirror) mirror(req *http.Request, cancel context.CancelFunc) {
+	defer func() {
+		cancel()
+		<-m.inflight
+		if err := recover(); err != nil {
+			log.Errorf("Error mirroring request to backend %s: %v", m.backend, err)
+		}
+	}()
+
+	start := time.Now()
+	rw := &discardResponseWriter{header: make(http.Header), code: http.StatusOK}
+	m.shadow.ServeHTTP(rw, req)
+
+	m.reqsCounter.With("backend", m.backend, "code", strconv.Itoa(rw.code)).Add(1)
+	m.durationHistogram.With("backend", m.backend).Observe(time.Since(start).Seconds())
+}
+
+// mirrorBody copies a request body while it is read, up to maxSize, calling done once with
+// the whole body, or with ok false when it is larger or not read up to its end.
+type mirrorBody struct {
+	io.ReadCloser
+	// size is the length of the body, -1 if unknown
+	size    int64
+	maxSize int64
+	done    func(body []byte, ok bool)
+
+	mux sync.Mutex
+	buf *bytes.Buffer
+}
+
+func (b *mirrorBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.buf == nil {
+		return n, err
+	}
+	if int64(b.buf.Len()+n) > b.maxSize {
+		b.complete(false)
+		return n, err
+	}
+	b.buf.Write(p[:n])
+	if err == io.EOF || int64(b.buf.Len()) == b.size {
+		b.complete(true)
+	}
+	return n, err
+}
+
+// finish gives up on the body if it has not been read up to its end yet.
+func (b *mirrorBody) finish() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.buf != nil {
+		b.complete(false)
+	}
+}
+
+func (b *mirrorBody) complete(ok bool) {
+	var body []byte
+	if ok {
+		body = b.buf.Bytes()
+	}
+	b.buf = nil
+	b.done(body, ok)
+}
+
+// discardResponseWriter records the status code of a response and discards it.
+type discardResponseWriter struct {
+	header      http.Header
+	code        int
+	wroteHeader bool
+}
+
+func (rw *discardResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *discardResponseWriter) Write(buf []byte) (int, error) {
+	rw.wroteHeader = true
+	return len(buf), nil
+}
+
+func (rw *discardResponseWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.code = code
+		rw.wroteHeader = true
+	}
+}
+
+func (rw *discardResponseWriter) Flush() {}
@@ -0,0 +1,323 @@
+package middlewares
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirror(t *testing.T) {
+	tests := []struct {
+		desc         string
+		config       types.Mirror
+		method       string
+		body         string
+		header       http.Header
+		wantMirrored bool
+	}{
+		{
+			desc:         "GET mirrored by default",
+			method:       http.MethodGet,
+			wantMirrored: true,
+		},
+		{
+			desc:         "POST not mirrored by default",
+			method:       http.MethodPost,
+			body:         "data",
+			wantMirrored: false,
+		},
+		{
+			desc:         "POST mirrored with its body",
+			config:       types.Mirror{Methods: []string{"post"}},
+			method:       http.MethodPost,
+			body:         "data",
+			wantMirrored: true,
+		},
+		{
+			desc:         "body too large",
+			config:       types.Mirror{Methods: []string{http.MethodPost}, MaxBodySize: 3},
+			method:       http.MethodPost,
+			body:         "data",
+			wantMirrored: false,
+		},
+		{
+			desc:         "upgrade not mirrored",
+			method:       http.MethodGet,
+			header:       http.Header{"Upgrade": {"websocket"}},
+			wantMirrored: false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			shadow := newShadowBackend(http.StatusNotFound)
+			test.config.Backend = "shadow"
+			registry := newCollectingMirrorMetrics()
+			mirror, err := NewMirror(&test.config, "shadow", registry)
+			require.NoError(t, err)
+			mirror.PostLoad(shadow)
+
+			req := httptest.NewRequest(test.method, "/api/v1/namespaces?watch=false", strings.NewReader(test.body))
+			req.Header.Set("Authorization", "Bearer token")
+			for name, values := range test.header {
+				req.Header[name] = values
+			}
+			rw := httptest.NewRecorder()
+			mirror.ServeHTTP(rw, req, func(rw http.ResponseWriter, req *http.Request) {
+				// the primary gets the whole body, and may alter the request
+				body, err := ioutil.ReadAll(req.Body)
+				require.NoError(t, err)
+				assert.Equal(t, test.body, string(body))
+				req.Header.Set("Authorization", "changed")
+				req.URL.Path = "/changed"
+				rw.WriteHeader(http.StatusOK)
+			})
+			assert.Equal(t, http.StatusOK, rw.Code)
+
+			if !test.wantMirrored {
+				select {
+				case <-shadow.requests:
+					t.Fatal("unexpected mirrored request")
+				case <-time.After(50 * time.Millisecond):
+				}
+				return
+			}
+
+			var mirrored *http.Request
+			select {
+			case mirrored = <-shadow.requests:
+			case <-time.After(time.Second):
+				t.Fatal("request not mirrored")
+			}
+			assert.Equal(t, test.method, mirrored.Method)
+			assert.Equal(t, "/api/v1/namespaces?watch=false", mirrored.URL.RequestURI())
+			assert.Equal(t, "Bearer token", mirrored.Header.Get("Authorization"))
+			assert.Equal(t, test.body, shadow.body(mirrored))
+
+			waitForCount(t, registry, "shadow", "404", 1)
+		})
+	}
+}
+
+func TestMirrorBodyRead(t *testing.T) {
+	tests := []struct {
+		desc         string
+		body         string
+		read         bool
+		wantMirrored bool
+	}{
+		{
+			desc:         "body of unknown length mirrored once read",
+			body:         "data",
+			read:         true,
+			wantMirrored: true,
+		},
+		{
+			desc:         "body of unknown length too large",
+			body:         strings.Repeat("x", 11),
+			read:         true,
+			wantMirrored: false,
+		},
+		{
+			desc:         "body not read",
+			body:         "data",
+			read:         false,
+			wantMirrored: false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			shadow := newShadowBackend(http.StatusOK)
+			registry := newCollectingMirrorMetrics()
+			mirror, err := NewMirror(&types.Mirror{Backend: "shadow", Methods: []string{http.MethodPut}, MaxBodySize: 10}, "shadow", registry)
+			require.NoError(t, err)
+			mirror.PostLoad(shadow)
+
+			req := httptest.NewRequest(http.MethodPut, "/", ioutil.NopCloser(strings.NewReader(test.body)))
+			req.ContentLength = -1
+			mirror.ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, req *http.Request) {
+				if !test.read {
+					return
+				}
+				// nothing is mirrored before the primary reads the body
+				select {
+				case <-shadow.requests:
+					t.Fatal("request mirrored before its body is read")
+				case <-time.After(10 * time.Millisecond):
+				}
+				body, err := ioutil.ReadAll(req.Body)
+				require.NoError(t, err)
+				assert.Equal(t, test.body, string(body))
+			})
+
+			if !test.wantMirrored {
+				select {
+				case <-shadow.requests:
+					t.Fatal("unexpected mirrored request")
+				case <-time.After(50 * time.Millisecond):
+				}
+				assert.Len(t, mirror.inflight, 0)
+				return
+			}
+
+			select {
+			case mirrored := <-shadow.requests:
+				assert.Equal(t, test.body, shadow.body(mirrored))
+			case <-time.After(time.Second):
+				t.Fatal("request not mirrored")
+			}
+		})
+	}
+}
+
+func TestMirrorPercent(t *testing.T) {
+	shadow := newShadowBackend(http.StatusOK)
+	shadow.requests = make(chan *http.Request, 1000)
+	registry := newCollectingMirrorMetrics()
+	mirror, err := NewMirror(&types.Mirror{Backend: "shadow", Percent: 20}, "shadow", registry)
+	require.NoError(t, err)
+	mirror.PostLoad(shadow)
+
+	for i := 0; i < 1000; i++ {
+		mirror.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), func(http.ResponseWriter, *http.Request) {})
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(mirror.inflight) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	// the requests sampled beyond maxMirroredRequests in flight are dropped
+	assert.InDelta(t, 200, registry.count("shadow", "200")+registry.count("shadow", "dropped"), 60)
+}
+
+func TestMirrorNotDelayingPrimary(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	shadow := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-release })
+	registry := newCollectingMirrorMetrics()
+	mirror, err := NewMirror(&types.Mirror{Backend: "shadow"}, "shadow", registry)
+	require.NoError(t, err)
+	mirror.PostLoad(shadow)
+
+	// the shadow backend never answers: the requests beyond maxMirroredRequests are dropped
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < maxMirroredRequests+10; i++ {
+			mirror.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), func(http.ResponseWriter, *http.Request) {})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("primary requests delayed by the shadow backend")
+	}
+	assert.Equal(t, 10, registry.count("shadow", "dropped"))
+}
+
+func TestNewMirrorInvalid(t *testing.T) {
+	for _, config := range []types.Mirror{
+		{},
+		{Backend: "shadow", Percent: 101},
+		{Backend: "shadow", Percent: -1},
+		{Backend: "shadow", MaxBodySize: -1},
+	} {
+		_, err := NewMirror(&config, "shadow", newCollectingMirrorMetrics())
+		assert.Error(t, err, "%+v", config)
+	}
+}
+
+type shadowBackend struct {
+	status   int
+	requests chan *http.Request
+	mux      sync.Mutex
+	bodies   map[*http.Request]string
+}
+
+func newShadowBackend(status int) *shadowBackend {
+	return &shadowBackend{status: status, requests: make(chan *http.Request, 10), bodies: make(map[*http.Request]string)}
+}
+
+func (s *shadowBackend) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	s.mux.Lock()
+	s.bodies[req] = string(body)
+	s.mux.Unlock()
+	rw.WriteHeader(s.status)
+	rw.Write(bytes.Repeat([]byte("x"), 10))
+	s.requests <- req
+}
+
+func (s *shadowBackend) body(req *http.Request) string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.bodies[req]
+}
+
+// collectingMirrorMetrics counts the mirrored requests by label values.
+type collectingMirrorMetrics struct {
+	mux    sync.Mutex
+	counts map[string]int
+}
+
+func newCollectingMirrorMetrics() *collectingMirrorMetrics {
+	return &collectingMirrorMetrics{counts: make(map[string]int)}
+}
+
+func (m *collectingMirrorMetrics) BackendMirrorReqsCounter() metrics.Counter {
+	return &collectingMirrorCounter{metrics: m}
+}
+
+func (m *collectingMirrorMetrics) BackendMirrorReqDurationHistogram() metrics.Histogram {
+	return generic.NewHistogram("mirror", 10)
+}
+
+func (m *collectingMirrorMetrics) count(labelValues ...string) int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.counts[strings.Join(labelValues, ",")]
+}
+
+type collectingMirrorCounter struct {
+	metrics     *collectingMirrorMetrics
+	labelValues []string
+}
+
+func (c *collectingMirrorCounter) With(labelValues ...string) metrics.Counter {
+	var values []string
+	for i := 1; i < len(labelValues); i += 2 {
+		values = append(values, labelValues[i])
+	}
+	return &collectingMirrorCounter{metrics: c.metrics, labelValues: values}
+}
+
+func (c *collectingMirrorCounter) Add(delta float64) {
+	c.metrics.mux.Lock()
+	defer c.metrics.mux.Unlock()
+	c.metrics.counts[strings.Join(c.labelValues, ",")] += int(delta)
+}
+
+func waitForCount(t *testing.T, registry *collectingMirrorMetrics, backend, code string, want int) {
+	deadline := time.Now().Add(time.Second)
+	for registry.count(backend, code) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d mirrored requests with code %s, want %d", registry.count(backend, code), code, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
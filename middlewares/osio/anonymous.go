@@ -0,0 +1,102 @@
+package osio
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/containous/traefik/provider/osio"
+)
+
+// Authentication kinds of the requests counter.
+const (
+	authToken       = "token"
+	authPassthrough = "passthrough"
+	authAnonymous   = "anonymous"
+	authPreflight   = "preflight"
+)
+
+// defaultTarget is the Target of the default backend.
+const defaultTarget = "default"
+
+// anonymousRoute is the middleware side of an osio.AnonymousRoute.
+type anonymousRoute struct {
+	name    string
+	methods map[string]bool
+	path    string
+	prefix  bool
+	target  string
+}
+
+func (r *anonymousRoute) match(req *http.Request) bool {
+	if !r.methods[req.Method] {
+		return false
+	}
+	if r.prefix {
+		return strings.HasPrefix(req.URL.Path, r.path)
+	}
+	return req.URL.Path == r.path
+}
+
+// SetAnonymousRoutes sets the requests which reach a cluster without a token.
+func (a *OSIOAuth) SetAnonymousRoutes(routes []*osio.AnonymousRoute) error {
+	var anonymousRoutes []*anonymousRoute
+	for _, route := range routes {
+		if err := route.Validate(); err != nil {
+			return err
+		}
+		methods := route.Methods
+		if len(methods) == 0 {
+			methods = osio.DefaultAnonymousMethods
+		}
+		ar := &anonymousRoute{
+			name:    route.Name,
+			methods: make(map[string]bool),
+			path:    strings.TrimSuffix(route.Path, "*"),
+			prefix:  strings.HasSuffix(route.Path, "*"),
+			target:  defaultTarget,
+		}
+		for _, method := range methods {
+			ar.methods[method] = true
+		}
+		if route.Cluster != "" {
+			ar.target = normalizeURL(route.Cluster)
+		}
+		anonymousRoutes = append(anonymousRoutes, ar)
+	}
+	// exact paths first, then the longest prefixes
+	sort.SliceStable(anonymousRoutes, func(i, j int) bool {
+		if anonymousRoutes[i].prefix != anonymousRoutes[j].prefix {
+			return !anonymousRoutes[i].prefix
+		}
+		return len(anonymousRoutes[i].path) > len(anonymousRoutes[j].path)
+	})
+	a.anonymousRoutes = anonymousRoutes
+	return nil
+}
+
+func (a *OSIOAuth) getAnonymousRoute(req *http.Request) *anonymousRoute {
+	for _, route := range a.anonymousRoutes {
+		if route.match(req) {
+			return route
+		}
+	}
+	return nil
+}
+
+// serveAnonymous forwards the request to the route target without resolving any identity.
+// The credentials are removed, as the request is not meant to be authenticated.
+func (a *OSIOAuth) serveAnonymous(rw http.ResponseWriter, r *http.Request, route *anonymousRoute, next http.HandlerFunc) {
+	r.Header.Del(Authorization)
+	r.Header.Set("Target", route.target)
+	dbg := getDebugInfo(r)
+	dbg.set(DebugRouteHeader, route.name)
+	dbg.set(DebugTargetHeader, route.target)
+	next(rw, r)
+}
+
+func (a *OSIOAuth) countRequest(auth string) {
+	if a.RequestsCounter != nil {
+		a.RequestsCounter.With("auth", auth).Add(1)
+	}
+}
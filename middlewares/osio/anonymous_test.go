@@ -0,0 +1,127 @@
+package osio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/provider/osio"
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymousRoutes(t *testing.T) {
+	osioAuth := newIdentityTestAuth(UserToken)
+	counter := &testhelpers.CollectingCounter{}
+	osioAuth.RequestsCounter = counter
+	require.NoError(t, osioAuth.SetAnonymousRoutes([]*osio.AnonymousRoute{
+		{Name: "version", Path: "/version", Cluster: "https://api.cluster1.com/"},
+		{Name: "discovery", Path: "/.well-known/*"},
+		{Name: "oauth", Path: "/.well-known/oauth-authorization-server", Methods: []string{"GET", "OPTIONS"}, Cluster: "https://api.cluster2.com"},
+	}))
+
+	tests := []struct {
+		desc           string
+		method         string
+		path           string
+		token          bool
+		expectedTarget string
+		expectedAuth   string
+	}{
+		{
+			desc:           "exact path",
+			method:         http.MethodGet,
+			path:           "/version",
+			expectedTarget: "https://api.cluster1.com",
+			expectedAuth:   authAnonymous,
+		},
+		{
+			desc:           "credentials are removed",
+			method:         http.MethodHead,
+			path:           "/version",
+			token:          true,
+			expectedTarget: "https://api.cluster1.com",
+			expectedAuth:   authAnonymous,
+		},
+		{
+			desc:           "path prefix to the default backend",
+			method:         http.MethodGet,
+			path:           "/.well-known/openid-configuration",
+			expectedTarget: defaultTarget,
+			expectedAuth:   authAnonymous,
+		},
+		{
+			desc:           "exact path before path prefix",
+			method:         http.MethodOptions,
+			path:           "/.well-known/oauth-authorization-server",
+			expectedTarget: "https://api.cluster2.com",
+			expectedAuth:   authAnonymous,
+		},
+		{
+			desc:           "preflight of other paths",
+			method:         http.MethodOptions,
+			path:           "/version/extra",
+			expectedTarget: defaultTarget,
+			expectedAuth:   authPreflight,
+		},
+		{
+			desc:           "method not allowed anonymously",
+			method:         http.MethodPost,
+			path:           "/version",
+			token:          true,
+			expectedTarget: "http://api.cluster1.com",
+			expectedAuth:   authToken,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.path, nil)
+			if test.token {
+				req.Header.Set(Authorization, "Bearer "+createSubjectToken(t, "john"))
+			}
+
+			var forwarded *http.Request
+			osioAuth.ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req
+			})
+
+			require.NotNil(t, forwarded)
+			assert.Equal(t, test.expectedTarget, forwarded.Header.Get("Target"))
+			assert.Equal(t, []string{"auth", test.expectedAuth}, counter.LastLabelValues)
+			if test.expectedAuth == authAnonymous {
+				assert.Empty(t, forwarded.Header.Get(Authorization))
+				_, found := GetIdentity(forwarded)
+				assert.False(t, found)
+			}
+		})
+	}
+}
+
+func TestAnonymousRoutesWithoutToken(t *testing.T) {
+	osioAuth := newIdentityTestAuth(UserToken)
+	require.NoError(t, osioAuth.SetAnonymousRoutes([]*osio.AnonymousRoute{{Name: "version", Path: "/version"}}))
+
+	rw := httptest.NewRecorder()
+	osioAuth.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/healthz", nil), func(rw http.ResponseWriter, req *http.Request) {
+		t.Fatal("request without token should not be forwarded")
+	})
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+}
+
+func TestSetAnonymousRoutesInvalid(t *testing.T) {
+	osioAuth := newIdentityTestAuth(UserToken)
+	invalid := map[string]*osio.AnonymousRoute{
+		"no name":          {Path: "/version"},
+		"relative path":    {Name: "version", Path: "version"},
+		"inner wildcard":   {Name: "apis", Path: "/apis/*/version"},
+		"lowercase method": {Name: "version", Path: "/version", Methods: []string{"get"}},
+		"relative cluster": {Name: "version", Path: "/version", Cluster: "api.cluster1.com"},
+	}
+	for desc, route := range invalid {
+		assert.Error(t, osioAuth.SetAnonymousRoutes([]*osio.AnonymousRoute{route}), desc)
+	}
+	assert.Nil(t, osioAuth.anonymousRoutes)
+}
@@ -2,6 +2,7 @@ package osio
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 type Cache struct {
@@ -25,12 +26,61 @@ func (c *Cache) Get(key string, resolver Resolver) Promise {
 	return val
 }
 
+// Entries returns the values of the resolved entries by key.
+// Entries being resolved or whose resolution failed are left out.
+func (c *Cache) Entries() map[string]interface{} {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	entries := make(map[string]interface{})
+	for key, promise := range c.m {
+		if value, ok := resolvedValue(promise); ok {
+			entries[key] = value
+		}
+	}
+	return entries
+}
+
+// Evict removes the resolved entries for which match returns true and returns how many were removed.
+func (c *Cache) Evict(match func(key string, value interface{}) bool) int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	count := 0
+	for key, promise := range c.m {
+		if value, ok := resolvedValue(promise); ok && match(key, value) {
+			delete(c.m, key)
+			count++
+		}
+	}
+	return count
+}
+
+// Flush removes all entries and returns how many were removed.
+func (c *Cache) Flush() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	count := len(c.m)
+	c.m = make(map[string]Promise)
+	return count
+}
+
+func resolvedValue(promise Promise) (interface{}, bool) {
+	if rp, ok := promise.(*ResolverPromise); ok {
+		return rp.resolvedValue()
+	}
+	return nil, false
+}
+
 type ResolverPromise struct {
 	mux      sync.Mutex
 	resolver Resolver
 	resolved bool
 	value    interface{}
 	err      error
+	// result holds the value once resolved, so that it can be read without waiting for a resolution in progress
+	result atomic.Value
 }
 
 func (r *ResolverPromise) Get() (interface{}, error) {
@@ -53,11 +103,21 @@ func (r *ResolverPromise) Get() (interface{}, error) {
 
 	if r.err == nil {
 		r.resolved = true
+		r.result.Store(resolvedResult{value: r.value})
 	}
 
 	return r.value, r.err
 }
 
+type resolvedResult struct {
+	value interface{}
+}
+
+func (r *ResolverPromise) resolvedValue() (interface{}, bool) {
+	result, ok := r.result.Load().(resolvedResult)
+	return result.value, ok
+}
+
 type Resolver func() (interface{}, error)
 
 type Promise interface {
@@ -0,0 +1,98 @@
+package osio
+
+import (
+	"sort"
+	"time"
+)
+
+// Resolution paths of cache entries.
+const (
+	// ResolutionByToken entries are resolved from a user token.
+	ResolutionByToken = "token"
+	// ResolutionByID entries are resolved from a service token impersonating a user.
+	ResolutionByID = "user-id"
+)
+
+// CacheEntry describes a cache entry without exposing its tokens.
+type CacheEntry struct {
+	Key        string    `json:"key"`
+	Issuer     string    `json:"issuer,omitempty"`
+	User       string    `json:"user"`
+	Namespace  string    `json:"namespace"`
+	Cluster    string    `json:"cluster"`
+	Created    time.Time `json:"created"`
+	Age        string    `json:"age"`
+	Resolution string    `json:"resolution"`
+}
+
+// CacheFilter selects cache entries. Empty fields match any value.
+type CacheFilter struct {
+	User      string
+	Namespace string
+	Cluster   string
+}
+
+// IsEmpty reports whether the filter matches every entry.
+func (f CacheFilter) IsEmpty() bool {
+	return f.User == "" && f.Namespace == "" && f.Cluster == ""
+}
+
+func (f CacheFilter) match(data cacheData) bool {
+	return (f.User == "" || f.User == data.UserID) &&
+		(f.Namespace == "" || f.Namespace == data.NamespaceName) &&
+		(f.Cluster == "" || normalizeURL(f.Cluster) == normalizeURL(data.Namespace.ClusterURL))
+}
+
+// CacheEntries lists the resolved cache entries, oldest first.
+func (a *OSIOAuth) CacheEntries() []CacheEntry {
+	now := time.Now()
+	entries := []CacheEntry{}
+	for key, value := range a.cache.Entries() {
+		data, ok := value.(cacheData)
+		if !ok {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Key:        key,
+			Issuer:     data.Issuer,
+			User:       data.UserID,
+			Namespace:  data.NamespaceName,
+			Cluster:    data.Namespace.ClusterURL,
+			Created:    data.Created,
+			Age:        now.Sub(data.Created).Round(time.Second).String(),
+			Resolution: data.Resolution,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Created.Equal(entries[j].Created) {
+			return entries[i].Created.Before(entries[j].Created)
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
+// EvictCache removes the cache entries matching filter and returns how many were removed.
+// An empty filter removes nothing, use FlushCache to remove all entries.
+func (a *OSIOAuth) EvictCache(filter CacheFilter) int {
+	if filter.IsEmpty() {
+		return 0
+	}
+	count := a.cache.Evict(func(key string, value interface{}) bool {
+		data, ok := value.(cacheData)
+		return ok && filter.match(data)
+	})
+	if a.warmup != nil {
+		count += a.warmup.evict(filter.match)
+	}
+	return count
+}
+
+// FlushCache removes all cache entries and returns how many were removed.
+func (a *OSIOAuth) FlushCache() int {
+	count := a.cache.Flush()
+	if a.warmup != nil {
+		count += a.warmup.evict(func(cacheData) bool { return true })
+	}
+	return count
+}
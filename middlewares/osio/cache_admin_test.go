@@ -0,0 +1,45 @@
+package osio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheAdmin(t *testing.T) {
+	osioAuth := newIdentityTestAuth(CheToken)
+	serve := func(path, userID string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(Authorization, "Bearer "+createSubjectToken(t, "che"))
+		req.Header.Set(UserIDHeader, userID)
+		osioAuth.ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, req *http.Request) {})
+	}
+	serve("/api/v1/namespaces/john-preview-che/pods", "11111111")
+	serve("/api/v1/namespaces/jane-preview-che/pods", "22222222")
+	serve("/api/v1/namespaces/jane-preview-stage/pods", "22222222")
+
+	entries := osioAuth.CacheEntries()
+	require.Len(t, entries, 3)
+	for _, entry := range entries {
+		assert.Len(t, entry.Key, 64)
+		assert.Equal(t, "http://api.cluster1.com", entry.Cluster)
+		assert.Equal(t, ResolutionByID, entry.Resolution)
+		assert.NotEmpty(t, entry.Age)
+	}
+
+	assert.Equal(t, 0, osioAuth.EvictCache(CacheFilter{}))
+	assert.Equal(t, 0, osioAuth.EvictCache(CacheFilter{User: "33333333"}))
+	assert.Equal(t, 1, osioAuth.EvictCache(CacheFilter{User: "22222222", Namespace: "jane-preview-che"}))
+	assert.Equal(t, 1, osioAuth.EvictCache(CacheFilter{User: "22222222"}))
+	require.Len(t, osioAuth.CacheEntries(), 1)
+	assert.Equal(t, "11111111", osioAuth.CacheEntries()[0].User)
+
+	assert.Equal(t, 1, osioAuth.EvictCache(CacheFilter{Cluster: "http://api.cluster1.com/"}))
+
+	serve("/api/v1/namespaces/john-preview-che/pods", "11111111")
+	assert.Equal(t, 1, osioAuth.FlushCache())
+	assert.Empty(t, osioAuth.CacheEntries())
+}
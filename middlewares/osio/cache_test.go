@@ -105,3 +105,25 @@ func TestCacheErrorNotCached(t *testing.T) {
 	assert.Equal(t, wantVal, gotVal)
 	assert.Equal(t, 3, callCnt) // cnt NOT changed as previous result was value
 }
+
+func TestCacheEntriesAndEvict(t *testing.T) {
+	c := Cache{}
+
+	c.Get("k1", singleValResolver("v1")).Get()
+	c.Get("k2", singleValResolver("v2")).Get()
+	c.Get("k3", tempErrResolver(errors.New("test_error"), new(int), 1, "v3")).Get()
+	c.Get("k4", singleValResolver("v4")) // not resolved yet
+
+	assert.Equal(t, map[string]interface{}{"k1": "v1", "k2": "v2"}, c.Entries())
+
+	evicted := c.Evict(func(key string, value interface{}) bool { return value == "v1" })
+	assert.Equal(t, 1, evicted)
+	assert.Equal(t, map[string]interface{}{"k2": "v2"}, c.Entries())
+
+	// evicted entries are resolved again
+	val, _ := c.Get("k1", singleValResolver("v1bis")).Get()
+	assert.Equal(t, "v1bis", val)
+
+	assert.Equal(t, 4, c.Flush())
+	assert.Empty(t, c.Entries())
+}
@@ -0,0 +1,92 @@
+package osio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/containous/traefik/provider/osio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingSecretLocator struct {
+	stubSecretLocator
+}
+
+func (failingSecretLocator) GetName(clusterUrl, clusterToken, nsName, nsType string) (string, error) {
+	return "", errors.New("unauthorized")
+}
+
+func TestGetClusterToken(t *testing.T) {
+	osioAuth := newIdentityTestAuth(CheToken)
+	saCalls := 0
+	osioAuth.RequestSrvAccToken = func() (string, error) {
+		saCalls++
+		return "sa_token", nil
+	}
+	store := osio.NewClusterTokenStore()
+	osioAuth.SetClusterTokens(store)
+
+	// token prefetched by the provider
+	store.Set("http://api.cluster1.com", "prefetched_token")
+	token, err := osioAuth.getClusterToken(osioAuth.defaultIssuer(), "http://api.cluster1.com/")
+	require.NoError(t, err)
+	assert.Equal(t, "prefetched_token", token)
+	assert.Equal(t, 0, saCalls)
+
+	// miss, fetched once then read from the store
+	for i := 0; i < 2; i++ {
+		token, err = osioAuth.getClusterToken(osioAuth.defaultIssuer(), "http://api.cluster2.com")
+		require.NoError(t, err)
+		assert.Equal(t, "cluster_token", token)
+	}
+	assert.Equal(t, 1, saCalls)
+}
+
+func TestClusterTokenInvalidatedOnSecretFailure(t *testing.T) {
+	osioAuth := newIdentityTestAuth(CheToken)
+	osioAuth.RequestSecretLocation = failingSecretLocator{}
+	store := osio.NewClusterTokenStore()
+	store.Set("http://api.cluster1.com", "revoked_token")
+	osioAuth.SetClusterTokens(store)
+
+	_, err := osioAuth.resolveByIDWithoutCache(osioAuth.defaultIssuer(), "11111111", "che_token", CheToken, "john-preview-che")
+	assert.Error(t, err)
+	_, ok := store.Get("http://api.cluster1.com")
+	assert.False(t, ok)
+}
+
+// saTenantTokenLocator returns cluster tokens made of the service account token used to fetch them.
+type saTenantTokenLocator struct {
+	stubTenantTokenLocator
+}
+
+func (saTenantTokenLocator) GetTokenWithSAToken(saToken, location string) (string, error) {
+	return "cluster_token_of_" + saToken, nil
+}
+
+func TestClusterTokensPerIssuer(t *testing.T) {
+	osioAuth := newIdentityTestAuth(CheToken)
+	store := osio.NewClusterTokenStore()
+	store.Set("http://api.cluster1.com", "prefetched_token")
+	osioAuth.SetClusterTokens(store)
+	preview := &issuer{
+		name:               "https://auth.prod-preview.openshift.io",
+		tenantTokenLocator: saTenantTokenLocator{},
+		srvAccTokenLocator: func() (string, error) { return "preview_sa_token", nil },
+		clusterTokens:      osio.NewClusterTokenStore(),
+	}
+
+	// the token prefetched with the default service account is not used for another issuer
+	token, err := osioAuth.getClusterToken(preview, "http://api.cluster1.com")
+	require.NoError(t, err)
+	assert.Equal(t, "cluster_token_of_preview_sa_token", token)
+
+	token, err = osioAuth.getClusterToken(osioAuth.defaultIssuer(), "http://api.cluster1.com")
+	require.NoError(t, err)
+	assert.Equal(t, "prefetched_token", token)
+
+	preview.clusterTokens.Invalidate("http://api.cluster1.com")
+	_, ok := store.Get("http://api.cluster1.com")
+	assert.True(t, ok)
+}
@@ -0,0 +1,176 @@
+package osio
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/provider/osio"
+)
+
+// Debug response headers, describing how the request was routed. They never hold a token.
+const (
+	DebugAuthHeader            = "X-OSIO-Debug-Auth"
+	DebugTokenTypeHeader       = "X-OSIO-Debug-Token-Type"
+	DebugNamespaceHeader       = "X-OSIO-Debug-Namespace"
+	DebugRouteHeader           = "X-OSIO-Debug-Route"
+	DebugTargetHeader          = "X-OSIO-Debug-Target"
+	DebugCacheHeader           = "X-OSIO-Debug-Cache"
+	DebugResolveDurationHeader = "X-OSIO-Debug-Resolve-Duration"
+	DebugFrontendHeader        = "X-OSIO-Debug-Frontend"
+	DebugBackendHeader         = "X-OSIO-Debug-Backend"
+)
+
+// Cache status of the debug responses.
+const (
+	cacheHit      = "hit"
+	cacheMiss     = "miss"
+	cacheWarm     = "warm"
+	cacheDisabled = "disabled"
+)
+
+type debugContextKey struct{}
+
+type debug struct {
+	header string
+	secret []byte
+}
+
+// SetDebug enables the debug response headers for the requests holding the debug secret.
+func (a *OSIOAuth) SetDebug(config *osio.Debug) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	a.debug = &debug{header: config.Header, secret: []byte(config.Secret)}
+	return nil
+}
+
+// startDebug removes the debug header from the request and, when it holds the debug secret,
+// returns the request and the response writer collecting the debug response headers.
+func (a *OSIOAuth) startDebug(rw http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+	if a.debug == nil {
+		return rw, r
+	}
+	secret := r.Header.Get(a.debug.header)
+	if secret == "" {
+		return rw, r
+	}
+	r.Header.Del(a.debug.header)
+	if subtle.ConstantTimeCompare([]byte(secret), a.debug.secret) != 1 {
+		log.Debugf("Invalid debug secret in header %s, path='%s'", a.debug.header, r.URL.Path)
+		return rw, r
+	}
+	info := &debugInfo{header: make(http.Header)}
+	return &debugResponseWriter{ResponseWriter: rw, info: info}, r.WithContext(context.WithValue(r.Context(), debugContextKey{}, info))
+}
+
+// debugInfo holds the debug response headers of a request.
+type debugInfo struct {
+	mux    sync.Mutex
+	header http.Header
+}
+
+// getDebugInfo returns the debug headers of the request, nil when debugging is not enabled for it.
+func getDebugInfo(r *http.Request) *debugInfo {
+	info, _ := r.Context().Value(debugContextKey{}).(*debugInfo)
+	return info
+}
+
+func (d *debugInfo) set(name, value string) {
+	if d == nil || value == "" {
+		return
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.header.Set(name, value)
+}
+
+func (d *debugInfo) setDuration(name string, duration time.Duration) {
+	d.set(name, duration.String())
+}
+
+func (d *debugInfo) copyTo(header http.Header) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	for name, values := range d.header {
+		header[name] = values
+	}
+}
+
+// cacheStatus tells whether the entry was resolved for the request, was already cached or was pre-resolved.
+func cacheStatus(data cacheData, start time.Time) string {
+	switch {
+	case data.Prewarmed:
+		return cacheWarm
+	case data.Created.Before(start):
+		return cacheHit
+	default:
+		return cacheMiss
+	}
+}
+
+// debugRoute records the frontend and backend a request is forwarded to.
+type debugRoute struct {
+	next         http.Handler
+	frontendName string
+	backendName  string
+}
+
+// NewDebugRoute creates a handler recording the frontend and backend names in the debug response headers.
+func NewDebugRoute(next http.Handler, frontendName, backendName string) http.Handler {
+	return &debugRoute{next: next, frontendName: frontendName, backendName: backendName}
+}
+
+func (d *debugRoute) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if info := getDebugInfo(r); info != nil {
+		info.set(DebugFrontendHeader, d.frontendName)
+		info.set(DebugBackendHeader, d.backendName)
+	}
+	d.next.ServeHTTP(rw, r)
+}
+
+// debugResponseWriter adds the debug headers when the response headers are written.
+type debugResponseWriter struct {
+	http.ResponseWriter
+	info        *debugInfo
+	wroteHeader bool
+}
+
+func (w *debugResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.info.copyTo(w.ResponseWriter.Header())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *debugResponseWriter) Write(buf []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(buf)
+}
+
+// Hijack hijacks the connection
+func (w *debugResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (w *debugResponseWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush sends any buffered data to the client.
+func (w *debugResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
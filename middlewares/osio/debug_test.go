@@ -0,0 +1,130 @@
+package osio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/provider/osio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveDebug(t *testing.T, osioAuth *OSIOAuth, req *http.Request) (*httptest.ResponseRecorder, *http.Request) {
+	var forwarded *http.Request
+	backend := NewDebugRoute(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req
+		rw.WriteHeader(http.StatusOK)
+	}), "api0", "api0")
+
+	res := httptest.NewRecorder()
+	osioAuth.ServeHTTP(res, req, backend.ServeHTTP)
+	return res, forwarded
+}
+
+// debugHeaders returns the debug headers of the response by name.
+func debugHeaders(header http.Header) map[string]string {
+	debug := make(map[string]string)
+	for _, name := range []string{DebugAuthHeader, DebugTokenTypeHeader, DebugNamespaceHeader, DebugRouteHeader, DebugTargetHeader,
+		DebugCacheHeader, DebugResolveDurationHeader, DebugFrontendHeader, DebugBackendHeader} {
+		if value := header.Get(name); value != "" {
+			debug[name] = value
+		}
+	}
+	return debug
+}
+
+func TestDebugHeaders(t *testing.T) {
+	osioAuth := newIdentityTestAuth(UserToken)
+	require.NoError(t, osioAuth.SetDebug(&osio.Debug{Secret: "debug_secret"}))
+	token := createSubjectToken(t, "john")
+
+	for _, expectedCache := range []string{cacheMiss, cacheHit} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/john-preview/pods", nil)
+		req.Header.Set(Authorization, "Bearer "+token)
+		req.Header.Set(osio.DefaultDebugHeader, "debug_secret")
+
+		res, forwarded := serveDebug(t, osioAuth, req)
+		require.NotNil(t, forwarded)
+		assert.Empty(t, forwarded.Header.Get(osio.DefaultDebugHeader), "the debug secret is not forwarded")
+
+		header := debugHeaders(res.Header())
+		assert.NotEmpty(t, header[DebugResolveDurationHeader])
+		delete(header, DebugResolveDurationHeader)
+		assert.Equal(t, map[string]string{
+			DebugAuthHeader:      authToken,
+			DebugTokenTypeHeader: "user",
+			DebugNamespaceHeader: "john-preview",
+			DebugRouteHeader:     "api",
+			DebugTargetHeader:    "http://api.cluster1.com",
+			DebugCacheHeader:     expectedCache,
+			DebugFrontendHeader:  "api0",
+			DebugBackendHeader:   "api0",
+		}, header)
+		for _, values := range res.Header() {
+			assert.NotContains(t, values, token)
+			assert.NotContains(t, values, "oso_token")
+		}
+	}
+}
+
+func TestDebugHeadersPreflight(t *testing.T) {
+	osioAuth := newIdentityTestAuth(UserToken)
+	require.NoError(t, osioAuth.SetDebug(&osio.Debug{Header: "X-Debug", Secret: "debug_secret"}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/namespaces/john-preview/pods", nil)
+	req.Header.Set("X-Debug", "debug_secret")
+
+	res, _ := serveDebug(t, osioAuth, req)
+	assert.Equal(t, map[string]string{
+		DebugAuthHeader:     authPreflight,
+		DebugTargetHeader:   defaultTarget,
+		DebugFrontendHeader: "api0",
+		DebugBackendHeader:  "api0",
+	}, debugHeaders(res.Header()))
+}
+
+func TestDebugHeadersDisabled(t *testing.T) {
+	tests := []struct {
+		desc            string
+		debug           *osio.Debug
+		expectedForward string
+	}{
+		{
+			desc:            "debug not configured",
+			expectedForward: "debug_secret",
+		},
+		{
+			desc:  "invalid secret",
+			debug: &osio.Debug{Secret: "other_secret"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			osioAuth := newIdentityTestAuth(UserToken)
+			if test.debug != nil {
+				require.NoError(t, osioAuth.SetDebug(test.debug))
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/john-preview/pods", nil)
+			req.Header.Set(Authorization, "Bearer "+createSubjectToken(t, "john"))
+			req.Header.Set(osio.DefaultDebugHeader, "debug_secret")
+
+			res, forwarded := serveDebug(t, osioAuth, req)
+			require.NotNil(t, forwarded)
+			assert.Equal(t, test.expectedForward, forwarded.Header.Get(osio.DefaultDebugHeader))
+			assert.Empty(t, debugHeaders(res.Header()))
+		})
+	}
+}
+
+func TestCacheStatus(t *testing.T) {
+	data := cacheData{Created: time.Now()}
+	assert.Equal(t, cacheMiss, cacheStatus(data, data.Created.Add(-time.Second)))
+	assert.Equal(t, cacheHit, cacheStatus(data, data.Created.Add(time.Second)))
+	data.Prewarmed = true
+	assert.Equal(t, cacheWarm, cacheStatus(data, data.Created.Add(time.Second)))
+}
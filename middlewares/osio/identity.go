@@ -0,0 +1,117 @@
+package osio
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/vulcand/oxy/utils"
+)
+
+type identityKey string
+
+// IdentityKey is the key within the request context used to store the Identity resolved by OSIOAuth.
+const IdentityKey identityKey = "OSIOIdentity"
+
+// Extractor variables which limit requests by the identity resolved by OSIOAuth.
+const (
+	ExtractorSubject   = "osio.subject"
+	ExtractorUser      = "osio.user"
+	ExtractorNamespace = "osio.namespace"
+	// ExtractorCluster is the Target cluster of the request, set by OSIOAuth.
+	ExtractorCluster = "osio.cluster"
+	// ExtractorCookie is the prefix of the cookie variables, e.g. request.cookie.session.
+	ExtractorCookie = "request.cookie."
+)
+
+// Identity is who a request was resolved for by OSIOAuth.
+type Identity struct {
+	// Subject is the 'sub' claim of the verified token.
+	Subject string
+	// User is the impersonated user for service tokens, the subject otherwise.
+	User string
+	// Namespace is the namespace of the request path, or the user namespace when the path has none.
+	Namespace string
+}
+
+// GetIdentity returns the Identity stored in the request context by OSIOAuth, if any.
+func GetIdentity(req *http.Request) (Identity, bool) {
+	identity, ok := req.Context().Value(IdentityKey).(Identity)
+	return identity, ok
+}
+
+func withIdentity(req *http.Request, identity Identity) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), IdentityKey, identity))
+}
+
+// NewExtractor creates a source extractor for rate and connection limiting, and consistent hashing.
+// On top of the oxy variables (client.ip, request.host, request.header.X) it supports
+// request.cookie.X, osio.subject, osio.user, osio.namespace and osio.cluster. Requests without
+// the cookie, an OSIO identity or a target (e.g. OPTIONS requests) are limited by client IP.
+func NewExtractor(variable string) (utils.SourceExtractor, error) {
+	if !strings.HasPrefix(variable, "osio.") && !strings.HasPrefix(variable, ExtractorCookie) {
+		return utils.NewExtractor(variable)
+	}
+
+	clientIP, err := utils.NewExtractor("client.ip")
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(variable, ExtractorCookie) {
+		name := strings.TrimPrefix(variable, ExtractorCookie)
+		if name == "" {
+			return nil, fmt.Errorf("Wrong cookie: %s", variable)
+		}
+		return utils.ExtractorFunc(func(req *http.Request) (string, int64, error) {
+			if cookie, err := req.Cookie(name); err == nil && cookie.Value != "" {
+				return variable + ":" + cookie.Value, 1, nil
+			}
+			return clientIP.Extract(req)
+		}), nil
+	}
+	if variable == ExtractorCluster {
+		return utils.ExtractorFunc(func(req *http.Request) (string, int64, error) {
+			if target := req.Header.Get("Target"); target != "" {
+				return variable + ":" + target, 1, nil
+			}
+			return clientIP.Extract(req)
+		}), nil
+	}
+
+	var field func(Identity) string
+	switch variable {
+	case ExtractorSubject:
+		field = func(identity Identity) string { return identity.Subject }
+	case ExtractorUser:
+		field = func(identity Identity) string { return identity.User }
+	case ExtractorNamespace:
+		field = func(identity Identity) string { return identity.Namespace }
+	default:
+		return nil, fmt.Errorf("Unsupported limiting variable: '%s'", variable)
+	}
+
+	return utils.ExtractorFunc(func(req *http.Request) (string, int64, error) {
+		if identity, ok := GetIdentity(req); ok {
+			if value := field(identity); value != "" {
+				return variable + ":" + value, 1, nil
+			}
+		}
+		return clientIP.Extract(req)
+	}), nil
+}
+
+// tokenSubject returns the 'sub' claim of a token whose signature was already verified by the TokenTypeLocator.
+func tokenSubject(token string) string {
+	jwtToken, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+	claims, ok := jwtToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	sub, _ := claims["sub"].(string)
+	return sub
+}
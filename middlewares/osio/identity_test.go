@@ -0,0 +1,173 @@
+package osio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubTenantLocator struct {
+	ns namespace
+}
+
+func (s stubTenantLocator) GetTenant(token string, tokenType TokenType) (namespace, error) {
+	return s.ns, nil
+}
+
+func (s stubTenantLocator) GetTenantById(token string, tokenType TokenType, userID string) (namespace, error) {
+	return s.ns, nil
+}
+
+type stubTenantTokenLocator struct{}
+
+func (stubTenantTokenLocator) GetTokenWithUserToken(userToken, location string) (string, error) {
+	return "oso_token", nil
+}
+
+func (stubTenantTokenLocator) GetTokenWithSAToken(saToken, location string) (string, error) {
+	return "cluster_token", nil
+}
+
+type stubSecretLocator struct{}
+
+func (stubSecretLocator) GetName(clusterUrl, clusterToken, nsName, nsType string) (string, error) {
+	return "secret", nil
+}
+
+func (stubSecretLocator) GetSecret(clusterUrl, clusterToken, nsName, secretName string) (string, error) {
+	return "oso_secret", nil
+}
+
+func newIdentityTestAuth(tokenType TokenType) *OSIOAuth {
+	return &OSIOAuth{
+		RequestTenantLocation: stubTenantLocator{ns: namespace{Name: "john-preview", ClusterURL: "http://api.cluster1.com"}},
+		RequestTenantToken:    stubTenantTokenLocator{},
+		RequestSrvAccToken:    func() (string, error) { return "sa_token", nil },
+		RequestSecretLocation: stubSecretLocator{},
+		RequestTokenType:      func(string) (TokenType, error) { return tokenType, nil },
+		cache:                 &Cache{},
+		routes:                defaultRequestRoutes(),
+	}
+}
+
+func createSubjectToken(t *testing.T, sub string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": sub}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	return token
+}
+
+func TestIdentity(t *testing.T) {
+	tests := []struct {
+		desc      string
+		tokenType TokenType
+		path      string
+		userID    string
+		expected  Identity
+	}{
+		{
+			desc:      "user token",
+			tokenType: UserToken,
+			path:      "/api/v1/namespaces/john-preview-stage/pods",
+			expected:  Identity{Subject: "john", User: "john", Namespace: "john-preview-stage"},
+		},
+		{
+			desc:      "user token without namespace in path",
+			tokenType: UserToken,
+			path:      "/api/v1/projects",
+			expected:  Identity{Subject: "john", User: "john", Namespace: "john-preview"},
+		},
+		{
+			desc:      "che token impersonating a user",
+			tokenType: CheToken,
+			path:      "/api/v1/namespaces/john-preview-che/pods",
+			userID:    "11111111",
+			expected:  Identity{Subject: "john", User: "11111111", Namespace: "john-preview-che"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			osioAuth := newIdentityTestAuth(test.tokenType)
+
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			req.Header.Set(Authorization, "Bearer "+createSubjectToken(t, "john"))
+			if test.userID != "" {
+				req.Header.Set(UserIDHeader, test.userID)
+			}
+
+			var identity Identity
+			var found bool
+			osioAuth.ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, req *http.Request) {
+				identity, found = GetIdentity(req)
+			})
+
+			require.True(t, found)
+			assert.Equal(t, test.expected, identity)
+		})
+	}
+}
+
+func TestNewExtractor(t *testing.T) {
+	identity := Identity{Subject: "john", User: "11111111", Namespace: "john-preview-che"}
+
+	tests := []struct {
+		variable    string
+		identity    *Identity
+		target      string
+		expected    string
+		expectedErr bool
+	}{
+		{variable: ExtractorSubject, identity: &identity, expected: "osio.subject:john"},
+		{variable: ExtractorUser, identity: &identity, expected: "osio.user:11111111"},
+		{variable: ExtractorNamespace, identity: &identity, expected: "osio.namespace:john-preview-che"},
+		{variable: ExtractorUser, expected: "10.0.0.1"},
+		{variable: ExtractorSubject, identity: &Identity{User: "11111111"}, expected: "10.0.0.1"},
+		{variable: "client.ip", identity: &identity, expected: "10.0.0.1"},
+		{variable: "request.header.X-Che", expected: "workspace"},
+		{variable: "request.cookie.session", expected: "request.cookie.session:abc"},
+		{variable: "request.cookie.missing", expected: "10.0.0.1"},
+		{variable: "request.cookie.", expectedErr: true},
+		{variable: ExtractorCluster, target: "https://api.starter-us-east-2.openshift.com", expected: "osio.cluster:https://api.starter-us-east-2.openshift.com"},
+		{variable: ExtractorCluster, identity: &identity, expected: "10.0.0.1"},
+		{variable: "osio.tenant", expectedErr: true},
+		{variable: "unknown", expectedErr: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.variable, func(t *testing.T) {
+			extractor, err := NewExtractor(test.variable)
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/john-preview-che/pods", nil)
+			req.RemoteAddr = "10.0.0.1:34567"
+			req.Header.Set("X-Che", "workspace")
+			req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+			if test.identity != nil {
+				req = withIdentity(req, *test.identity)
+			}
+			if test.target != "" {
+				req.Header.Set("Target", test.target)
+			}
+
+			token, amount, err := extractor.Extract(req)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, token)
+			assert.EqualValues(t, 1, amount)
+		})
+	}
+}
+
+func TestTokenSubject(t *testing.T) {
+	assert.Equal(t, "john", tokenSubject(createSubjectToken(t, "john")))
+	assert.Equal(t, "", tokenSubject("1000"))
+}
@@ -0,0 +1,89 @@
+package osio
+
+import (
+	"net/http"
+
+	"github.com/containous/traefik/provider/osio"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// issuer holds the services resolving the tokens of one identity realm, and the cluster tokens
+// fetched with its service account. The default issuer, with an empty name, is made of the
+// OSIOAuth locators and cluster token store.
+type issuer struct {
+	name               string
+	tenantLocator      TenantLocator
+	tenantTokenLocator TenantTokenLocator
+	srvAccTokenLocator SrvAccTokenLocator
+	tokenTypeLocator   TokenTypeLocator
+	clusterTokens      *osio.ClusterTokenStore
+}
+
+func newIssuer(client *http.Client, config *osio.Issuer, keyring *osio.Keyring) *issuer {
+	tokenTypes := TokenTypeMap
+	if len(config.TokenTypes) > 0 {
+		tokenTypes = make(map[string]TokenType)
+		for accountName, tokenType := range config.TokenTypes {
+			tokenTypes[accountName] = TokenType(tokenType)
+		}
+	}
+	authURL := normalizeURL(config.AuthURL)
+	return &issuer{
+		name:               config.Issuer,
+		tenantLocator:      CreateTenantLocator(client, normalizeURL(config.TenantURL)),
+		tenantTokenLocator: &tenantTokenLocator{client: client, authBaseURL: authURL, keyring: keyring},
+		srvAccTokenLocator: CreateSrvAccTokenLocator(authURL, config.ServiceAccountID, config.ServiceAccountSecret),
+		tokenTypeLocator:   createTokenTypeLocator(client, authURL, config.Issuer, tokenTypes),
+		clusterTokens:      osio.NewClusterTokenStore(),
+	}
+}
+
+// SetIssuers adds the issuers selected by the 'iss' claim of the tokens.
+// Tokens of other issuers are resolved by the default auth and tenant services.
+func (a *OSIOAuth) SetIssuers(configs []*osio.Issuer) error {
+	if err := osio.ValidateIssuers(configs); err != nil {
+		return err
+	}
+	issuers := make(map[string]*issuer)
+	for _, config := range configs {
+		issuers[config.Issuer] = newIssuer(http.DefaultClient, config, a.keyring)
+	}
+	a.issuers = issuers
+	return nil
+}
+
+func (a *OSIOAuth) defaultIssuer() *issuer {
+	return &issuer{
+		tenantLocator:      a.RequestTenantLocation,
+		tenantTokenLocator: a.RequestTenantToken,
+		srvAccTokenLocator: a.RequestSrvAccToken,
+		tokenTypeLocator:   a.RequestTokenType,
+		clusterTokens:      a.clusterTokens,
+	}
+}
+
+// selectIssuer returns the issuer configured for the 'iss' claim of the token, or the default one.
+func (a *OSIOAuth) selectIssuer(token string) *issuer {
+	if iss, ok := a.issuers[tokenIssuer(token)]; ok {
+		return iss
+	}
+	return a.defaultIssuer()
+}
+
+// cacheKey namespaces the cache keys by issuer, so that the realms never share entries.
+func (i *issuer) cacheKey(plainKey string) string {
+	if i.name == "" {
+		return cacheKey(plainKey)
+	}
+	return cacheKey(i.name + "_" + plainKey)
+}
+
+// tokenIssuer returns the 'iss' claim of a JWT token, without verifying it.
+func tokenIssuer(token string) string {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err != nil {
+		return ""
+	}
+	iss, _ := claims["iss"].(string)
+	return iss
+}
@@ -0,0 +1,160 @@
+package osio
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/provider/osio"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v1"
+)
+
+// newKeysServer serves the public key as the key set of an auth service.
+func newKeysServer(t *testing.T, kid string, key *rsa.PrivateKey) *httptest.Server {
+	jwk, err := json.Marshal(&jose.JsonWebKey{Key: &key.PublicKey, KeyID: kid, Algorithm: "RS256", Use: "sig"})
+	require.NoError(t, err)
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/token/keys" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Write([]byte(`{"keys":[` + string(jwk) + `]}`))
+	}))
+}
+
+func createIssuerToken(t *testing.T, kid string, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestIssuerTokenType(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	auth := newKeysServer(t, "preview-key", key)
+	defer auth.Close()
+
+	iss := newIssuer(http.DefaultClient, &osio.Issuer{
+		Issuer:     "https://auth.prod-preview.openshift.io",
+		AuthURL:    auth.URL + "/",
+		TenantURL:  "https://tenant.prod-preview.openshift.io",
+		TokenTypes: map[string]string{"preview-che": "che"},
+	}, nil)
+
+	tests := []struct {
+		desc        string
+		claims      jwt.MapClaims
+		expected    TokenType
+		expectedErr bool
+	}{
+		{
+			desc:     "user token",
+			claims:   jwt.MapClaims{"iss": "https://auth.prod-preview.openshift.io", "sub": "john"},
+			expected: UserToken,
+		},
+		{
+			desc:     "mapped service token",
+			claims:   jwt.MapClaims{"iss": "https://auth.prod-preview.openshift.io", "service_accountname": "preview-che"},
+			expected: CheToken,
+		},
+		{
+			desc:        "service token not in the issuer mapping",
+			claims:      jwt.MapClaims{"iss": "https://auth.prod-preview.openshift.io", "service_accountname": "rh-che"},
+			expectedErr: true,
+		},
+		{
+			desc:        "other issuer",
+			claims:      jwt.MapClaims{"iss": "https://auth.openshift.io", "sub": "john"},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			tokenType, err := iss.tokenTypeLocator(createIssuerToken(t, "preview-key", key, test.claims))
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, tokenType)
+		})
+	}
+}
+
+func TestSelectIssuer(t *testing.T) {
+	osioAuth := newIdentityTestAuth(UserToken)
+	require.NoError(t, osioAuth.SetIssuers([]*osio.Issuer{{
+		Issuer:               "https://auth.prod-preview.openshift.io",
+		AuthURL:              "https://auth.prod-preview.openshift.io",
+		TenantURL:            "https://tenant.prod-preview.openshift.io",
+		ServiceAccountID:     "oso-proxy",
+		ServiceAccountSecret: "secret",
+	}}))
+
+	previewToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": "https://auth.prod-preview.openshift.io"}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://auth.prod-preview.openshift.io", osioAuth.selectIssuer(previewToken).name)
+	assert.Equal(t, "", osioAuth.selectIssuer(createSubjectToken(t, "john")).name)
+	assert.Equal(t, "", osioAuth.selectIssuer("not-a-jwt").name)
+
+	assert.NotEqual(t, osioAuth.selectIssuer(previewToken).cacheKey("token"), osioAuth.defaultIssuer().cacheKey("token"))
+	assert.Equal(t, cacheKey("token"), osioAuth.defaultIssuer().cacheKey("token"))
+}
+
+func TestIssuerResolution(t *testing.T) {
+	osioAuth := newIdentityTestAuth(UserToken)
+	osioAuth.issuers = map[string]*issuer{
+		"https://auth.prod-preview.openshift.io": {
+			name:               "https://auth.prod-preview.openshift.io",
+			tenantLocator:      stubTenantLocator{ns: namespace{Name: "john-preview", ClusterURL: "http://api.preview.com"}},
+			tenantTokenLocator: stubTenantTokenLocator{},
+			srvAccTokenLocator: func() (string, error) { return "preview_sa_token", nil },
+			tokenTypeLocator:   func(string) (TokenType, error) { return UserToken, nil },
+		},
+	}
+
+	for _, iss := range []string{"https://auth.openshift.io", "https://auth.prod-preview.openshift.io"} {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": iss, "sub": "john"}).SignedString([]byte("secret"))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/john-preview/pods", nil)
+		req.Header.Set(Authorization, "Bearer "+token)
+		osioAuth.ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, req *http.Request) {})
+	}
+
+	entries := osioAuth.CacheEntries()
+	require.Len(t, entries, 2)
+	clusters := map[string]string{}
+	for _, entry := range entries {
+		clusters[entry.Issuer] = entry.Cluster
+	}
+	assert.Equal(t, map[string]string{
+		"":                                       "http://api.cluster1.com",
+		"https://auth.prod-preview.openshift.io": "http://api.preview.com",
+	}, clusters)
+}
+
+func TestSetIssuersInvalid(t *testing.T) {
+	osioAuth := newIdentityTestAuth(UserToken)
+	preview := &osio.Issuer{
+		Issuer:               "https://auth.prod-preview.openshift.io",
+		AuthURL:              "https://auth.prod-preview.openshift.io",
+		TenantURL:            "https://tenant.prod-preview.openshift.io",
+		ServiceAccountID:     "oso-proxy",
+		ServiceAccountSecret: "secret",
+	}
+	assert.Error(t, osioAuth.SetIssuers([]*osio.Issuer{preview, preview}))
+	assert.Error(t, osioAuth.SetIssuers([]*osio.Issuer{{Issuer: "https://auth.openshift.io", AuthURL: "auth"}}))
+	assert.Nil(t, osioAuth.issuers)
+}
@@ -24,6 +24,11 @@ type JSONKeys struct {
 }
 
 func CreateTokenTypeLocator(client *http.Client, authURL string) TokenTypeLocator {
+	return createTokenTypeLocator(client, authURL, "", TokenTypeMap)
+}
+
+// createTokenTypeLocator verifies the tokens with the keys of the auth service and, if set, their issuer.
+func createTokenTypeLocator(client *http.Client, authURL, issuer string, tokenTypes map[string]TokenType) TokenTypeLocator {
 	var publicKeysMap map[string]*rsa.PublicKey
 
 	keyFunc := func(token *jwt.Token) (interface{}, error) {
@@ -55,11 +60,14 @@ func CreateTokenTypeLocator(client *http.Client, authURL string) TokenTypeLocato
 		if err != nil {
 			return "", err
 		}
+		if issuer != "" && !jwtToken.Claims.(jwt.MapClaims).VerifyIssuer(issuer, true) {
+			return "", fmt.Errorf("token not issued by '%s'", issuer)
+		}
 		accountName := jwtToken.Claims.(jwt.MapClaims)["service_accountname"]
 		if accountName != nil {
 			accNameStr, isString := accountName.(string)
 			if isString {
-				tokenType := TokenTypeMap[accNameStr]
+				tokenType := tokenTypes[accNameStr]
 				if tokenType == "" {
 					return "", fmt.Errorf("service_accountname '%s' not supported", accNameStr)
 				}
@@ -7,8 +7,11 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/provider/osio"
+	gokitmetrics "github.com/go-kit/kit/metrics"
 )
 
 const (
@@ -17,16 +20,6 @@ const (
 	UserIDHeader           = "Impersonate-User"
 )
 
-type RequestType string
-
-const (
-	api      RequestType = "api"
-	metrics  RequestType = "metrics"
-	console  RequestType = "console"
-	logs     RequestType = "logs"
-	undefine RequestType = ""
-)
-
 const (
 	// service maps to token type, if not a service token then it maps to UserToken
 	CheToken  TokenType = "che"
@@ -37,11 +30,6 @@ var TokenTypeMap = map[string]TokenType{
 	"rh-che": CheToken,
 }
 
-var (
-	apiPrefix  = api.path() + api.path()
-	oapiPrefix = api.path() + "/oapi"
-)
-
 type TokenType string
 
 type TenantLocator interface {
@@ -66,6 +54,14 @@ type TokenTypeLocator func(string) (TokenType, error)
 type cacheData struct {
 	Token     string
 	Namespace namespace
+	// Issuer, UserID, NamespaceName, Resolution and Created describe the entry in the cache admin API
+	Issuer        string
+	UserID        string
+	NamespaceName string
+	Resolution    string
+	Created       time.Time
+	// Prewarmed entries were resolved on startup from the identities of the warm-up snapshot
+	Prewarmed bool
 }
 
 type OSIOAuth struct {
@@ -75,12 +71,22 @@ type OSIOAuth struct {
 	RequestSecretLocation SecretLocator
 	RequestTokenType      TokenTypeLocator
 	cache                 *Cache
+	routes                *RequestRoutes
+	clusterTokens         *osio.ClusterTokenStore
+	passthrough           *passthrough
+	issuers               map[string]*issuer
+	keyring               *osio.Keyring
+	anonymousRoutes       []*anonymousRoute
+	warmup                *warmup
+	debug                 *debug
+
+	// RequestsCounter counts the requests by authentication kind (token, passthrough, anonymous or preflight).
+	RequestsCounter gokitmetrics.Counter
 }
 
 func NewPreConfiguredOSIOAuth() *OSIOAuth {
-	authTokenKey := os.Getenv("AUTH_TOKEN_KEY")
-	if authTokenKey == "" {
-		panic("Missing AUTH_TOKEN_KEY")
+	if os.Getenv("AUTH_TOKEN_KEY") == "" && os.Getenv("AUTH_TOKEN_KEYRING") == "" {
+		panic("Missing AUTH_TOKEN_KEY or AUTH_TOKEN_KEYRING")
 	}
 	tenantURL := os.Getenv("TENANT_URL")
 	if tenantURL == "" {
@@ -110,33 +116,133 @@ func NewOSIOAuth(tenantURL, authURL, srvAccID, srvAccSecret string) *OSIOAuth {
 		RequestSecretLocation: CreateSecretLocator(http.DefaultClient),
 		RequestTokenType:      CreateTokenTypeLocator(http.DefaultClient, authURL),
 		cache:                 &Cache{},
+		routes:                defaultRequestRoutes(),
+		clusterTokens:         osio.NewClusterTokenStore(),
 	}
 }
 
-func (a *OSIOAuth) cacheResolverByID(token string, tokenType TokenType, userID string, namespaceName string) Resolver {
+// NewFromProvider creates the middleware configured by the [osio] section of the provider, which
+// may be nil, decrypting the cluster tokens with the keyring. The cache warm-up is left to the caller,
+// as it runs in the background.
+func NewFromProvider(provider *osio.Provider, keyring *osio.Keyring) (*OSIOAuth, error) {
+	a := NewPreConfiguredOSIOAuth()
+	if provider != nil {
+		if len(provider.Routes) > 0 {
+			if err := a.SetRoutes(provider.Routes); err != nil {
+				return nil, fmt.Errorf("error configuring OSIO routes: %v", err)
+			}
+		}
+		if provider.Passthrough != nil {
+			if err := a.SetPassthrough(provider.Passthrough); err != nil {
+				return nil, fmt.Errorf("error configuring OSIO passthrough: %v", err)
+			}
+		}
+		if len(provider.Issuers) > 0 {
+			if err := a.SetIssuers(provider.Issuers); err != nil {
+				return nil, fmt.Errorf("error configuring OSIO issuers: %v", err)
+			}
+		}
+		if len(provider.Anonymous) > 0 {
+			if err := a.SetAnonymousRoutes(provider.Anonymous); err != nil {
+				return nil, fmt.Errorf("error configuring OSIO anonymous routes: %v", err)
+			}
+		}
+		if provider.Debug != nil {
+			if err := a.SetDebug(provider.Debug); err != nil {
+				return nil, fmt.Errorf("error configuring OSIO debug headers: %v", err)
+			}
+		}
+	}
+	a.SetKeyring(keyring)
+	if provider != nil {
+		clusterTokens := osio.NewClusterTokenStore()
+		provider.ClusterTokens(clusterTokens)
+		provider.Keyring(keyring)
+		a.SetClusterTokens(clusterTokens)
+	}
+	return a, nil
+}
+
+// SetRoutes replaces the routes used to select the target of a request.
+func (a *OSIOAuth) SetRoutes(routes []*osio.Route) error {
+	rr, err := NewRequestRoutes(routes)
+	if err != nil {
+		return err
+	}
+	a.routes = rr
+	return nil
+}
+
+// SetClusterTokens replaces the store of cluster tokens, e.g. by the one filled by the osio provider.
+func (a *OSIOAuth) SetClusterTokens(store *osio.ClusterTokenStore) {
+	a.clusterTokens = store
+}
+
+// SetKeyring sets the keys used to decrypt the cluster tokens, AUTH_TOKEN_KEY by default.
+func (a *OSIOAuth) SetKeyring(keyring *osio.Keyring) {
+	a.keyring = keyring
+	if locator, ok := a.RequestTenantToken.(*tenantTokenLocator); ok {
+		locator.keyring = keyring
+	}
+	for _, iss := range a.issuers {
+		if locator, ok := iss.tenantTokenLocator.(*tenantTokenLocator); ok {
+			locator.keyring = keyring
+		}
+	}
+}
+
+// getClusterToken returns the cluster token from the store of the issuer, or fetches it with the
+// service account token of the issuer on a miss.
+func (a *OSIOAuth) getClusterToken(iss *issuer, clusterURL string) (string, error) {
+	if iss.clusterTokens != nil {
+		if clusterToken, ok := iss.clusterTokens.Get(clusterURL); ok {
+			return clusterToken, nil
+		}
+	}
+	osoProxySAToken, err := iss.srvAccTokenLocator()
+	if err != nil {
+		log.Errorf("Failed to locate service account token, %v", err)
+		return "", err
+	}
+	clusterToken, err := iss.tenantTokenLocator.GetTokenWithSAToken(osoProxySAToken, clusterURL)
+	if err != nil {
+		log.Errorf("Failed to locate cluster token, %v", err)
+		return "", err
+	}
+	if iss.clusterTokens != nil {
+		iss.clusterTokens.Set(clusterURL, clusterToken)
+	}
+	return clusterToken, nil
+}
+
+func (a *OSIOAuth) cacheResolverByID(iss *issuer, token string, tokenType TokenType, userID string, namespaceName string) Resolver {
 	return func() (interface{}, error) {
-		namespace, err := a.RequestTenantLocation.GetTenantById(token, tokenType, userID)
+		namespace, err := iss.tenantLocator.GetTenantById(token, tokenType, userID)
 		if err != nil {
 			log.Errorf("Failed to locate tenant, %v", err)
 			return cacheData{}, err
 		}
+		if a.warmup != nil && namespaceName != "" {
+			// the pre-resolved entry is only used once the tenant service authorized the token of the request
+			if data, ok := a.warmup.take(recentKey{iss.name, userID, namespaceName, tokenType}); ok && data.Namespace == namespace {
+				return data, nil
+			}
+		}
 		if namespaceName == "" {
 			namespaceName = namespace.Name
 		}
 
-		osoProxySAToken, err := a.RequestSrvAccToken()
-		if err != nil {
-			log.Errorf("Failed to locate service account token, %v", err)
-			return cacheData{}, err
-		}
-		clusterToken, err := a.RequestTenantToken.GetTokenWithSAToken(osoProxySAToken, namespace.ClusterURL)
+		clusterToken, err := a.getClusterToken(iss, namespace.ClusterURL)
 		if err != nil {
-			log.Errorf("Failed to locate cluster token, %v", err)
 			return cacheData{}, err
 		}
 		secretName, err := a.RequestSecretLocation.GetName(namespace.ClusterURL, clusterToken, namespaceName, namespace.Type)
 		if err != nil {
 			log.Errorf("Failed to locate secret name, %v", err)
+			if iss.clusterTokens != nil {
+				// the cluster token may have been revoked, fetch it again on the next call
+				iss.clusterTokens.Invalidate(namespace.ClusterURL)
+			}
 			return cacheData{}, err
 		}
 		osoToken, err := a.RequestSecretLocation.GetSecret(namespace.ClusterURL, clusterToken, namespaceName, secretName)
@@ -144,29 +250,45 @@ func (a *OSIOAuth) cacheResolverByID(token string, tokenType TokenType, userID s
 			log.Errorf("Failed to get secret, %v", err)
 			return cacheData{}, err
 		}
-		return cacheData{Namespace: namespace, Token: osoToken}, nil
+		return cacheData{
+			Namespace:     namespace,
+			Token:         osoToken,
+			Issuer:        iss.name,
+			UserID:        userID,
+			NamespaceName: namespaceName,
+			Resolution:    ResolutionByID,
+			Created:       time.Now(),
+		}, nil
 	}
 }
 
-func (a *OSIOAuth) cacheResolverByToken(token string, tokenType TokenType) Resolver {
+func (a *OSIOAuth) cacheResolverByToken(iss *issuer, token string, tokenType TokenType) Resolver {
 	return func() (interface{}, error) {
-		namespace, err := a.RequestTenantLocation.GetTenant(token, tokenType)
+		namespace, err := iss.tenantLocator.GetTenant(token, tokenType)
 		if err != nil {
 			log.Errorf("Failed to locate tenant, %v", err)
 			return cacheData{}, err
 		}
-		osoToken, err := a.RequestTenantToken.GetTokenWithUserToken(token, namespace.ClusterURL)
+		osoToken, err := iss.tenantTokenLocator.GetTokenWithUserToken(token, namespace.ClusterURL)
 		if err != nil {
 			log.Errorf("Failed to locate token, %v", err)
 			return cacheData{}, err
 		}
-		return cacheData{Namespace: namespace, Token: osoToken}, nil
+		return cacheData{
+			Namespace:     namespace,
+			Token:         osoToken,
+			Issuer:        iss.name,
+			UserID:        tokenSubject(token),
+			NamespaceName: namespace.Name,
+			Resolution:    ResolutionByToken,
+			Created:       time.Now(),
+		}, nil
 	}
 }
 
-func (a *OSIOAuth) resolveByToken(token string, tokenType TokenType) (cacheData, error) {
-	key := cacheKey(token)
-	val, err := a.cache.Get(key, a.cacheResolverByToken(token, tokenType)).Get()
+func (a *OSIOAuth) resolveByToken(iss *issuer, token string, tokenType TokenType) (cacheData, error) {
+	key := iss.cacheKey(token)
+	val, err := a.cache.Get(key, a.cacheResolverByToken(iss, token, tokenType)).Get()
 
 	if data, ok := val.(cacheData); ok {
 		return data, err
@@ -174,10 +296,10 @@ func (a *OSIOAuth) resolveByToken(token string, tokenType TokenType) (cacheData,
 	return cacheData{}, err
 }
 
-func (a *OSIOAuth) resolveByID(userID, token string, tokenType TokenType, namespaceName string) (cacheData, error) {
+func (a *OSIOAuth) resolveByID(iss *issuer, userID, token string, tokenType TokenType, namespaceName string) (cacheData, error) {
 	plainKey := fmt.Sprintf("%s_%s_%s", token, userID, namespaceName)
-	key := cacheKey(plainKey)
-	val, err := a.cache.Get(key, a.cacheResolverByID(token, tokenType, userID, namespaceName)).Get()
+	key := iss.cacheKey(plainKey)
+	val, err := a.cache.Get(key, a.cacheResolverByID(iss, token, tokenType, userID, namespaceName)).Get()
 
 	if data, ok := val.(cacheData); ok {
 		return data, err
@@ -185,8 +307,8 @@ func (a *OSIOAuth) resolveByID(userID, token string, tokenType TokenType, namesp
 	return cacheData{}, err
 }
 
-func (a *OSIOAuth) resolveByIDWithoutCache(userID, token string, tokenType TokenType, namespaceName string) (cacheData, error) {
-	resolver := a.cacheResolverByID(token, tokenType, userID, namespaceName)
+func (a *OSIOAuth) resolveByIDWithoutCache(iss *issuer, userID, token string, tokenType TokenType, namespaceName string) (cacheData, error) {
+	resolver := a.cacheResolverByID(iss, token, tokenType, userID, namespaceName)
 	val, err := resolver()
 
 	if data, ok := val.(cacheData); ok {
@@ -198,6 +320,15 @@ func (a *OSIOAuth) resolveByIDWithoutCache(userID, token string, tokenType Token
 func (a *OSIOAuth) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 
 	if a.RequestTenantLocation != nil {
+		rw, r = a.startDebug(rw, r)
+		dbg := getDebugInfo(r)
+
+		if route := a.getAnonymousRoute(r); route != nil {
+			a.countRequest(authAnonymous)
+			dbg.set(DebugAuthHeader, authAnonymous)
+			a.serveAnonymous(rw, r, route, next)
+			return
+		}
 
 		if r.Method != "OPTIONS" {
 			// get token and token type
@@ -207,12 +338,25 @@ func (a *OSIOAuth) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.
 				rw.WriteHeader(http.StatusUnauthorized)
 				return
 			}
-			tokenType, err := a.RequestTokenType(token)
+			if a.passthrough != nil && isNativeToken(token) {
+				a.countRequest(authPassthrough)
+				dbg.set(DebugAuthHeader, authPassthrough)
+				a.servePassthrough(rw, r, token, next)
+				return
+			}
+			a.countRequest(authToken)
+			dbg.set(DebugAuthHeader, authToken)
+			start := time.Now()
+			iss := a.selectIssuer(token)
+			tokenType, err := iss.tokenTypeLocator(token)
 			if err != nil {
 				log.Errorf("Invalid token, %v", err)
 				rw.WriteHeader(http.StatusUnauthorized)
 				return
 			}
+			dbg.set(DebugTokenTypeHeader, string(tokenType))
+
+			identity := Identity{Subject: tokenSubject(token), Namespace: getNamespaceName(r.URL.Path)}
 
 			// retrieve cache data
 			var cached cacheData
@@ -223,116 +367,64 @@ func (a *OSIOAuth) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.
 					rw.WriteHeader(http.StatusUnauthorized)
 					return
 				}
-				namespaceName := getNamespaceName(r.URL.Path)
+				identity.User = userID
+				namespaceName := identity.Namespace
 				if namespaceName == "" {
 					log.Infof("Cache disabled for this call as 'namespace name' is missing in request path, host='%s', path='%s', userID='%s'", r.Host, r.URL.Path, userID)
-					cached, err = a.resolveByIDWithoutCache(userID, token, tokenType, namespaceName)
+					cached, err = a.resolveByIDWithoutCache(iss, userID, token, tokenType, namespaceName)
+					dbg.set(DebugCacheHeader, cacheDisabled)
 				} else {
-					cached, err = a.resolveByID(userID, token, tokenType, namespaceName)
+					cached, err = a.resolveByID(iss, userID, token, tokenType, namespaceName)
+					dbg.set(DebugCacheHeader, cacheStatus(cached, start))
 				}
 			} else {
-				cached, err = a.resolveByToken(token, tokenType)
+				identity.User = identity.Subject
+				cached, err = a.resolveByToken(iss, token, tokenType)
+				dbg.set(DebugCacheHeader, cacheStatus(cached, start))
 			}
+			dbg.setDuration(DebugResolveDurationHeader, time.Since(start))
 			if err != nil {
 				log.Errorf("Cache resolve failed, %v", err)
 				rw.WriteHeader(http.StatusUnauthorized)
 				return
 			}
+			if identity.Namespace == "" {
+				identity.Namespace = cached.Namespace.Name
+			}
+			dbg.set(DebugNamespaceHeader, identity.Namespace)
+			if a.warmup != nil && cached.NamespaceName != "" {
+				a.warmup.record(recentKey{iss.name, identity.User, cached.NamespaceName, tokenType})
+			}
+			r = withIdentity(r, identity)
 
 			// routing or redirect
-			reqType := getRequestType(r)
-			reqType.stripPathPrefix(r)
-			targetURL := normalizeURL(reqType.getTargetURL(cached.Namespace))
-			if reqType.isRedirectRequest() {
-				redirectURL := reqType.getRedirectURL(targetURL, r)
+			reqRoute := a.routes.getRequestRoute(r)
+			reqRoute.stripPathPrefix(r)
+			targetURL := normalizeURL(reqRoute.getTargetURL(cached.Namespace))
+			dbg.set(DebugRouteHeader, string(reqRoute.reqType))
+			dbg.set(DebugTargetHeader, targetURL)
+			if reqRoute.isRedirectRequest() {
+				redirectURL := reqRoute.getRedirectURL(targetURL, r)
 				http.Redirect(rw, r, redirectURL, http.StatusTemporaryRedirect)
 				return
 			} else {
 				r.Header.Set("Target", targetURL)
 				r.Header.Set("Authorization", "Bearer "+cached.Token)
+				replaceProtocolToken(r, cached.Token)
 				if tokenType != UserToken {
 					removeUserID(r)
 				}
 			}
 		} else {
-			r.Header.Set("Target", "default")
+			a.countRequest(authPreflight)
+			dbg.set(DebugAuthHeader, authPreflight)
+			dbg.set(DebugTargetHeader, defaultTarget)
+			r.Header.Set("Target", defaultTarget)
 		}
 	}
 	next(rw, r)
 }
 
-func getRequestType(req *http.Request) RequestType {
-	reqPath := req.URL.Path
-	switch {
-	// extra '/' (slash at end) to make sure other prefix like '/apis' should not match with this case
-	case strings.HasPrefix(reqPath, api.path()+"/"):
-		return api
-	case strings.HasPrefix(reqPath, metrics.path()):
-		return metrics
-	case strings.HasPrefix(reqPath, console.path()):
-		return console
-	case strings.HasPrefix(reqPath, logs.path()):
-		return logs
-	default:
-		return undefine
-	}
-}
-
-func (r RequestType) path() string {
-	return "/" + string(r)
-}
-
-func (r RequestType) getTargetURL(ns namespace) string {
-	switch r {
-	case api:
-		return ns.ClusterURL
-	case metrics:
-		return ns.ClusterMetricsURL
-	case console:
-		return ns.ClusterConsoleURL
-	case logs:
-		return ns.ClusterLoggingURL
-	case undefine:
-		return ns.ClusterURL
-	default:
-		return ns.ClusterURL
-	}
-}
-
-func (r RequestType) stripPathPrefix(req *http.Request) {
-	var pathPrefix, stripPath string
-	switch r {
-	case api:
-		if strings.HasPrefix(req.URL.Path, oapiPrefix) {
-			pathPrefix = oapiPrefix
-		} else {
-			pathPrefix = apiPrefix
-		}
-		stripPath = r.path()
-	case metrics, console, logs:
-		pathPrefix = r.path()
-		stripPath = r.path()
-	case undefine:
-		return
-	}
-	stripRequestPathPrefix(req, pathPrefix, stripPath)
-}
-
-func (r RequestType) isRedirectRequest() bool {
-	if r == console || r == logs {
-		return true
-	}
-	return false
-}
-
-func (r RequestType) getRedirectURL(targetURL string, req *http.Request) string {
-	redirectURL := targetURL + req.URL.Path
-	if r == logs && req.URL.RawQuery != "" {
-		redirectURL = strings.Join([]string{redirectURL, "?", req.URL.RawQuery}, "")
-	}
-	return redirectURL
-}
-
 func getToken(r *http.Request) (string, error) {
 	t, err := extractToken(r.Header.Get(Authorization))
 	if err != nil {
@@ -1,8 +1,16 @@
 package osio
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
+	"strings"
+)
+
+const (
+	WebSocketProtocolHeader = "Sec-WebSocket-Protocol"
+	// bearerProtocolPrefix is the Kubernetes WebSocket subprotocol carrying a base64url encoded bearer token
+	bearerProtocolPrefix = "base64url.bearer.authorization.k8s.io."
 )
 
 type OSIORequest struct {
@@ -12,9 +20,11 @@ func NewOSIORequest() *OSIORequest {
 	return &OSIORequest{}
 }
 
-// ServeHTTP handle OSIORequest middleware. It moves access_token from query param to request header.
+// ServeHTTP handle OSIORequest middleware. It moves access_token from query param or
+// the bearer token from the WebSocket subprotocol to request header.
 func (a *OSIORequest) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	if r.Method != "OPTIONS" {
+		processWebSocketProtocol(r)
 		processAuthParam(r)
 	}
 	next(rw, r)
@@ -28,6 +38,16 @@ func processAuthParam(r *http.Request) {
 	}
 }
 
+func processWebSocketProtocol(r *http.Request) {
+	if r.Header.Get(Authorization) != "" {
+		return
+	}
+	accessToken := extractProtocolToken(r)
+	if accessToken != "" {
+		addAuthHeader(r, accessToken)
+	}
+}
+
 func addAuthHeader(r *http.Request, accessToken string) {
 	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 }
@@ -38,3 +58,47 @@ func removeAuthParam(r *http.Request) {
 	r.URL.RawQuery = q.Encode()
 	r.RequestURI = r.URL.RequestURI()
 }
+
+// extractProtocolToken returns the bearer token of the WebSocket subprotocols, if any.
+func extractProtocolToken(r *http.Request) string {
+	for _, protocol := range getWebSocketProtocols(r) {
+		if strings.HasPrefix(protocol, bearerProtocolPrefix) {
+			encoded := strings.TrimRight(strings.TrimPrefix(protocol, bearerProtocolPrefix), "=")
+			token, err := base64.RawURLEncoding.DecodeString(encoded)
+			if err != nil {
+				return ""
+			}
+			return string(token)
+		}
+	}
+	return ""
+}
+
+// replaceProtocolToken swaps the bearer token of the WebSocket subprotocols with the given token.
+// Requests without a bearer subprotocol are left untouched.
+func replaceProtocolToken(r *http.Request, token string) {
+	protocols := getWebSocketProtocols(r)
+	replaced := false
+	for ind, protocol := range protocols {
+		if strings.HasPrefix(protocol, bearerProtocolPrefix) {
+			protocols[ind] = bearerProtocolPrefix + base64.RawURLEncoding.EncodeToString([]byte(token))
+			replaced = true
+		}
+	}
+	if replaced {
+		r.Header.Set(WebSocketProtocolHeader, strings.Join(protocols, ", "))
+	}
+}
+
+func getWebSocketProtocols(r *http.Request) []string {
+	var protocols []string
+	for _, value := range r.Header[http.CanonicalHeaderKey(WebSocketProtocolHeader)] {
+		for _, protocol := range strings.Split(value, ",") {
+			protocol = strings.TrimSpace(protocol)
+			if protocol != "" {
+				protocols = append(protocols, protocol)
+			}
+		}
+	}
+	return protocols
+}
@@ -33,3 +33,73 @@ func TestProcessAuthParam(t *testing.T) {
 		assert.Equal(t, "/test?watch=true", req.RequestURI)
 	})
 }
+
+func TestProcessWebSocketProtocol(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set(WebSocketProtocolHeader, "v4.channel.k8s.io, base64url.bearer.authorization.k8s.io.YWJjZDEyMzQ")
+
+		processWebSocketProtocol(req)
+
+		assert.Equal(t, "Bearer abcd1234", req.Header.Get("Authorization"))
+		assert.Equal(t, "v4.channel.k8s.io, base64url.bearer.authorization.k8s.io.YWJjZDEyMzQ", req.Header.Get(WebSocketProtocolHeader))
+	})
+
+	t.Run("padded", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Add(WebSocketProtocolHeader, "base64.channel.k8s.io")
+		req.Header.Add(WebSocketProtocolHeader, "base64url.bearer.authorization.k8s.io.YWJjZDEyMzQ=")
+
+		processWebSocketProtocol(req)
+
+		assert.Equal(t, "Bearer abcd1234", req.Header.Get("Authorization"))
+	})
+
+	t.Run("auth_header_set", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer 1111")
+		req.Header.Set(WebSocketProtocolHeader, "base64url.bearer.authorization.k8s.io.YWJjZDEyMzQ")
+
+		processWebSocketProtocol(req)
+
+		assert.Equal(t, "Bearer 1111", req.Header.Get("Authorization"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set(WebSocketProtocolHeader, "base64url.bearer.authorization.k8s.io.!!!")
+
+		processWebSocketProtocol(req)
+
+		assert.Empty(t, req.Header.Get("Authorization"))
+	})
+
+	t.Run("not_set", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set(WebSocketProtocolHeader, "v4.channel.k8s.io")
+
+		processWebSocketProtocol(req)
+
+		assert.Empty(t, req.Header.Get("Authorization"))
+	})
+}
+
+func TestReplaceProtocolToken(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set(WebSocketProtocolHeader, "v4.channel.k8s.io, base64url.bearer.authorization.k8s.io.YWJjZDEyMzQ")
+
+		replaceProtocolToken(req, "1001")
+
+		assert.Equal(t, "v4.channel.k8s.io, base64url.bearer.authorization.k8s.io.MTAwMQ", req.Header.Get(WebSocketProtocolHeader))
+	})
+
+	t.Run("not_set", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set(WebSocketProtocolHeader, "v4.channel.k8s.io")
+
+		replaceProtocolToken(req, "1001")
+
+		assert.Equal(t, "v4.channel.k8s.io", req.Header.Get(WebSocketProtocolHeader))
+	})
+}
@@ -3,9 +3,12 @@ package osio
 import (
 	"net/http"
 	"net/url"
+	"os"
 	"testing"
 
+	"github.com/containous/traefik/provider/osio"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestExtracToken(t *testing.T) {
@@ -81,10 +84,11 @@ func TestStripPathPrefix(t *testing.T) {
 		{"/apis/apps/v1/namespaces/k8s-image-puller/daemonsets", "/apis/apps/v1/namespaces/k8s-image-puller/daemonsets"},
 	}
 
+	routes := defaultRequestRoutes()
 	for _, table := range tables {
 		req := createRequestWithPath(table.reqPath)
-		reqType := getRequestType(req)
-		reqType.stripPathPrefix(req)
+		reqRoute := routes.getRequestRoute(req)
+		reqRoute.stripPathPrefix(req)
 		assertRequestPath(t, req, table.expectedPath)
 	}
 }
@@ -140,3 +144,84 @@ func assertRequestPath(t *testing.T, req *http.Request, expectedPath string) {
 	assert.Equal(t, expectedPath, req.URL.Path)
 	assert.Equal(t, expectedPath, req.RequestURI)
 }
+
+func TestRequestRoutes(t *testing.T) {
+	routes, err := NewRequestRoutes([]*osio.Route{
+		{Name: "api", PathPrefix: "/api/", TargetField: "cluster-url", ClusterField: "api-url"},
+		{Name: "registry", PathPrefix: "/registry", TargetField: "cluster-app-domain", ClusterField: "app-dns", StripPrefix: "/registry"},
+		{Name: "console", PathPrefix: "/console", TargetField: "cluster-console-url", StripPrefix: "/console", Redirect: true},
+	})
+	require.NoError(t, err)
+
+	ns := namespace{
+		ClusterURL:        "https://api.cluster1.com/",
+		ClusterConsoleURL: "https://console.cluster1.com/console",
+		ClusterAppDomain:  "apps.cluster1.com",
+	}
+	tables := []struct {
+		reqPath      string
+		wantType     RequestType
+		wantPath     string
+		wantTarget   string
+		wantRedirect bool
+	}{
+		{"/api/v1/pods", "api", "/api/v1/pods", "https://api.cluster1.com/", false},
+		{"/registry/v2/_catalog", "registry", "/v2/_catalog", "https://apps.cluster1.com", false},
+		{"/console/project/john-preview", "console", "/project/john-preview", "https://console.cluster1.com/console", true},
+		{"/metrics/anything", undefine, "/metrics/anything", "https://api.cluster1.com/", false},
+	}
+	for _, table := range tables {
+		req := createRequestWithPath(table.reqPath)
+		reqRoute := routes.getRequestRoute(req)
+		reqRoute.stripPathPrefix(req)
+		assert.Equalf(t, table.wantType, reqRoute.reqType, "reqPath=%s", table.reqPath)
+		assert.Equalf(t, table.wantTarget, reqRoute.getTargetURL(ns), "reqPath=%s", table.reqPath)
+		assert.Equalf(t, table.wantRedirect, reqRoute.isRedirectRequest(), "reqPath=%s", table.reqPath)
+		assertRequestPath(t, req, table.wantPath)
+	}
+}
+
+func TestRequestRoutesInvalid(t *testing.T) {
+	tables := []*osio.Route{
+		{Name: "api", PathPrefix: "api", TargetField: "cluster-url"},
+		{Name: "api", PathPrefix: "/api", TargetField: "unknown-url"},
+		{Name: "api", PathPrefix: "/api", TargetField: "cluster-url", StripPrefix: "/metrics"},
+		{Name: "api", PathPrefix: "/api", TargetField: "cluster-url", ClusterField: "unknown-url"},
+		{PathPrefix: "/api", TargetField: "cluster-url"},
+	}
+	for _, table := range tables {
+		_, err := NewRequestRoutes([]*osio.Route{table})
+		assert.Errorf(t, err, "route=%+v", table)
+	}
+}
+
+func TestNewFromProvider(t *testing.T) {
+	for name, value := range map[string]string{
+		"AUTH_TOKEN_KEY":         "foo",
+		"TENANT_URL":             "http://tenant.example.com",
+		"AUTH_URL":               "http://auth.example.com",
+		"SERVICE_ACCOUNT_ID":     "oso-proxy",
+		"SERVICE_ACCOUNT_SECRET": "secret",
+	} {
+		os.Setenv(name, value)
+	}
+	keyring := osio.NewPassphraseKeyring("foo")
+
+	osioAuth, err := NewFromProvider(nil, keyring)
+	require.NoError(t, err)
+	assert.Equal(t, keyring, osioAuth.keyring)
+
+	osioAuth, err = NewFromProvider(&osio.Provider{
+		Issuers:   []*osio.Issuer{{Issuer: "https://auth.openshift.io", AuthURL: "https://auth.openshift.io", TenantURL: "https://tenant.openshift.io", ServiceAccountID: "oso-proxy", ServiceAccountSecret: "secret"}},
+		Anonymous: []*osio.AnonymousRoute{{Name: "healthz", Path: "/healthz"}},
+	}, keyring)
+	require.NoError(t, err)
+	require.Len(t, osioAuth.issuers, 1)
+	for _, iss := range osioAuth.issuers {
+		assert.Equal(t, keyring, iss.tenantTokenLocator.(*tenantTokenLocator).keyring)
+	}
+	assert.Len(t, osioAuth.anonymousRoutes, 1)
+
+	_, err = NewFromProvider(&osio.Provider{Issuers: []*osio.Issuer{{Issuer: "https://auth.openshift.io", AuthURL: "auth"}}}, keyring)
+	assert.Error(t, err)
+}
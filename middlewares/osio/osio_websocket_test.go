@@ -0,0 +1,85 @@
+package osio
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWebSocketExec simulates the handshake of `oc exec` from a browser, which sends
+// the OSIO token as a WebSocket subprotocol instead of an Authorization header.
+func TestWebSocketExec(t *testing.T) {
+	os.Setenv("AUTH_TOKEN_KEY", "foo")
+
+	tenantServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get(Authorization) != "Bearer 1000" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rw.Write([]byte(`{
+			"data": {
+				"attributes": {
+					"namespaces": [
+						{
+							"name": "john-preview-stage",
+							"type": "user",
+							"cluster-url": "http://api.cluster1.com"
+						}
+					]
+				}
+			}
+		}`))
+	}))
+	defer tenantServer.Close()
+	authServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get(Authorization) != "Bearer 1000" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rw.Write([]byte(`{"token_type":"bearer", "scope":"user","access_token":"1001"}`))
+	}))
+	defer authServer.Close()
+
+	osioAuth := NewOSIOAuth(tenantServer.URL, authServer.URL, "sa1", "secret")
+	osioAuth.RequestTokenType = func(token string) (TokenType, error) {
+		return UserToken, nil
+	}
+	osioRequest := NewOSIORequest()
+
+	var gotReq *http.Request
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		osioRequest.ServeHTTP(rw, req, func(rw http.ResponseWriter, req *http.Request) {
+			osioAuth.ServeHTTP(rw, req, func(rw http.ResponseWriter, req *http.Request) {
+				gotReq = req
+				rw.WriteHeader(http.StatusSwitchingProtocols)
+			})
+		})
+	}
+
+	path := "/api/v1/namespaces/john-preview-stage/pods/nodejs-1-abcde/exec?command=sh&stdin=true&stdout=true&tty=true"
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set(WebSocketProtocolHeader, fmt.Sprintf("base64url.bearer.authorization.k8s.io.%s, base64.channel.k8s.io", base64.RawURLEncoding.EncodeToString([]byte("1000"))))
+	rw := httptest.NewRecorder()
+
+	handler(rw, req)
+
+	assert.Equal(t, http.StatusSwitchingProtocols, rw.Code)
+	require.NotNil(t, gotReq)
+	assert.Equal(t, "http://api.cluster1.com", gotReq.Header.Get("Target"))
+	assert.Equal(t, "Bearer 1001", gotReq.Header.Get(Authorization))
+	protocols := gotReq.Header.Get(WebSocketProtocolHeader)
+	assert.Equal(t, "base64url.bearer.authorization.k8s.io.MTAwMQ, base64.channel.k8s.io", protocols)
+	assert.False(t, strings.Contains(protocols, base64.RawURLEncoding.EncodeToString([]byte("1000"))), "OSIO token must not be forwarded")
+	assert.Equal(t, "/api/v1/namespaces/john-preview-stage/pods/nodejs-1-abcde/exec", gotReq.URL.Path)
+	assert.Equal(t, "websocket", gotReq.Header.Get("Upgrade"))
+}
@@ -0,0 +1,252 @@
+package osio
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/provider/osio"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const (
+	defaultPassthroughCacheSeconds = 300
+	serviceAccountIssuer           = "kubernetes/serviceaccount"
+	currentUserPath                = "/apis/user.openshift.io/v1/users/~"
+
+	// failedDiscoveryCacheDuration is how long a token unknown by the clusters is not discovered again.
+	failedDiscoveryCacheDuration = 30 * time.Second
+	// maxFailedDiscoveries bounds the failed discoveries in the cache, any token being tried as an OpenShift one.
+	maxFailedDiscoveries = 10000
+	// maxConcurrentDiscoveries bounds the discovery calls made to the clusters at the same time.
+	maxConcurrentDiscoveries = 16
+)
+
+// passthrough resolves the cluster of native OpenShift tokens, which are forwarded untouched.
+type passthrough struct {
+	config osio.Passthrough
+	client *http.Client
+
+	mux      sync.Mutex
+	cache    map[string]discoveredCluster
+	failures int
+	// the expired discoveries are evicted once the cache reaches evictionSize, twice the size left
+	// by the last eviction, or once the failures reach their bound and the first one expired,
+	// so that the cache is not scanned on every store
+	evictionSize   int
+	failuresExpiry time.Time
+	now            func() time.Time
+	discoveries    chan struct{}
+}
+
+// discoveredCluster is the cluster of a token, none if the token is unknown by the clusters.
+type discoveredCluster struct {
+	clusterURL string
+	user       string
+	expiry     time.Time
+}
+
+type currentUserResponse struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+}
+
+func newPassthrough(config osio.Passthrough, client *http.Client) *passthrough {
+	if config.ClusterHeader == "" {
+		config.ClusterHeader = osio.DefaultPassthroughClusterHeader
+	}
+	if config.CacheSeconds == 0 {
+		config.CacheSeconds = defaultPassthroughCacheSeconds
+	}
+	return &passthrough{
+		config:       config,
+		client:       client,
+		cache:        make(map[string]discoveredCluster),
+		evictionSize: maxFailedDiscoveries,
+		now:          time.Now,
+		discoveries:  make(chan struct{}, maxConcurrentDiscoveries),
+	}
+}
+
+// SetPassthrough enables the forwarding of native OpenShift tokens to their cluster.
+func (a *OSIOAuth) SetPassthrough(config *osio.Passthrough) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	a.passthrough = newPassthrough(*config, http.DefaultClient)
+	return nil
+}
+
+// isNativeToken reports whether the token was issued by OpenShift rather than OSIO auth:
+// OAuth access tokens are not JWT, service account tokens are JWT issued by kubernetes.
+func isNativeToken(token string) bool {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err != nil {
+		return true
+	}
+	iss, _ := claims["iss"].(string)
+	return iss == serviceAccountIssuer
+}
+
+func (a *OSIOAuth) servePassthrough(rw http.ResponseWriter, r *http.Request, token string, next http.HandlerFunc) {
+	reqRoute := a.routes.getRequestRoute(r)
+	if reqRoute.targetField != clusterURLField || reqRoute.isRedirectRequest() {
+		log.Errorf("Route '%s' is not available with OpenShift tokens, path='%s'", reqRoute.reqType, r.URL.Path)
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	clusterURL, user, err := a.passthrough.resolveCluster(r, token, a.knownClusters())
+	if err != nil {
+		log.Errorf("Failed to locate cluster of OpenShift token, %v", err)
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	reqRoute.stripPathPrefix(r)
+	r.Header.Del(a.passthrough.config.ClusterHeader)
+	r.Header.Set("Target", normalizeURL(clusterURL))
+	dbg := getDebugInfo(r)
+	dbg.set(DebugRouteHeader, string(reqRoute.reqType))
+	dbg.set(DebugTargetHeader, normalizeURL(clusterURL))
+	if user != "" {
+		r = withIdentity(r, Identity{Subject: user, User: user, Namespace: getNamespaceName(r.URL.Path)})
+	}
+	next(rw, r)
+}
+
+func (a *OSIOAuth) knownClusters() []string {
+	if a.clusterTokens == nil {
+		return nil
+	}
+	return a.clusterTokens.Clusters()
+}
+
+// resolveCluster returns the cluster of the token from, in order, the cluster header,
+// the host mapping and the discovery, along with the token user when discovered.
+func (p *passthrough) resolveCluster(r *http.Request, token string, knownClusters []string) (string, string, error) {
+	if clusterURL := r.Header.Get(p.config.ClusterHeader); clusterURL != "" {
+		for _, known := range knownClusters {
+			if normalizeURL(clusterURL) == known {
+				return known, "", nil
+			}
+		}
+		return "", "", fmt.Errorf("unknown cluster '%s' in header %s", clusterURL, p.config.ClusterHeader)
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = h
+	}
+	if clusterURL, ok := p.config.Hosts[host]; ok {
+		return clusterURL, "", nil
+	}
+
+	if !p.config.Discover {
+		return "", "", fmt.Errorf("no cluster header, host mapping or discovery for host '%s'", host)
+	}
+	return p.discover(r, token, knownClusters)
+}
+
+// discover asks each known cluster who the token belongs to, the first one knowing the token being its cluster.
+// The tokens unknown by all the clusters are remembered for a short time, as any token which is not an OSIO
+// one is discovered: the discoveries of the clients sending invalid tokens would otherwise reach all the clusters.
+func (p *passthrough) discover(r *http.Request, token string, knownClusters []string) (string, string, error) {
+	key := cacheKey(token)
+	p.mux.Lock()
+	cached, ok := p.cache[key]
+	p.mux.Unlock()
+	if ok && p.now().Before(cached.expiry) {
+		if cached.clusterURL == "" {
+			return "", "", fmt.Errorf("token not known by any of the %d clusters (cached)", len(knownClusters))
+		}
+		return cached.clusterURL, cached.user, nil
+	}
+
+	select {
+	case p.discoveries <- struct{}{}:
+		defer func() { <-p.discoveries }()
+	case <-r.Context().Done():
+		return "", "", r.Context().Err()
+	}
+
+	for _, clusterURL := range knownClusters {
+		user, err := p.currentUser(clusterURL, token)
+		if err != nil {
+			log.Debugf("Token not known by cluster %s, %v", clusterURL, err)
+			continue
+		}
+		p.store(key, discoveredCluster{
+			clusterURL: clusterURL,
+			user:       user,
+			expiry:     p.now().Add(time.Duration(p.config.CacheSeconds) * time.Second),
+		})
+		return clusterURL, user, nil
+	}
+	p.store(key, discoveredCluster{expiry: p.now().Add(failedDiscoveryCacheDuration)})
+	return "", "", fmt.Errorf("token not known by any of the %d clusters", len(knownClusters))
+}
+
+func (p *passthrough) store(key string, discovered discoveredCluster) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if len(p.cache) >= p.evictionSize || (discovered.clusterURL == "" && p.failures >= maxFailedDiscoveries && !p.now().Before(p.failuresExpiry)) {
+		p.evictExpired()
+	}
+	if discovered.clusterURL == "" {
+		if p.failures >= maxFailedDiscoveries {
+			return
+		}
+		p.failures++
+	}
+	if previous, ok := p.cache[key]; ok && previous.clusterURL == "" {
+		p.failures--
+	}
+	p.cache[key] = discovered
+}
+
+func (p *passthrough) currentUser(clusterURL, token string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, normalizeURL(clusterURL)+currentUserPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(Authorization, "Bearer "+token)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Call to '%s' failed with status '%s'", req.URL, resp.Status)
+	}
+	var user currentUserResponse
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", err
+	}
+	return user.Metadata.Name, nil
+}
+
+// evictExpired removes the expired discoveries, so that the cache does not grow with expired tokens.
+func (p *passthrough) evictExpired() {
+	now := p.now()
+	p.failuresExpiry = time.Time{}
+	for key, cached := range p.cache {
+		if !now.Before(cached.expiry) {
+			delete(p.cache, key)
+			if cached.clusterURL == "" {
+				p.failures--
+			}
+		} else if cached.clusterURL == "" && (p.failuresExpiry.IsZero() || cached.expiry.Before(p.failuresExpiry)) {
+			p.failuresExpiry = cached.expiry
+		}
+	}
+	p.evictionSize = 2 * len(p.cache)
+	if p.evictionSize < maxFailedDiscoveries {
+		p.evictionSize = maxFailedDiscoveries
+	}
+}
@@ -0,0 +1,246 @@
+package osio
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/provider/osio"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newClusterServer serves the current user of the given token, counting the calls.
+func newClusterServer(token, user string, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if req.URL.Path != currentUserPath || req.Header.Get(Authorization) != "Bearer "+token {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rw.Write([]byte(`{"kind":"User","metadata":{"name":"` + user + `"}}`))
+	}))
+}
+
+func newPassthroughTestAuth(t *testing.T, config *osio.Passthrough, clusters ...string) *OSIOAuth {
+	osioAuth := newIdentityTestAuth(UserToken)
+	osioAuth.RequestTokenType = func(string) (TokenType, error) {
+		t.Fatal("token type should not be requested for OpenShift tokens")
+		return UserToken, nil
+	}
+	osioAuth.clusterTokens = osio.NewClusterTokenStore()
+	osioAuth.clusterTokens.Retain(clusters)
+	require.NoError(t, osioAuth.SetPassthrough(config))
+	return osioAuth
+}
+
+func TestIsNativeToken(t *testing.T) {
+	saToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": serviceAccountIssuer}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	assert.True(t, isNativeToken("GkQ3r6Rt5cJy5-PaZ8qbHJMiwDlhaMwfH2TuAgN4Gbk"))
+	assert.True(t, isNativeToken(saToken))
+	assert.False(t, isNativeToken(createSubjectToken(t, "john")))
+}
+
+func TestPassthrough(t *testing.T) {
+	var calls1, calls2 int32
+	cluster1 := newClusterServer("other_token", "jane", &calls1)
+	defer cluster1.Close()
+	cluster2 := newClusterServer("oso_native_token", "john", &calls2)
+	defer cluster2.Close()
+
+	tests := []struct {
+		desc             string
+		config           osio.Passthrough
+		host             string
+		header           string
+		path             string
+		expectedStatus   int
+		expectedTarget   string
+		expectedPath     string
+		expectedIdentity *Identity
+	}{
+		{
+			desc:           "cluster header",
+			header:         cluster2.URL + "/",
+			path:           "/api/v1/namespaces/john-preview/pods",
+			expectedStatus: http.StatusOK,
+			expectedTarget: cluster2.URL,
+			expectedPath:   "/api/v1/namespaces/john-preview/pods",
+		},
+		{
+			desc:           "unknown cluster header",
+			header:         "http://api.unknown.com",
+			path:           "/api/v1/namespaces/john-preview/pods",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:           "host mapping",
+			config:         osio.Passthrough{Hosts: map[string]string{"api.cluster2.com": cluster2.URL}},
+			host:           "api.cluster2.com:8443",
+			path:           "/api/v1/namespaces/john-preview/pods",
+			expectedStatus: http.StatusOK,
+			expectedTarget: cluster2.URL,
+			expectedPath:   "/api/v1/namespaces/john-preview/pods",
+		},
+		{
+			desc:             "discovery",
+			config:           osio.Passthrough{Discover: true},
+			path:             "/api/v1/namespaces/john-preview/pods",
+			expectedStatus:   http.StatusOK,
+			expectedTarget:   cluster2.URL,
+			expectedPath:     "/api/v1/namespaces/john-preview/pods",
+			expectedIdentity: &Identity{Subject: "john", User: "john", Namespace: "john-preview"},
+		},
+		{
+			desc:           "discovery disabled",
+			path:           "/api/v1/namespaces/john-preview/pods",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:           "metrics route",
+			config:         osio.Passthrough{Discover: true},
+			path:           "/metrics/api/v1/namespaces/john-preview/pods",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			osioAuth := newPassthroughTestAuth(t, &test.config, cluster1.URL, cluster2.URL)
+
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.host != "" {
+				req.Host = test.host
+			}
+			req.Header.Set(Authorization, "Bearer oso_native_token")
+			if test.header != "" {
+				req.Header.Set(osio.DefaultPassthroughClusterHeader, test.header)
+			}
+
+			rw := httptest.NewRecorder()
+			var forwarded *http.Request
+			osioAuth.ServeHTTP(rw, req, func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req
+			})
+
+			assert.Equal(t, test.expectedStatus, rw.Code)
+			if test.expectedStatus != http.StatusOK {
+				assert.Nil(t, forwarded)
+				return
+			}
+			require.NotNil(t, forwarded)
+			assert.Equal(t, test.expectedTarget, forwarded.Header.Get("Target"))
+			assert.Equal(t, test.expectedPath, forwarded.URL.Path)
+			assert.Equal(t, "Bearer oso_native_token", forwarded.Header.Get(Authorization))
+			assert.Empty(t, forwarded.Header.Get(osio.DefaultPassthroughClusterHeader))
+			identity, found := GetIdentity(forwarded)
+			if test.expectedIdentity == nil {
+				assert.False(t, found)
+			} else {
+				assert.Equal(t, *test.expectedIdentity, identity)
+			}
+		})
+	}
+}
+
+func TestPassthroughDiscoveryCache(t *testing.T) {
+	var calls1, calls2 int32
+	cluster1 := newClusterServer("other_token", "jane", &calls1)
+	defer cluster1.Close()
+	cluster2 := newClusterServer("oso_native_token", "john", &calls2)
+	defer cluster2.Close()
+
+	osioAuth := newPassthroughTestAuth(t, &osio.Passthrough{Discover: true, CacheSeconds: 60}, cluster1.URL, cluster2.URL)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/john-preview/pods", nil)
+		req.Header.Set(Authorization, "Bearer oso_native_token")
+		rw := httptest.NewRecorder()
+		osioAuth.ServeHTTP(rw, req, func(rw http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, cluster2.URL, req.Header.Get("Target"))
+		})
+		assert.Equal(t, http.StatusOK, rw.Code)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls1))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls2))
+}
+
+func TestPassthroughFailedDiscoveryCache(t *testing.T) {
+	var calls1, calls2 int32
+	cluster1 := newClusterServer("other_token", "jane", &calls1)
+	defer cluster1.Close()
+	cluster2 := newClusterServer("oso_native_token", "john", &calls2)
+	defer cluster2.Close()
+
+	osioAuth := newPassthroughTestAuth(t, &osio.Passthrough{Discover: true, CacheSeconds: 60}, cluster1.URL, cluster2.URL)
+	now := time.Now()
+	osioAuth.passthrough.now = func() time.Time { return now }
+
+	request := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/john-preview/pods", nil)
+		req.Header.Set(Authorization, "Bearer not_a_token")
+		rw := httptest.NewRecorder()
+		osioAuth.ServeHTTP(rw, req, func(rw http.ResponseWriter, req *http.Request) {
+			t.Fatal("request with an unknown token should not be forwarded")
+		})
+		return rw.Code
+	}
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, request())
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls1))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls2))
+
+	// the token is discovered again once the failure expired
+	now = now.Add(failedDiscoveryCacheDuration)
+	assert.Equal(t, http.StatusUnauthorized, request())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls1))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls2))
+	assert.Equal(t, 1, osioAuth.passthrough.failures)
+}
+
+func TestPassthroughEviction(t *testing.T) {
+	p := newPassthrough(osio.Passthrough{Discover: true}, http.DefaultClient)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	for i := 0; i < maxFailedDiscoveries; i++ {
+		p.store(fmt.Sprintf("failure-%d", i), discoveredCluster{expiry: now.Add(failedDiscoveryCacheDuration)})
+	}
+	assert.Len(t, p.cache, maxFailedDiscoveries)
+
+	// the failures are bounded until the first one expired
+	p.store("rejected", discoveredCluster{expiry: now.Add(failedDiscoveryCacheDuration)})
+	assert.Len(t, p.cache, maxFailedDiscoveries)
+	assert.Equal(t, maxFailedDiscoveries, p.failures)
+
+	now = now.Add(failedDiscoveryCacheDuration)
+	p.store("stored", discoveredCluster{expiry: now.Add(failedDiscoveryCacheDuration)})
+	assert.Len(t, p.cache, 1)
+	assert.Equal(t, 1, p.failures)
+	assert.Equal(t, maxFailedDiscoveries, p.evictionSize)
+
+	// the expired discoveries are evicted once the cache reached the eviction size
+	for i := 1; i < maxFailedDiscoveries; i++ {
+		p.store(fmt.Sprintf("cluster-%d", i), discoveredCluster{clusterURL: "https://api.cluster1.com", expiry: now.Add(time.Second)})
+	}
+	assert.Len(t, p.cache, maxFailedDiscoveries)
+	now = now.Add(time.Minute)
+	p.store("cluster", discoveredCluster{clusterURL: "https://api.cluster1.com", expiry: now.Add(time.Second)})
+	assert.Len(t, p.cache, 1)
+	assert.Equal(t, 0, p.failures)
+}
+
+func TestSetPassthroughInvalid(t *testing.T) {
+	osioAuth := newIdentityTestAuth(UserToken)
+	err := osioAuth.SetPassthrough(&osio.Passthrough{Hosts: map[string]string{"api.cluster1.com": "not-a-url"}})
+	assert.Error(t, err)
+	assert.Nil(t, osioAuth.passthrough)
+}
@@ -0,0 +1,110 @@
+package osio
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/containous/traefik/provider/osio"
+)
+
+type RequestType string
+
+const undefine RequestType = ""
+
+const clusterURLField = "cluster-url"
+
+var namespaceURLFields = map[string]func(namespace) string{
+	clusterURLField:       func(ns namespace) string { return ns.ClusterURL },
+	"cluster-metrics-url": func(ns namespace) string { return ns.ClusterMetricsURL },
+	"cluster-console-url": func(ns namespace) string { return ns.ClusterConsoleURL },
+	"cluster-logging-url": func(ns namespace) string { return ns.ClusterLoggingURL },
+	"cluster-app-domain":  func(ns namespace) string { return osio.HostURL(ns.ClusterAppDomain) },
+}
+
+// requestRoute is the middleware side of an osio.Route.
+type requestRoute struct {
+	reqType       RequestType
+	targetField   string
+	pathPrefix    string
+	stripPrefix   string
+	targetURL     func(namespace) string
+	redirect      bool
+	redirectQuery bool
+}
+
+// undefineRoute is used for requests which match none of the configured routes.
+var undefineRoute = &requestRoute{reqType: undefine, targetField: clusterURLField, targetURL: namespaceURLFields[clusterURLField]}
+
+// RequestRoutes selects the route of a request by the longest matching path prefix.
+type RequestRoutes struct {
+	routes []*requestRoute
+}
+
+// NewRequestRoutes creates RequestRoutes from the given route configuration.
+func NewRequestRoutes(routes []*osio.Route) (*RequestRoutes, error) {
+	rr := &RequestRoutes{}
+	for _, route := range routes {
+		if err := route.Validate(); err != nil {
+			return nil, err
+		}
+		targetURL, ok := namespaceURLFields[route.TargetField]
+		if !ok {
+			return nil, fmt.Errorf("route %q: unknown target field %q", route.Name, route.TargetField)
+		}
+		rr.routes = append(rr.routes, &requestRoute{
+			reqType:       RequestType(route.Name),
+			targetField:   route.TargetField,
+			pathPrefix:    route.PathPrefix,
+			stripPrefix:   route.StripPrefix,
+			targetURL:     targetURL,
+			redirect:      route.Redirect,
+			redirectQuery: route.RedirectQuery,
+		})
+	}
+	sort.SliceStable(rr.routes, func(i, j int) bool {
+		return len(rr.routes[i].pathPrefix) > len(rr.routes[j].pathPrefix)
+	})
+	return rr, nil
+}
+
+func defaultRequestRoutes() *RequestRoutes {
+	rr, err := NewRequestRoutes(osio.DefaultRoutes())
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+func (rr *RequestRoutes) getRequestRoute(req *http.Request) *requestRoute {
+	for _, route := range rr.routes {
+		if strings.HasPrefix(req.URL.Path, route.pathPrefix) {
+			return route
+		}
+	}
+	return undefineRoute
+}
+
+func (r *requestRoute) getTargetURL(ns namespace) string {
+	return r.targetURL(ns)
+}
+
+func (r *requestRoute) stripPathPrefix(req *http.Request) {
+	if r.stripPrefix == "" {
+		return
+	}
+	stripRequestPathPrefix(req, r.pathPrefix, r.stripPrefix)
+}
+
+func (r *requestRoute) isRedirectRequest() bool {
+	return r.redirect
+}
+
+func (r *requestRoute) getRedirectURL(targetURL string, req *http.Request) string {
+	redirectURL := targetURL + req.URL.Path
+	if r.redirectQuery && req.URL.RawQuery != "" {
+		redirectURL = strings.Join([]string{redirectURL, "?", req.URL.RawQuery}, "")
+	}
+	return redirectURL
+}
@@ -47,6 +47,7 @@ type namespace struct {
 	ClusterMetricsURL string `json:"cluster-metrics-url,omitempty"`
 	ClusterConsoleURL string `json:"cluster-console-url,omitempty"`
 	ClusterLoggingURL string `json:"cluster-logging-url,omitempty"`
+	ClusterAppDomain  string `json:"cluster-app-domain,omitempty"`
 }
 
 func getNamespace(resp response, tokenType TokenType) (ns namespace, err error) {
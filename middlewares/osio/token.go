@@ -1,17 +1,12 @@
 package osio
 
 import (
-	"bytes"
-	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
 
 	"github.com/containous/traefik/provider/osio"
-	"golang.org/x/crypto/openpgp"
 )
 
 type tokenResponse struct {
@@ -23,6 +18,7 @@ type tokenResponse struct {
 type tenantTokenLocator struct {
 	client      *http.Client
 	authBaseURL string
+	keyring     *osio.Keyring
 }
 
 func (t *tenantTokenLocator) GetTokenWithUserToken(userToken, location string) (string, error) {
@@ -30,13 +26,15 @@ func (t *tenantTokenLocator) GetTokenWithUserToken(userToken, location string) (
 }
 
 func (t *tenantTokenLocator) GetTokenWithSAToken(saToken, location string) (string, error) {
-	// TODO this clusterToken can be cached in sync with osio.provider
 	encryptedClusterToken, err := locateToken(t.client, t.authBaseURL, saToken, location)
 	if err != nil {
 		return "", err
 	}
-	passphrase := os.Getenv("AUTH_TOKEN_KEY")
-	clusterToken, err := gpgDecyptToken(encryptedClusterToken, passphrase)
+	keyring := t.keyring
+	if keyring == nil {
+		keyring = osio.NewPassphraseKeyring(os.Getenv("AUTH_TOKEN_KEY"))
+	}
+	clusterToken, _, err := keyring.Decrypt(encryptedClusterToken)
 	if err != nil {
 		return "", err
 	}
@@ -85,28 +83,3 @@ func locateToken(client *http.Client, authBaseURL, token, location string) (stri
 	}
 	return t.AccessToken, nil
 }
-
-func gpgDecyptToken(base64Body, passphrase string) (string, error) {
-	decodedEnc, err := base64.StdEncoding.DecodeString(base64Body)
-	if err != nil {
-		return "", err
-	}
-	decbuf := bytes.NewBuffer(decodedEnc)
-	firstCall := true
-	md, err := openpgp.ReadMessage(decbuf, nil, func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
-		if firstCall {
-			firstCall = false
-			return []byte(passphrase), nil
-		}
-		return nil, errors.New("unable to decrypt token with given key")
-
-	}, nil)
-	if err != nil {
-		return "", err
-	}
-	bytes, err := ioutil.ReadAll(md.UnverifiedBody)
-	if err != nil {
-		return "", err
-	}
-	return string(bytes), nil
-}
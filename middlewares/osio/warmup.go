@@ -0,0 +1,278 @@
+package osio
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/provider/osio"
+	"github.com/containous/traefik/safe"
+)
+
+// recentIdentity is a snapshot entry: the identity of a cache entry, never its tokens.
+type recentIdentity struct {
+	Issuer    string    `json:"issuer,omitempty"`
+	User      string    `json:"user"`
+	Namespace string    `json:"namespace"`
+	TokenType TokenType `json:"tokenType"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+type identitySnapshot struct {
+	Identities []recentIdentity `json:"identities"`
+}
+
+type recentKey struct {
+	issuer    string
+	user      string
+	namespace string
+	tokenType TokenType
+}
+
+// warmup tracks the recently active identities, and holds the entries pre-resolved from the
+// previous snapshot until a request resolving the same identity takes them.
+type warmup struct {
+	config osio.Warmup
+
+	mux    sync.Mutex
+	recent map[recentKey]time.Time
+	warmed map[recentKey]cacheData
+	now    func() time.Time
+}
+
+func newWarmup(config osio.Warmup) *warmup {
+	return &warmup{
+		config: config,
+		recent: make(map[recentKey]time.Time),
+		warmed: make(map[recentKey]cacheData),
+		now:    time.Now,
+	}
+}
+
+// SetWarmup pre-resolves in the background the entries of the identities of the last snapshot,
+// and snapshots the recently active identities periodically and when the pool stops.
+func (a *OSIOAuth) SetWarmup(config *osio.Warmup, pool *safe.Pool) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	w := newWarmup(*config)
+	identities, err := loadSnapshot(config.File)
+	if err != nil && !os.IsNotExist(err) {
+		log.Errorf("Error loading OSIO cache snapshot %s, starting with an empty cache: %v", config.File, err)
+	}
+	for _, identity := range identities {
+		w.recordAt(recentKey{identity.Issuer, identity.User, identity.Namespace, identity.TokenType}, identity.LastSeen)
+	}
+	a.warmup = w
+
+	pool.Go(func(stop chan bool) {
+		a.prewarm(identities, stop)
+	})
+	pool.Go(func(stop chan bool) {
+		ticker := time.NewTicker(time.Duration(config.SnapshotSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				if err := w.snapshot(); err != nil {
+					log.Errorf("Error writing OSIO cache snapshot: %v", err)
+				}
+				return
+			case <-ticker.C:
+				if err := w.snapshot(); err != nil {
+					log.Errorf("Error writing OSIO cache snapshot: %v", err)
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// prewarm resolves the service token entries of the identities with the service account token of their issuer,
+// with at most config.Concurrency resolutions at a time so that the tenant service is not overwhelmed.
+// The tenant service is still asked about the token of the request using a pre-resolved entry: only the
+// cluster calls locating the secret of the namespace are saved.
+func (a *OSIOAuth) prewarm(identities []recentIdentity, stop chan bool) {
+	var candidates []recentIdentity
+	for _, identity := range identities {
+		if a.warmup.prewarmable(identity) {
+			candidates = append(candidates, identity)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	var resolved int
+	var resolvedMux sync.Mutex
+	jobs := make(chan recentIdentity)
+	var wg sync.WaitGroup
+	for i := 0; i < a.warmup.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for identity := range jobs {
+				if a.prewarmIdentity(identity) {
+					resolvedMux.Lock()
+					resolved++
+					resolvedMux.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, identity := range candidates {
+		select {
+		case jobs <- identity:
+		case <-stop:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	log.Infof("Pre-resolved %d of %d recent OSIO identities", resolved, len(candidates))
+}
+
+func (a *OSIOAuth) prewarmIdentity(identity recentIdentity) bool {
+	iss := a.defaultIssuer()
+	if identity.Issuer != "" {
+		var ok bool
+		if iss, ok = a.issuers[identity.Issuer]; !ok {
+			return false
+		}
+	}
+	saToken, err := iss.srvAccTokenLocator()
+	if err != nil {
+		log.Debugf("Failed to pre-resolve user %s, %v", identity.User, err)
+		return false
+	}
+	val, err := a.cacheResolverByID(iss, saToken, identity.TokenType, identity.User, identity.Namespace)()
+	data, ok := val.(cacheData)
+	if err != nil || !ok {
+		log.Debugf("Failed to pre-resolve user %s in namespace %s, %v", identity.User, identity.Namespace, err)
+		return false
+	}
+	data.Prewarmed = true
+	a.warmup.store(recentKey{identity.Issuer, identity.User, identity.Namespace, identity.TokenType}, data)
+	return true
+}
+
+// prewarmable reports whether the entry of the identity can be resolved without the token of the request.
+func (w *warmup) prewarmable(identity recentIdentity) bool {
+	return identity.TokenType != UserToken && identity.User != "" && identity.Namespace != "" &&
+		w.now().Sub(identity.LastSeen) <= w.maxAge()
+}
+
+func (w *warmup) maxAge() time.Duration {
+	return time.Duration(w.config.MaxAgeSeconds) * time.Second
+}
+
+func (w *warmup) record(key recentKey) {
+	w.recordAt(key, w.now())
+}
+
+func (w *warmup) recordAt(key recentKey, lastSeen time.Time) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	if lastSeen.After(w.recent[key]) {
+		w.recent[key] = lastSeen
+	}
+}
+
+func (w *warmup) store(key recentKey, data cacheData) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	w.warmed[key] = data
+}
+
+// take removes and returns the pre-resolved entry of the identity, unless it is too old.
+func (w *warmup) take(key recentKey) (cacheData, bool) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	data, ok := w.warmed[key]
+	if !ok {
+		return cacheData{}, false
+	}
+	delete(w.warmed, key)
+	return data, w.now().Sub(data.Created) <= w.maxAge()
+}
+
+// evict removes the pre-resolved entries for which match returns true and returns how many were removed.
+func (w *warmup) evict(match func(data cacheData) bool) int {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	count := 0
+	for key, data := range w.warmed {
+		if match(data) {
+			delete(w.warmed, key)
+			count++
+		}
+	}
+	return count
+}
+
+// snapshot writes the identities active within the max age, replacing the file atomically.
+func (w *warmup) snapshot() error {
+	w.mux.Lock()
+	snapshot := identitySnapshot{Identities: []recentIdentity{}}
+	now := w.now()
+	for key, lastSeen := range w.recent {
+		if now.Sub(lastSeen) > w.maxAge() {
+			delete(w.recent, key)
+			continue
+		}
+		snapshot.Identities = append(snapshot.Identities, recentIdentity{
+			Issuer:    key.issuer,
+			User:      key.user,
+			Namespace: key.namespace,
+			TokenType: key.tokenType,
+			LastSeen:  lastSeen,
+		})
+	}
+	w.mux.Unlock()
+
+	// most recent first, so that the most active identities are pre-resolved first
+	sort.Slice(snapshot.Identities, func(i, j int) bool {
+		return snapshot.Identities[i].LastSeen.After(snapshot.Identities[j].LastSeen)
+	})
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(w.config.File), filepath.Base(w.config.File))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), w.config.File)
+}
+
+func loadSnapshot(filename string) ([]recentIdentity, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot identitySnapshot
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot.Identities, nil
+}
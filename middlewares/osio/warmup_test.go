@@ -0,0 +1,200 @@
+package osio
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/provider/osio"
+	"github.com/containous/traefik/safe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingTenantLocator counts the tenant calls and the maximum number of concurrent calls.
+type countingTenantLocator struct {
+	stubTenantLocator
+	delay time.Duration
+	// denied is a token the tenant service does not authorize
+	denied string
+
+	mux         sync.Mutex
+	calls       int
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *countingTenantLocator) GetTenantById(token string, tokenType TokenType, userID string) (namespace, error) {
+	c.mux.Lock()
+	c.calls++
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.mux.Unlock()
+
+	time.Sleep(c.delay)
+
+	c.mux.Lock()
+	c.inFlight--
+	c.mux.Unlock()
+	if token == c.denied {
+		return namespace{}, fmt.Errorf("token not authorized for user %s", userID)
+	}
+	return c.ns, nil
+}
+
+func (c *countingTenantLocator) stats() (int, int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.calls, c.maxInFlight
+}
+
+func writeSnapshot(t *testing.T, filename string, identities []recentIdentity) {
+	w := newWarmup(osio.Warmup{File: filename, MaxAgeSeconds: 3600})
+	for _, identity := range identities {
+		w.recordAt(recentKey{identity.Issuer, identity.User, identity.Namespace, identity.TokenType}, identity.LastSeen)
+	}
+	require.NoError(t, w.snapshot())
+}
+
+func TestWarmupSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warmup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "identities.json")
+
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	w := newWarmup(osio.Warmup{File: filename, MaxAgeSeconds: 3600})
+	w.now = func() time.Time { return now }
+	w.recordAt(recentKey{"", "11111111", "john-preview-che", CheToken}, now.Add(-time.Minute))
+	w.recordAt(recentKey{"", "11111111", "john-preview-che", CheToken}, now.Add(-2*time.Hour))
+	w.recordAt(recentKey{"https://auth.prod-preview.openshift.io", "john", "john-preview", UserToken}, now.Add(-time.Second))
+	w.recordAt(recentKey{"", "22222222", "jane-preview-che", CheToken}, now.Add(-2*time.Hour))
+	w.store(recentKey{"", "11111111", "john-preview-che", CheToken}, cacheData{Token: "oso_secret"})
+	require.NoError(t, w.snapshot())
+
+	identities, err := loadSnapshot(filename)
+	require.NoError(t, err)
+	assert.Equal(t, []recentIdentity{
+		{Issuer: "https://auth.prod-preview.openshift.io", User: "john", Namespace: "john-preview", TokenType: UserToken, LastSeen: now.Add(-time.Second)},
+		{User: "11111111", Namespace: "john-preview-che", TokenType: CheToken, LastSeen: now.Add(-time.Minute)},
+	}, identities)
+
+	content, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "oso_secret")
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "temporary snapshot files are renamed")
+}
+
+func TestWarmupPrewarm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warmup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "identities.json")
+
+	now := time.Now()
+	identities := []recentIdentity{
+		{User: "john", Namespace: "john-preview", TokenType: UserToken, LastSeen: now},
+		{User: "33333333", Namespace: "old-preview-che", TokenType: CheToken, LastSeen: now.Add(-2 * time.Hour)},
+		{Issuer: "https://auth.unknown.io", User: "44444444", Namespace: "unknown-preview-che", TokenType: CheToken, LastSeen: now},
+	}
+	for i := 0; i < 10; i++ {
+		identities = append(identities, recentIdentity{User: fmt.Sprintf("%08d", i), Namespace: fmt.Sprintf("user%d-preview-che", i), TokenType: CheToken, LastSeen: now})
+	}
+	writeSnapshot(t, filename, identities)
+
+	osioAuth := newIdentityTestAuth(CheToken)
+	tenants := &countingTenantLocator{
+		stubTenantLocator: stubTenantLocator{ns: namespace{Name: "user0-preview-che", ClusterURL: "http://api.cluster1.com"}},
+		delay:             10 * time.Millisecond,
+	}
+	osioAuth.RequestTenantLocation = tenants
+
+	pool := safe.NewPool(context.Background())
+	require.NoError(t, osioAuth.SetWarmup(&osio.Warmup{File: filename, MaxAgeSeconds: 3600, Concurrency: 2}, pool))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if calls, _ := tenants.stats(); calls == 10 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	calls, maxInFlight := tenants.stats()
+	assert.Equal(t, 10, calls, "only the recent service token identities of known issuers are pre-resolved")
+	assert.True(t, maxInFlight <= 2, "at most 2 concurrent resolutions, got %d", maxInFlight)
+
+	// a token the tenant service does not authorize does not get the pre-resolved entry
+	tenants.mux.Lock()
+	tenants.denied = createSubjectToken(t, "intruder")
+	tenants.mux.Unlock()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/user0-preview-che/pods", nil)
+	req.Header.Set(Authorization, "Bearer "+tenants.denied)
+	req.Header.Set(UserIDHeader, "00000000")
+	osioAuth.ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, req *http.Request) {
+		t.Fatal("request with a denied token should not be forwarded")
+	})
+
+	// the pre-resolved entry is told apart from a resolution by its secret
+	key := recentKey{"", "00000000", "user0-preview-che", CheToken}
+	data, ok := osioAuth.warmup.take(key)
+	require.True(t, ok)
+	data.Token = "prewarmed_secret"
+	osioAuth.warmup.store(key, data)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/user0-preview-che/pods", nil)
+	req.Header.Set(Authorization, "Bearer "+createSubjectToken(t, "che"))
+	req.Header.Set(UserIDHeader, "00000000")
+	var forwarded *http.Request
+	osioAuth.ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req
+	})
+	require.NotNil(t, forwarded)
+	assert.Equal(t, "Bearer prewarmed_secret", forwarded.Header.Get(Authorization), "the pre-resolved entry is used")
+	calls, _ = tenants.stats()
+	assert.Equal(t, 12, calls, "the tenant service authorizes each token")
+
+	// the identities are snapshotted again when the pool stops
+	pool.Stop()
+	snapshot, err := loadSnapshot(filename)
+	require.NoError(t, err)
+	assert.Len(t, snapshot, 12)
+}
+
+func TestWarmupTake(t *testing.T) {
+	now := time.Now()
+	w := newWarmup(osio.Warmup{MaxAgeSeconds: 60})
+	key := recentKey{"", "11111111", "john-preview-che", CheToken}
+
+	w.store(key, cacheData{Token: "oso_secret", Created: now})
+	data, ok := w.take(key)
+	assert.True(t, ok)
+	assert.Equal(t, "oso_secret", data.Token)
+	_, ok = w.take(key)
+	assert.False(t, ok, "entries are taken once")
+
+	w.store(key, cacheData{Token: "oso_secret", Created: now.Add(-2 * time.Minute)})
+	_, ok = w.take(key)
+	assert.False(t, ok, "expired entry")
+}
+
+func TestWarmupEvict(t *testing.T) {
+	osioAuth := newIdentityTestAuth(CheToken)
+	osioAuth.warmup = newWarmup(osio.Warmup{MaxAgeSeconds: 60})
+	osioAuth.warmup.store(recentKey{"", "11111111", "john-preview-che", CheToken}, cacheData{UserID: "11111111", NamespaceName: "john-preview-che"})
+	osioAuth.warmup.store(recentKey{"", "22222222", "jane-preview-che", CheToken}, cacheData{UserID: "22222222", NamespaceName: "jane-preview-che"})
+
+	assert.Equal(t, 1, osioAuth.EvictCache(CacheFilter{User: "11111111"}))
+	assert.Equal(t, 1, osioAuth.FlushCache())
+}
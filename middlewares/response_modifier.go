@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+)
+
+type responseModifierKey struct{}
+
+// ResponseModifier sets the response modifier of a frontend on its requests, for the forwarder
+// of its backend, which may be shared with other frontends, to apply it to the backend responses.
+type ResponseModifier struct {
+	modify func(*http.Response) error
+}
+
+// NewResponseModifier constructs a new ResponseModifier instance.
+func NewResponseModifier(modify func(*http.Response) error) *ResponseModifier {
+	return &ResponseModifier{modify: modify}
+}
+
+func (m *ResponseModifier) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	next(rw, req.WithContext(context.WithValue(req.Context(), responseModifierKey{}, m.modify)))
+}
+
+// ModifyResponse applies the response modifier set on the request of the response, if any.
+func ModifyResponse(res *http.Response) error {
+	if res == nil || res.Request == nil {
+		return nil
+	}
+	if modify, ok := res.Request.Context().Value(responseModifierKey{}).(func(*http.Response) error); ok {
+		return modify(res)
+	}
+	return nil
+}
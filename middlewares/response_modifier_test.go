@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseModifier(t *testing.T) {
+	modifier := NewResponseModifier(func(res *http.Response) error {
+		res.Header.Set("X-Frontend", "frontend1")
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.NoError(t, ModifyResponse(&http.Response{Header: http.Header{}, Request: req}))
+
+	var res *http.Response
+	modifier.ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, req *http.Request) {
+		res = &http.Response{Header: http.Header{}, Request: req}
+		require.NoError(t, ModifyResponse(res))
+	})
+	assert.Equal(t, "frontend1", res.Header.Get("X-Frontend"))
+}
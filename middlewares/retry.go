@@ -2,12 +2,15 @@ package middlewares
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
 )
 
 // Compile time validation that the response writer implements http interfaces correctly.
@@ -18,6 +21,8 @@ type Retry struct {
 	attempts int
 	next     http.Handler
 	listener RetryListener
+	// policy retries on status and waits between the attempts, network errors only are retried when nil
+	policy *retryPolicy
 }
 
 // NewRetry returns a new Retry instance
@@ -29,31 +34,85 @@ func NewRetry(attempts int, next http.Handler, listener RetryListener) *Retry {
 	}
 }
 
+// NewRetryWithPolicy returns a new Retry instance applying the retry policy of a backend.
+// The attempts of the policy, when set, override the given ones.
+func NewRetryWithPolicy(attempts int, config *types.Retry, next http.Handler, listener RetryListener) (*Retry, error) {
+	policy, err := newRetryPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Attempts > 0 {
+		attempts = config.Attempts
+	}
+	return &Retry{
+		attempts: attempts,
+		next:     next,
+		listener: listener,
+		policy:   policy,
+	}, nil
+}
+
 func (retry *Retry) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	// an upgraded connection is hijacked by the forwarder, it can not be replayed
+	if IsUpgradeRequest(r) {
+		retry.next.ServeHTTP(rw, r)
+		return
+	}
+
+	// the body of the requests retried on status is replayed at each attempt
+	var body []byte
+	retryOnStatus := retry.attempts > 1 && retry.policy.retriesOnStatus(r)
+	if retryOnStatus {
+		body, retryOnStatus = readReplayableBody(r)
+	}
+
 	// if we might make multiple attempts, swap the body for an ioutil.NopCloser
 	// cf https://github.com/containous/traefik/issues/1008
-	if retry.attempts > 1 {
+	if retry.attempts > 1 && !retryOnStatus {
 		body := r.Body
 		defer body.Close()
 		r.Body = ioutil.NopCloser(body)
 	}
 
+	retry.policy.request()
 	attempts := 1
 	for {
 		netErrorOccurred := false
 		// We pass in a pointer to netErrorOccurred so that we can set it to true on network errors
 		// when proxying the HTTP requests to the backends. This happens in the custom RecordingErrorHandler.
 		newCtx := context.WithValue(r.Context(), defaultNetErrCtxKey, &netErrorOccurred)
-		retryResponseWriter := newRetryResponseWriter(rw, attempts >= retry.attempts, &netErrorOccurred)
+		attemptsExhausted := attempts >= retry.attempts || !retry.policy.allowRetry()
+		var retryResponseWriter retryResponseWriter
+		if retryOnStatus {
+			if body != nil {
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+			retryResponseWriter = newStatusRetryResponseWriter(rw, attemptsExhausted, &netErrorOccurred, retry.policy.retryOn)
+		} else {
+			retryResponseWriter = newRetryResponseWriter(rw, attemptsExhausted, &netErrorOccurred)
+		}
 
 		retry.next.ServeHTTP(retryResponseWriter, r.WithContext(newCtx))
 		if !retryResponseWriter.ShouldRetry() {
 			break
 		}
 
+		wait := retry.policy.backoff(attempts, retryResponseWriter.RetryAfter())
 		attempts++
-		log.Debugf("New attempt %d for request: %v", attempts, r.URL)
+		log.Debugf("New attempt %d for request: %v in %s", attempts, r.URL, wait)
+		retry.policy.retry()
 		retry.listener.Retried(r, attempts)
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				log.Debugf("Request %v canceled while waiting for attempt %d", r.URL, attempts)
+				rw.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		}
 	}
 }
 
@@ -104,15 +163,36 @@ type retryResponseWriter interface {
 	http.ResponseWriter
 	http.Flusher
 	ShouldRetry() bool
+	// RetryAfter is the delay requested by the Retry-After header of a status retried
+	RetryAfter() time.Duration
 }
 
 func newRetryResponseWriter(rw http.ResponseWriter, attemptsExhausted bool, netErrorOccured *bool) retryResponseWriter {
-	responseWriter := &retryResponseWriterWithoutCloseNotify{
+	return wrapRetryResponseWriter(&retryResponseWriterWithoutCloseNotify{
 		responseWriter:    rw,
 		attemptsExhausted: attemptsExhausted,
 		netErrorOccured:   netErrorOccured,
+	})
+}
+
+// newStatusRetryResponseWriter creates a writer also retrying on the status accepted by retryOn,
+// the response headers being only written to rw with a status not retried.
+func newStatusRetryResponseWriter(rw http.ResponseWriter, attemptsExhausted bool, netErrorOccured *bool, retryOn func(int, http.Header) (time.Duration, bool)) retryResponseWriter {
+	header := make(http.Header)
+	for name, values := range rw.Header() {
+		header[name] = values
 	}
-	if _, ok := rw.(http.CloseNotifier); ok {
+	return wrapRetryResponseWriter(&retryResponseWriterWithoutCloseNotify{
+		responseWriter:    rw,
+		attemptsExhausted: attemptsExhausted,
+		netErrorOccured:   netErrorOccured,
+		retryOn:           retryOn,
+		header:            header,
+	})
+}
+
+func wrapRetryResponseWriter(responseWriter *retryResponseWriterWithoutCloseNotify) retryResponseWriter {
+	if _, ok := responseWriter.responseWriter.(http.CloseNotifier); ok {
 		return &retryResponseWriterWithCloseNotify{responseWriter}
 	}
 	return responseWriter
@@ -122,30 +202,66 @@ type retryResponseWriterWithoutCloseNotify struct {
 	responseWriter    http.ResponseWriter
 	attemptsExhausted bool
 	netErrorOccured   *bool
+	retryOn           func(int, http.Header) (time.Duration, bool)
+	// header holds the headers of an attempt which may be retried on status, until its status is written
+	header      http.Header
+	wroteHeader bool
+	retryStatus int
+	retryAfter  time.Duration
 }
 
 func (rr *retryResponseWriterWithoutCloseNotify) ShouldRetry() bool {
-	return *rr.netErrorOccured && !rr.attemptsExhausted
+	return (*rr.netErrorOccured || rr.retryStatus != 0) && !rr.attemptsExhausted
+}
+
+func (rr *retryResponseWriterWithoutCloseNotify) RetryAfter() time.Duration {
+	return rr.retryAfter
 }
 
 func (rr *retryResponseWriterWithoutCloseNotify) Header() http.Header {
 	if rr.ShouldRetry() {
 		return make(http.Header)
 	}
+	if rr.header != nil {
+		return rr.header
+	}
 	return rr.responseWriter.Header()
 }
 
 func (rr *retryResponseWriterWithoutCloseNotify) Write(buf []byte) (int, error) {
+	if !rr.wroteHeader {
+		rr.WriteHeader(http.StatusOK)
+	}
 	if rr.ShouldRetry() {
-		return 0, nil
+		return len(buf), nil
 	}
 	return rr.responseWriter.Write(buf)
 }
 
 func (rr *retryResponseWriterWithoutCloseNotify) WriteHeader(code int) {
+	rr.wroteHeader = true
 	if rr.ShouldRetry() {
 		return
 	}
+	if rr.retryOn != nil && !rr.attemptsExhausted {
+		if retryAfter, ok := rr.retryOn(code, rr.header); ok {
+			rr.retryStatus = code
+			rr.retryAfter = retryAfter
+			return
+		}
+	}
+	if rr.header != nil {
+		header := rr.responseWriter.Header()
+		for name := range header {
+			if _, ok := rr.header[name]; !ok {
+				delete(header, name)
+			}
+		}
+		for name, values := range rr.header {
+			header[name] = values
+		}
+		rr.header = nil
+	}
 	rr.responseWriter.WriteHeader(code)
 }
 
@@ -154,6 +270,9 @@ func (rr *retryResponseWriterWithoutCloseNotify) Hijack() (net.Conn, *bufio.Read
 }
 
 func (rr *retryResponseWriterWithoutCloseNotify) Flush() {
+	if rr.ShouldRetry() {
+		return
+	}
 	if flusher, ok := rr.responseWriter.(http.Flusher); ok {
 		flusher.Flush()
 	}
@@ -0,0 +1,219 @@
+package middlewares
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/types"
+)
+
+const (
+	// DefaultRetryInitialInterval is the default maximum wait before the first retry.
+	DefaultRetryInitialInterval = 100 * time.Millisecond
+	// DefaultRetryMaxInterval is the default maximum wait between two attempts.
+	DefaultRetryMaxInterval = 5 * time.Second
+
+	// maxReplayedBodyBytes is the size of the largest request body replayed to retry on status.
+	maxReplayedBodyBytes = 1 << 20
+
+	retryBudgetWindow = 10 * time.Second
+	// retryBudgetMinRetries are the retries always allowed by the budget in its window, for low traffic
+	retryBudgetMinRetries = 10
+)
+
+var defaultIdempotentMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete}
+
+// retryPolicy is the retry policy of a backend, its methods are no-ops on a nil policy.
+type retryPolicy struct {
+	status          types.HTTPCodeRanges
+	methods         map[string]bool
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	budget          *retryBudget
+}
+
+func newRetryPolicy(config *types.Retry) (*retryPolicy, error) {
+	status, err := types.NewHTTPCodeRanges(config.Status)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry status %v: %v", config.Status, err)
+	}
+	if config.Budget < 0 || config.Budget > 100 {
+		return nil, fmt.Errorf("invalid retry budget %d%%", config.Budget)
+	}
+
+	policy := &retryPolicy{
+		status:          status,
+		methods:         make(map[string]bool),
+		initialInterval: time.Duration(config.InitialInterval),
+		maxInterval:     time.Duration(config.MaxInterval),
+	}
+	methods := config.Methods
+	if len(methods) == 0 {
+		methods = defaultIdempotentMethods
+	}
+	for _, method := range methods {
+		policy.methods[strings.ToUpper(method)] = true
+	}
+	if policy.initialInterval <= 0 {
+		policy.initialInterval = DefaultRetryInitialInterval
+	}
+	if policy.maxInterval <= 0 {
+		policy.maxInterval = DefaultRetryMaxInterval
+	}
+	if policy.maxInterval < policy.initialInterval {
+		return nil, fmt.Errorf("retry max interval %s is lower than the initial interval %s", policy.maxInterval, policy.initialInterval)
+	}
+	if config.Budget > 0 {
+		policy.budget = &retryBudget{percent: config.Budget, now: time.Now}
+	}
+	return policy, nil
+}
+
+// retriesOnStatus tells whether the request may be retried on status, its method being idempotent.
+func (p *retryPolicy) retriesOnStatus(r *http.Request) bool {
+	return p != nil && len(p.status) > 0 && p.methods[r.Method]
+}
+
+// retryOn tells whether the response status is retried and the delay requested by its Retry-After header,
+// a response asking to retry later than the max interval being returned.
+func (p *retryPolicy) retryOn(status int, header http.Header) (time.Duration, bool) {
+	if !p.status.Contains(status) {
+		return 0, false
+	}
+	retryAfter := parseRetryAfter(header.Get("Retry-After"))
+	return retryAfter, retryAfter <= p.maxInterval
+}
+
+// backoff returns the wait before the next attempt, after the given failed attempts:
+// a random delay up to the exponentially growing interval unless the backend requested one.
+func (p *retryPolicy) backoff(attempts int, retryAfter time.Duration) time.Duration {
+	if p == nil {
+		return 0
+	}
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	interval := p.maxInterval
+	if attempts <= 32 {
+		if exp := p.initialInterval << uint(attempts-1); exp > 0 && exp < interval {
+			interval = exp
+		}
+	}
+	return time.Duration(rand.Int63n(int64(interval) + 1))
+}
+
+func (p *retryPolicy) request() {
+	if p != nil {
+		p.budget.add(1, 0)
+	}
+}
+
+func (p *retryPolicy) retry() {
+	if p != nil {
+		p.budget.add(0, 1)
+	}
+}
+
+func (p *retryPolicy) allowRetry() bool {
+	return p == nil || p.budget.allow()
+}
+
+// parseRetryAfter parses the delay in seconds or the date of a Retry-After header.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// readReplayableBody reads the body of the request, which can be replayed unless too large.
+func readReplayableBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil, true
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxReplayedBodyBytes+1))
+	if err == nil && len(body) <= maxReplayedBodyBytes {
+		r.Body.Close()
+		return body, true
+	}
+	// the attempt gets what was read and the rest of the body
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	return nil, false
+}
+
+// retryBudget limits the retries to a percentage of the requests of the last retryBudgetWindow,
+// its methods are no-ops on a nil budget.
+type retryBudget struct {
+	percent int
+	now     func() time.Time
+
+	mux sync.Mutex
+	// buckets count the requests and retries of the seconds of the window
+	buckets [retryBudgetWindow / time.Second]retryBudgetBucket
+}
+
+type retryBudgetBucket struct {
+	second   int64
+	requests int
+	retries  int
+}
+
+func (b *retryBudget) add(requests, retries int) {
+	if b == nil {
+		return
+	}
+	second := b.now().Unix()
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	bucket := &b.buckets[second%int64(len(b.buckets))]
+	if bucket.second != second {
+		*bucket = retryBudgetBucket{second: second}
+	}
+	bucket.requests += requests
+	bucket.retries += retries
+}
+
+func (b *retryBudget) allow() bool {
+	if b == nil {
+		return true
+	}
+	second := b.now().Unix()
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	var requests, retries int
+	for _, bucket := range b.buckets {
+		if bucket.second > second-int64(len(b.buckets)) {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+	allowed := requests * b.percent / 100
+	if allowed < retryBudgetMinRetries {
+		allowed = retryBudgetMinRetries
+	}
+	return retries < allowed
+}
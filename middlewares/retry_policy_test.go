@@ -0,0 +1,208 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusFailingHTTPHandler responds with the status of its call, if any, echoing the request body.
+type statusFailingHTTPHandler struct {
+	status     map[int]int
+	retryAfter string
+	callNumber int
+	bodies     []string
+}
+
+func (handler *statusFailingHTTPHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	handler.callNumber++
+	body, _ := ioutil.ReadAll(r.Body)
+	handler.bodies = append(handler.bodies, string(body))
+
+	rw.Header().Set("X-Attempt", strconv.Itoa(handler.callNumber))
+	if status, ok := handler.status[handler.callNumber]; ok {
+		rw.Header().Set("X-Failed", "true")
+		if handler.retryAfter != "" {
+			rw.Header().Set("Retry-After", handler.retryAfter)
+		}
+		rw.WriteHeader(status)
+		rw.Write([]byte("failed"))
+		return
+	}
+	rw.Write([]byte("ok"))
+}
+
+func TestRetryOnStatus(t *testing.T) {
+	config := &types.Retry{
+		Attempts:        3,
+		Status:          []string{"502-504"},
+		InitialInterval: flaeg.Duration(time.Millisecond),
+	}
+	testCases := []struct {
+		desc           string
+		method         string
+		status         map[int]int
+		retryAfter     string
+		expectedStatus int
+		expectedBody   string
+		expectedCalls  int
+	}{
+		{
+			desc:           "retried",
+			method:         http.MethodPut,
+			status:         map[int]int{1: http.StatusServiceUnavailable, 2: http.StatusBadGateway},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+			expectedCalls:  3,
+		},
+		{
+			desc:           "attempts exhausted",
+			method:         http.MethodGet,
+			status:         map[int]int{1: http.StatusServiceUnavailable, 2: http.StatusServiceUnavailable, 3: http.StatusGatewayTimeout},
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedBody:   "failed",
+			expectedCalls:  3,
+		},
+		{
+			desc:           "status not retried",
+			method:         http.MethodGet,
+			status:         map[int]int{1: http.StatusInternalServerError},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "failed",
+			expectedCalls:  1,
+		},
+		{
+			desc:           "method not idempotent",
+			method:         http.MethodPost,
+			status:         map[int]int{1: http.StatusServiceUnavailable},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "failed",
+			expectedCalls:  1,
+		},
+		{
+			desc:           "retry after",
+			method:         http.MethodGet,
+			status:         map[int]int{1: http.StatusServiceUnavailable},
+			retryAfter:     "0",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+			expectedCalls:  2,
+		},
+		{
+			desc:           "retry after the max interval",
+			method:         http.MethodGet,
+			status:         map[int]int{1: http.StatusServiceUnavailable},
+			retryAfter:     "3600",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "failed",
+			expectedCalls:  1,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler := &statusFailingHTTPHandler{status: test.status, retryAfter: test.retryAfter}
+			listener := &countingRetryListener{}
+			retry, err := NewRetryWithPolicy(1, config, handler, listener)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("X-Frontend", "frontend")
+			req := httptest.NewRequest(test.method, "http://localhost/api", strings.NewReader("body"))
+			retry.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			assert.Equal(t, test.expectedBody, recorder.Body.String())
+			assert.Equal(t, test.expectedCalls, handler.callNumber)
+			assert.Equal(t, test.expectedCalls-1, listener.timesCalled)
+			for _, body := range handler.bodies {
+				assert.Equal(t, "body", body, "replayed body")
+			}
+
+			// only the headers of the last attempt are sent
+			assert.Equal(t, strconv.Itoa(test.expectedCalls), recorder.Header().Get("X-Attempt"))
+			assert.Equal(t, test.expectedStatus != http.StatusOK, recorder.Header().Get("X-Failed") != "")
+			assert.Equal(t, "frontend", recorder.Header().Get("X-Frontend"))
+		})
+	}
+}
+
+func TestRetryPolicyNetworkError(t *testing.T) {
+	handler := &networkFailingHTTPHandler{failAtCalls: []int{1}, netErrorRecorder: &DefaultNetErrorRecorder{}}
+	listener := &countingRetryListener{}
+	retry, err := NewRetryWithPolicy(2, &types.Retry{InitialInterval: flaeg.Duration(time.Millisecond)}, handler, listener)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	retry.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "http://localhost/api", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 1, listener.timesCalled)
+}
+
+func TestRetryPolicyInvalid(t *testing.T) {
+	for desc, config := range map[string]*types.Retry{
+		"status":    {Status: []string{"50x"}},
+		"budget":    {Budget: 120},
+		"intervals": {InitialInterval: flaeg.Duration(time.Second), MaxInterval: flaeg.Duration(time.Millisecond)},
+	} {
+		_, err := NewRetryWithPolicy(2, config, http.NotFoundHandler(), RetryListeners{})
+		assert.Error(t, err, desc)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy, err := newRetryPolicy(&types.Retry{InitialInterval: flaeg.Duration(10 * time.Millisecond), MaxInterval: flaeg.Duration(50 * time.Millisecond)})
+	require.NoError(t, err)
+
+	for attempts, max := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 40 * time.Millisecond, 4: 50 * time.Millisecond, 100: 50 * time.Millisecond} {
+		for i := 0; i < 100; i++ {
+			wait := policy.backoff(attempts, 0)
+			assert.True(t, wait >= 0 && wait <= max, "attempts %d: %s", attempts, wait)
+		}
+	}
+	assert.Equal(t, 30*time.Millisecond, policy.backoff(1, 30*time.Millisecond), "retry after")
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1"))
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Wed, 21 Oct 2015 07:28:00 GMT"))
+
+	wait := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, wait > 58*time.Second && wait <= time.Minute, "%s", wait)
+}
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Unix(1000, 0)
+	budget := &retryBudget{percent: 20, now: func() time.Time { return now }}
+
+	// some retries are always allowed
+	for i := 0; i < retryBudgetMinRetries; i++ {
+		require.True(t, budget.allow())
+		budget.add(1, 1)
+	}
+	assert.False(t, budget.allow())
+
+	budget.add(90, 0)
+	assert.True(t, budget.allow(), "10 retries for 100 requests")
+	for i := 0; i < 10; i++ {
+		budget.add(0, 1)
+	}
+	assert.False(t, budget.allow(), "20 retries for 100 requests")
+
+	now = now.Add(retryBudgetWindow)
+	assert.True(t, budget.allow(), "window elapsed")
+}
@@ -0,0 +1,268 @@
+package middlewares
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+)
+
+// Reasons of the rejections of the SizeLimit middleware.
+const (
+	RejectedURLTooLong           = "URLTooLong"
+	RejectedHeaderTooLarge       = "HeaderTooLarge"
+	RejectedRequestBodyTooLarge  = "RequestBodyTooLarge"
+	RejectedResponseBodyTooLarge = "ResponseBodyTooLarge"
+)
+
+var (
+	errRequestBodyTooLarge  = errors.New("request body too large")
+	errResponseBodyTooLarge = errors.New("response body too large")
+)
+
+// SizeLimitListener is used to inform about the requests and responses over a limit.
+type SizeLimitListener interface {
+	// Rejected is called with the reason of the rejection of a request, or of its response
+	Rejected(req *http.Request, reason string)
+}
+
+// SizeLimit rejects the requests of a frontend with a too long URL or too large headers or body,
+// and the too large responses of its backend. The bodies are limited while they are streamed,
+// without buffering.
+type SizeLimit struct {
+	maxRequestBodyBytes  int64
+	maxResponseBodyBytes int64
+	maxHeaderBytes       int
+	maxURLLength         int
+	listener             SizeLimitListener
+}
+
+// NewSizeLimit creates the size limits of a frontend, the listener being optional.
+func NewSizeLimit(config *types.Limits, listener SizeLimitListener) (*SizeLimit, error) {
+	if config.MaxRequestBodyBytes < 0 || config.MaxResponseBodyBytes < 0 || config.MaxHeaderBytes < 0 || config.MaxURLLength < 0 {
+		return nil, fmt.Errorf("invalid negative limit in %+v", *config)
+	}
+	return &SizeLimit{
+		maxRequestBodyBytes:  config.MaxRequestBodyBytes,
+		maxResponseBodyBytes: config.MaxResponseBodyBytes,
+		maxHeaderBytes:       config.MaxHeaderBytes,
+		maxURLLength:         config.MaxURLLength,
+		listener:             listener,
+	}, nil
+}
+
+func (l *SizeLimit) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	if l.maxURLLength > 0 && len(requestURI(req)) > l.maxURLLength {
+		l.reject(rw, req, RejectedURLTooLong, http.StatusRequestURITooLong)
+		return
+	}
+	if l.maxHeaderBytes > 0 && headerSize(req) > l.maxHeaderBytes {
+		l.reject(rw, req, RejectedHeaderTooLarge, http.StatusRequestHeaderFieldsTooLarge)
+		return
+	}
+	if l.maxRequestBodyBytes == 0 && l.maxResponseBodyBytes == 0 {
+		next.ServeHTTP(rw, req)
+		return
+	}
+
+	var body *limitedBody
+	if l.maxRequestBodyBytes > 0 {
+		if req.ContentLength > l.maxRequestBodyBytes {
+			l.reject(rw, req, RejectedRequestBodyTooLarge, http.StatusRequestEntityTooLarge)
+			return
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			// the body of a chunked request is only known while it is forwarded
+			body = &limitedBody{ReadCloser: req.Body, remaining: l.maxRequestBodyBytes}
+			req.Body = body
+		}
+	}
+
+	lrw := newSizeLimitResponseWriter(rw, l, req, body)
+	next.ServeHTTP(lrw, req)
+
+	if !lrw.wroteHeader && body.isExceeded() {
+		lrw.WriteHeader(http.StatusRequestEntityTooLarge)
+	}
+	if lrw.cutOff {
+		lrw.abort()
+	}
+}
+
+func (l *SizeLimit) reject(rw http.ResponseWriter, req *http.Request, reason string, code int) {
+	log.Debugf("Rejecting request %s %s: %s", req.Method, req.URL.Path, reason)
+	if l.listener != nil {
+		l.listener.Rejected(req, reason)
+	}
+	http.Error(rw, http.StatusText(code), code)
+}
+
+// requestURI returns the URL of the request as sent by the client.
+func requestURI(req *http.Request) string {
+	if req.RequestURI != "" {
+		return req.RequestURI
+	}
+	return req.URL.RequestURI()
+}
+
+// headerSize returns the size of the request headers, as they are sent on the wire with HTTP/1.
+func headerSize(req *http.Request) int {
+	size := len(req.Host)
+	for name, values := range req.Header {
+		for _, value := range values {
+			// name: value\r\n
+			size += len(name) + len(value) + 4
+		}
+	}
+	return size
+}
+
+// limitedBody fails the reads past the limit of a request body.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	// exceeded is set atomically, the body being read by the transport
+	exceeded int32
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errRequestBodyTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		atomic.StoreInt32(&b.exceeded, 1)
+		return n + int(b.remaining), errRequestBodyTooLarge
+	}
+	return n, err
+}
+
+func (b *limitedBody) isExceeded() bool {
+	return b != nil && atomic.LoadInt32(&b.exceeded) == 1
+}
+
+// sizeLimitResponseWriter replaces the response with an error when the request body was too large
+// or the response announces a too large body, and cuts off the response past the limit otherwise.
+type sizeLimitResponseWriter struct {
+	http.ResponseWriter
+	limit *SizeLimit
+	req   *http.Request
+	body  *limitedBody
+	// header holds the response headers until it is known whether the response is replaced
+	header      http.Header
+	wroteHeader bool
+	replaced    bool
+	written     int64
+	cutOff      bool
+}
+
+func newSizeLimitResponseWriter(rw http.ResponseWriter, limit *SizeLimit, req *http.Request, body *limitedBody) *sizeLimitResponseWriter {
+	header := make(http.Header)
+	for name, values := range rw.Header() {
+		header[name] = values
+	}
+	return &sizeLimitResponseWriter{ResponseWriter: rw, limit: limit, req: req, body: body, header: header}
+}
+
+func (w *sizeLimitResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *sizeLimitResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if w.body.isExceeded() {
+		w.replaced = true
+		w.limit.reject(w.ResponseWriter, w.req, RejectedRequestBodyTooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if w.limit.maxResponseBodyBytes > 0 {
+		if length, err := strconv.ParseInt(w.header.Get("Content-Length"), 10, 64); err == nil && length > w.limit.maxResponseBodyBytes {
+			w.replaced = true
+			w.limit.reject(w.ResponseWriter, w.req, RejectedResponseBodyTooLarge, http.StatusBadGateway)
+			return
+		}
+	}
+
+	header := w.ResponseWriter.Header()
+	for name, values := range w.header {
+		header[name] = values
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sizeLimitResponseWriter) Write(buf []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.replaced {
+		return len(buf), nil
+	}
+	if w.cutOff {
+		return 0, errResponseBodyTooLarge
+	}
+	if maxBytes := w.limit.maxResponseBodyBytes; maxBytes > 0 && w.written+int64(len(buf)) > maxBytes {
+		w.cutOff = true
+		if w.limit.listener != nil {
+			w.limit.listener.Rejected(w.req, RejectedResponseBodyTooLarge)
+		}
+		log.Debugf("Cutting off response of request %s %s: %s", w.req.Method, w.req.URL.Path, RejectedResponseBodyTooLarge)
+		return 0, errResponseBodyTooLarge
+	}
+	n, err := w.ResponseWriter.Write(buf)
+	w.written += int64(n)
+	return n, err
+}
+
+// abort closes the connection of a response cut off after its headers, so that the client
+// does not take it for a whole one. The HTTP/2 responses are only cut off.
+func (w *sizeLimitResponseWriter) abort() {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		log.Debugf("Error aborting response of request %s %s: %v", w.req.Method, w.req.URL.Path, err)
+		return
+	}
+	if err := conn.Close(); err != nil {
+		log.Debugf("Error closing connection of request %s %s: %v", w.req.Method, w.req.URL.Path, err)
+	}
+}
+
+// Hijack hijacks the connection
+func (w *sizeLimitResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (w *sizeLimitResponseWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush sends any buffered data to the client.
+func (w *sizeLimitResponseWriter) Flush() {
+	if w.replaced || w.cutOff {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,211 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+)
+
+// rejectionRecorder is a SizeLimitListener recording the rejection reasons.
+type rejectionRecorder struct {
+	mux     sync.Mutex
+	reasons []string
+}
+
+func (r *rejectionRecorder) Rejected(req *http.Request, reason string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.reasons = append(r.reasons, reason)
+}
+
+func (r *rejectionRecorder) recorded() []string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.reasons
+}
+
+// forwardBody answers with the request body, and 502 if it cannot be read as the forwarder.
+func forwardBody(rw http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	rw.Header().Set("X-Backend", "kept")
+	rw.Write(body)
+}
+
+func TestNewSizeLimitErrors(t *testing.T) {
+	for _, config := range []types.Limits{
+		{MaxRequestBodyBytes: -1},
+		{MaxResponseBodyBytes: -1},
+		{MaxHeaderBytes: -1},
+		{MaxURLLength: -1},
+	} {
+		_, err := NewSizeLimit(&config, nil)
+		assert.Error(t, err, "%+v", config)
+	}
+}
+
+func TestSizeLimit(t *testing.T) {
+	tests := []struct {
+		desc       string
+		config     types.Limits
+		target     string
+		header     http.Header
+		body       io.Reader
+		chunked    bool
+		wantCode   int
+		wantBody   string
+		wantReason string
+	}{
+		{
+			desc:     "no limits",
+			target:   "/api/v1/namespaces",
+			body:     strings.NewReader("namespace"),
+			wantCode: http.StatusOK,
+			wantBody: "namespace",
+		},
+		{
+			desc:     "under the limits",
+			config:   types.Limits{MaxRequestBodyBytes: 9, MaxResponseBodyBytes: 9, MaxHeaderBytes: 100, MaxURLLength: 18},
+			target:   "/api/v1/namespaces",
+			body:     strings.NewReader("namespace"),
+			wantCode: http.StatusOK,
+			wantBody: "namespace",
+		},
+		{
+			desc:       "URL too long",
+			config:     types.Limits{MaxURLLength: 18},
+			target:     "/api/v1/namespaces?watch=true",
+			wantCode:   http.StatusRequestURITooLong,
+			wantReason: RejectedURLTooLong,
+		},
+		{
+			desc:       "header too large",
+			config:     types.Limits{MaxHeaderBytes: 100},
+			target:     "/api",
+			header:     http.Header{"Authorization": {"Bearer " + strings.Repeat("x", 100)}},
+			wantCode:   http.StatusRequestHeaderFieldsTooLarge,
+			wantReason: RejectedHeaderTooLarge,
+		},
+		{
+			desc:       "announced body too large",
+			config:     types.Limits{MaxRequestBodyBytes: 8},
+			target:     "/api/v1/namespaces",
+			body:       strings.NewReader("namespace"),
+			wantCode:   http.StatusRequestEntityTooLarge,
+			wantReason: RejectedRequestBodyTooLarge,
+		},
+		{
+			desc:       "chunked body too large",
+			config:     types.Limits{MaxRequestBodyBytes: 8},
+			target:     "/api/v1/namespaces",
+			body:       strings.NewReader("namespace"),
+			chunked:    true,
+			wantCode:   http.StatusRequestEntityTooLarge,
+			wantReason: RejectedRequestBodyTooLarge,
+		},
+		{
+			desc:     "chunked body under the limit",
+			config:   types.Limits{MaxRequestBodyBytes: 9},
+			target:   "/api/v1/namespaces",
+			body:     strings.NewReader("namespace"),
+			chunked:  true,
+			wantCode: http.StatusOK,
+			wantBody: "namespace",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			listener := &rejectionRecorder{}
+			sizeLimit, err := NewSizeLimit(&test.config, listener)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPut, test.target, test.body)
+			for name, values := range test.header {
+				req.Header[name] = values
+			}
+			if test.chunked {
+				req.ContentLength = -1
+				req.Body = ioutil.NopCloser(req.Body)
+			}
+			rw := httptest.NewRecorder()
+			sizeLimit.ServeHTTP(rw, req, forwardBody)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+			if test.wantReason != "" {
+				assert.Equal(t, []string{test.wantReason}, listener.recorded())
+				assert.Empty(t, rw.Header().Get("X-Backend"))
+			} else {
+				assert.Empty(t, listener.recorded())
+				assert.Equal(t, test.wantBody, rw.Body.String())
+				assert.Equal(t, "kept", rw.Header().Get("X-Backend"))
+			}
+		})
+	}
+}
+
+func TestSizeLimitResponseContentLength(t *testing.T) {
+	listener := &rejectionRecorder{}
+	sizeLimit, err := NewSizeLimit(&types.Limits{MaxResponseBodyBytes: 8}, listener)
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	rw.Header().Set("X-Frontend", "kept")
+	sizeLimit.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/api", nil), func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Length", "9")
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte("namespace"))
+	})
+
+	assert.Equal(t, http.StatusBadGateway, rw.Code)
+	assert.Equal(t, "kept", rw.Header().Get("X-Frontend"))
+	assert.NotEqual(t, "9", rw.Header().Get("Content-Length"))
+	assert.NotContains(t, rw.Body.String(), "namespace")
+	assert.Equal(t, []string{RejectedResponseBodyTooLarge}, listener.recorded())
+}
+
+func TestSizeLimitResponseStreamed(t *testing.T) {
+	listener := &rejectionRecorder{}
+	sizeLimit, err := NewSizeLimit(&types.Limits{MaxResponseBodyBytes: 16}, listener)
+	require.NoError(t, err)
+
+	n := negroni.New(sizeLimit)
+	n.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		for i := 0; i < 4; i++ {
+			if _, err := rw.Write(bytes.Repeat([]byte("x"), 8)); err != nil {
+				return
+			}
+			rw.(http.Flusher).Flush()
+		}
+	})
+	server := httptest.NewServer(n)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the connection is closed after the bytes under the limit
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Error(t, err)
+	assert.Equal(t, strings.Repeat("x", 16), string(body))
+	assert.Equal(t, []string{RejectedResponseBodyTooLarge}, listener.recorded())
+}
@@ -0,0 +1,140 @@
+package middlewares
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/middlewares/balancer"
+	"github.com/containous/traefik/types"
+	gokitmetrics "github.com/go-kit/kit/metrics"
+	"github.com/vulcand/oxy/utils"
+)
+
+// splitMetrics is the part of metrics.Registry recording the requests of the split frontends.
+type splitMetrics interface {
+	FrontendSplitReqsCounter() gokitmetrics.Counter
+}
+
+// Split forwards the requests of a frontend to one of its backends, chosen by weight.
+type Split struct {
+	frontend string
+	backends []*splitBackend
+	total    int
+	hashKey  utils.SourceExtractor
+	cookie   string
+
+	reqsCounter gokitmetrics.Counter
+}
+
+type splitBackend struct {
+	name    string
+	weight  int
+	handler http.Handler
+}
+
+// NewSplit creates the split of a frontend, whose backend handlers are given to PostLoad.
+// The requests with the same hash key go to the same backend, the others are spread at random.
+func NewSplit(frontendName string, config *types.Split, hashKey utils.SourceExtractor, registry splitMetrics) (*Split, error) {
+	s := &Split{
+		frontend:    frontendName,
+		hashKey:     hashKey,
+		cookie:      config.Cookie,
+		reqsCounter: registry.FrontendSplitReqsCounter(),
+	}
+	names := make(map[string]bool)
+	for _, backend := range config.Backends {
+		if backend.Backend == "" {
+			return nil, fmt.Errorf("missing split backend")
+		}
+		if names[backend.Backend] {
+			return nil, fmt.Errorf("duplicate split backend %s", backend.Backend)
+		}
+		if backend.Weight < 0 {
+			return nil, fmt.Errorf("invalid weight %d of split backend %s", backend.Weight, backend.Backend)
+		}
+		names[backend.Backend] = true
+		s.backends = append(s.backends, &splitBackend{name: backend.Backend, weight: backend.Weight})
+		s.total += backend.Weight
+	}
+	if s.total == 0 {
+		return nil, fmt.Errorf("no split backend with a weight")
+	}
+	return s, nil
+}
+
+// PostLoad sets the backend handlers, looked up by backend name.
+func (s *Split) PostLoad(handler func(backendName string) http.Handler) {
+	for _, backend := range s.backends {
+		backend.handler = handler(backend.name)
+	}
+}
+
+func (s *Split) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	backend := s.stickyBackend(req)
+	if backend == nil {
+		backend = s.pick(req)
+		if s.cookie != "" {
+			http.SetCookie(rw, &http.Cookie{Name: s.cookie, Value: backend.name, Path: "/"})
+		}
+	}
+
+	s.reqsCounter.With("frontend", s.frontend, "backend", backend.name).Add(1)
+	if backend.handler == nil {
+		log.Errorf("Missing handler of backend %s for split frontend %s", backend.name, s.frontend)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	backend.handler.ServeHTTP(rw, req)
+}
+
+// stickyBackend returns the backend set by the cookie of the request, if it still takes requests.
+func (s *Split) stickyBackend(req *http.Request) *splitBackend {
+	if s.cookie == "" {
+		return nil
+	}
+	cookie, err := req.Cookie(s.cookie)
+	if err != nil {
+		return nil
+	}
+	for _, backend := range s.backends {
+		if backend.name == cookie.Value && backend.weight > 0 {
+			return backend
+		}
+	}
+	return nil
+}
+
+// pick chooses a backend by weight, the point of the request on the cumulated weights being
+// set by its hash key if any: raising the weight of the last backend only moves requests to it.
+func (s *Split) pick(req *http.Request) *splitBackend {
+	var point int
+	if key := s.requestKey(req); key != "" {
+		point = int(float64(balancer.Hash(key)) / (1 << 64) * float64(s.total))
+		if point >= s.total {
+			point = s.total - 1
+		}
+	} else {
+		point = rand.Intn(s.total)
+	}
+	for _, backend := range s.backends {
+		if point < backend.weight {
+			return backend
+		}
+		point -= backend.weight
+	}
+	return nil
+}
+
+func (s *Split) requestKey(req *http.Request) string {
+	if s.hashKey == nil {
+		return ""
+	}
+	key, _, err := s.hashKey.Extract(req)
+	if err != nil {
+		log.Debugf("Error extracting the split key of frontend %s: %v", s.frontend, err)
+		return ""
+	}
+	return key
+}
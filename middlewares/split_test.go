@@ -0,0 +1,188 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/utils"
+)
+
+func TestNewSplitErrors(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config types.Split
+	}{
+		{
+			desc: "no backends",
+		},
+		{
+			desc:   "missing backend name",
+			config: types.Split{Backends: []types.WeightedBackend{{Weight: 1}}},
+		},
+		{
+			desc: "duplicate backend",
+			config: types.Split{Backends: []types.WeightedBackend{
+				{Backend: "stable", Weight: 1},
+				{Backend: "stable", Weight: 1},
+			}},
+		},
+		{
+			desc:   "negative weight",
+			config: types.Split{Backends: []types.WeightedBackend{{Backend: "stable", Weight: -1}}},
+		},
+		{
+			desc:   "no weight",
+			config: types.Split{Backends: []types.WeightedBackend{{Backend: "stable"}}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewSplit("frontend", &test.config, nil, &splitCounter{})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSplitWeights(t *testing.T) {
+	counter := &splitCounter{}
+	split := newTestSplit(t, &types.Split{Backends: []types.WeightedBackend{
+		{Backend: "stable", Weight: 90},
+		{Backend: "canary", Weight: 10},
+		{Backend: "drained", Weight: 0},
+	}}, nil, counter)
+
+	served := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		rw := httptest.NewRecorder()
+		split.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+		served[rw.Body.String()]++
+	}
+	assert.InDelta(t, 1800, served["stable"], 100)
+	assert.InDelta(t, 200, served["canary"], 100)
+	assert.Zero(t, served["drained"])
+	assert.Equal(t, served["canary"], int(counter.Value("frontend", "canary")))
+}
+
+func TestSplitHashKey(t *testing.T) {
+	key, err := utils.NewExtractor("request.header.X-User")
+	require.NoError(t, err)
+	route := func(split *Split, user string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-User", user)
+		rw := httptest.NewRecorder()
+		split.ServeHTTP(rw, req)
+		return rw.Body.String()
+	}
+
+	split := newTestSplit(t, &types.Split{Backends: []types.WeightedBackend{
+		{Backend: "stable", Weight: 80},
+		{Backend: "canary", Weight: 20},
+	}}, key, &splitCounter{})
+	routes := make(map[string]string)
+	for i := 0; i < 500; i++ {
+		user := fmt.Sprintf("user%d", i)
+		routes[user] = route(split, user)
+		assert.Equal(t, routes[user], route(split, user), "same user, same backend")
+	}
+
+	// raising the weight of the canary only moves users to it
+	split = newTestSplit(t, &types.Split{Backends: []types.WeightedBackend{
+		{Backend: "stable", Weight: 50},
+		{Backend: "canary", Weight: 50},
+	}}, key, &splitCounter{})
+	moved := 0
+	for user, backend := range routes {
+		if backend == "canary" {
+			assert.Equal(t, "canary", route(split, user), user)
+		} else if route(split, user) == "canary" {
+			moved++
+		}
+	}
+	assert.NotZero(t, moved)
+}
+
+func TestSplitCookie(t *testing.T) {
+	split := newTestSplit(t, &types.Split{
+		Backends: []types.WeightedBackend{
+			{Backend: "stable", Weight: 1},
+			{Backend: "canary", Weight: 1},
+			{Backend: "drained", Weight: 0},
+		},
+		Cookie: "_split",
+	}, nil, &splitCounter{})
+
+	rw := httptest.NewRecorder()
+	split.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := (&http.Response{Header: rw.Header()}).Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "_split", cookies[0].Name)
+	assert.Equal(t, rw.Body.String(), cookies[0].Value)
+
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+		rw := httptest.NewRecorder()
+		split.ServeHTTP(rw, req)
+		assert.Equal(t, cookies[0].Value, rw.Body.String())
+		assert.Empty(t, rw.Header().Get("Set-Cookie"))
+	}
+
+	// the clients of a backend without weight move to another one
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "_split", Value: "drained"})
+	rw = httptest.NewRecorder()
+	split.ServeHTTP(rw, req)
+	assert.NotEqual(t, "drained", rw.Body.String())
+	assert.Contains(t, rw.Header().Get("Set-Cookie"), "_split="+rw.Body.String())
+}
+
+func newTestSplit(t *testing.T, config *types.Split, hashKey utils.SourceExtractor, counter *splitCounter) *Split {
+	split, err := NewSplit("frontend", config, hashKey, counter)
+	require.NoError(t, err)
+	split.PostLoad(func(backendName string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(backendName))
+		})
+	})
+	return split
+}
+
+// splitCounter is a splitMetrics counting the requests of each backend.
+type splitCounter struct {
+	counters map[string]*generic.Counter
+}
+
+func (c *splitCounter) FrontendSplitReqsCounter() metrics.Counter {
+	return c
+}
+
+func (c *splitCounter) With(labelValues ...string) metrics.Counter {
+	if c.counters == nil {
+		c.counters = make(map[string]*generic.Counter)
+	}
+	key := fmt.Sprint(labelValues)
+	if c.counters[key] == nil {
+		c.counters[key] = generic.NewCounter(key)
+	}
+	return c.counters[key]
+}
+
+func (c *splitCounter) Add(delta float64) {}
+
+// Value returns the count of the requests of the backend of the frontend.
+func (c *splitCounter) Value(frontend, backend string) float64 {
+	if counter := c.counters[fmt.Sprint([]string{"frontend", frontend, "backend", backend})]; counter != nil {
+		return counter.Value()
+	}
+	return 0
+}
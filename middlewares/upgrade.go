@@ -0,0 +1,391 @@
+package middlewares
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/metrics"
+	gokitmetrics "github.com/go-kit/kit/metrics"
+	"github.com/vulcand/oxy/forward"
+	"github.com/vulcand/oxy/utils"
+)
+
+const (
+	upgradeClosed      = "closed"
+	upgradeIdleTimeout = "idle_timeout"
+	upgradeTimeout     = "timeout"
+)
+
+// UpgradeOptions configures the UpgradeForwarder.
+type UpgradeOptions struct {
+	// PassHostHeader forwards the client Host header to the backend server.
+	PassHostHeader bool
+	// IdleTimeout closes an upgraded connection without traffic in either direction for that long.
+	IdleTimeout time.Duration
+	// Timeout closes an upgraded connection after that long, whatever its traffic.
+	Timeout time.Duration
+}
+
+// UpgradeForwarder proxies requests which upgrade the connection to another protocol
+// (SPDY/3.1 used by exec, attach and port-forward, WebSocket, ...) to the backend server
+// selected by the load-balancer, and tunnels the raw connection once the backend
+// switched protocols. Other requests are passed to the next handler.
+// The backend connection is dialed directly, with the dialer and TLS configuration of the
+// transport, as the transport does not return a writable body for upgraded connections.
+type UpgradeForwarder struct {
+	next             http.Handler
+	dialer           *upgradeDialer
+	rewriter         forward.ReqRewriter
+	responseModifier func(*http.Response) error
+	errHandler       utils.ErrorHandler
+	options          UpgradeOptions
+
+	backendName       string
+	openConnsGauge    gokitmetrics.Gauge
+	durationHistogram gokitmetrics.Histogram
+
+	mux       sync.Mutex
+	openConns map[string]int
+}
+
+// NewUpgradeForwarder creates a new UpgradeForwarder. The response modifier, if any, is applied
+// to the backend responses to upgrade requests, as the forwarder does to the other responses.
+func NewUpgradeForwarder(next http.Handler, roundTripper http.RoundTripper, rewriter forward.ReqRewriter, responseModifier func(*http.Response) error,
+	errHandler utils.ErrorHandler, options UpgradeOptions, registry metrics.Registry, backendName string) *UpgradeForwarder {
+	if errHandler == nil {
+		errHandler = utils.DefaultHandler
+	}
+	return &UpgradeForwarder{
+		next:              next,
+		dialer:            newUpgradeDialer(roundTripper),
+		rewriter:          rewriter,
+		responseModifier:  responseModifier,
+		errHandler:        errHandler,
+		options:           options,
+		backendName:       backendName,
+		openConnsGauge:    registry.BackendUpgradedConnsGauge(),
+		durationHistogram: registry.BackendUpgradedConnDurationHistogram(),
+		openConns:         make(map[string]int),
+	}
+}
+
+// IsUpgradeRequest determines if the specified HTTP request asks to upgrade the connection to another protocol.
+func IsUpgradeRequest(req *http.Request) bool {
+	return containsHeader(req, "Connection", "upgrade") && req.Header.Get("Upgrade") != ""
+}
+
+// SkipUpgrade returns a handler which passes upgrade requests directly to next and the other
+// requests to handler. It shields upgrade requests from handlers which can not deal with
+// hijacked connections, such as response buffering.
+func SkipUpgrade(handler http.Handler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if IsUpgradeRequest(req) {
+			next.ServeHTTP(rw, req)
+		} else {
+			handler.ServeHTTP(rw, req)
+		}
+	})
+}
+
+func (u *UpgradeForwarder) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if !IsUpgradeRequest(req) {
+		u.next.ServeHTTP(rw, req)
+		return
+	}
+
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		log.Errorf("Unable to upgrade connection for %s: %T can not be hijacked", req.URL, rw)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	outReq := u.outRequest(req)
+	backendConn, err := u.dialer.dial(req.Context(), outReq.URL)
+	if err != nil {
+		log.Errorf("Error forwarding upgrade request to %s: %v", req.URL, err)
+		u.errHandler.ServeHTTP(rw, req, err)
+		return
+	}
+	defer backendConn.Close()
+
+	backendReader := bufio.NewReader(backendConn)
+	res, err := u.dialer.roundTrip(backendConn, backendReader, outReq)
+	if err != nil {
+		log.Errorf("Error forwarding upgrade request to %s: %v", req.URL, err)
+		u.errHandler.ServeHTTP(rw, req, err)
+		return
+	}
+
+	if u.responseModifier != nil {
+		if err := u.responseModifier(res); err != nil {
+			log.Errorf("Error modifying upgrade response for %s: %v", req.URL, err)
+			res.Body.Close()
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		// the backend refused to upgrade, forward its response as is
+		defer res.Body.Close()
+		utils.CopyHeaders(rw.Header(), res.Header)
+		rw.WriteHeader(res.StatusCode)
+		io.Copy(rw, res.Body)
+		return
+	}
+
+	clientConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		log.Errorf("Error hijacking connection for %s: %v", req.URL, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer clientConn.Close()
+
+	// the server deadlines (read/write timeouts) do not apply to an upgraded connection
+	clientConn.SetDeadline(time.Time{})
+
+	res.Body = nil
+	if err := res.Write(brw); err != nil {
+		log.Errorf("Error writing upgrade response for %s: %v", req.URL, err)
+		return
+	}
+	if err := brw.Flush(); err != nil {
+		log.Errorf("Error writing upgrade response for %s: %v", req.URL, err)
+		return
+	}
+
+	protocol := getUpgradeProtocol(req)
+	u.updateOpenConns(protocol, 1)
+	defer u.updateOpenConns(protocol, -1)
+
+	start := time.Now()
+	reason := u.tunnel(clientConn, brw, backendConn, backendReader)
+	log.Debugf("Upgraded %s connection to %s %s after %s", protocol, req.URL, reason, time.Since(start))
+	u.durationHistogram.With("backend", u.backendName, "protocol", protocol, "reason", reason).Observe(time.Since(start).Seconds())
+}
+
+// tunnel copies data between the client and the backend until one of them closes the
+// connection or a timeout elapses. It returns the reason why the tunnel was closed.
+func (u *UpgradeForwarder) tunnel(clientConn io.ReadWriteCloser, clientReader io.Reader, backendConn io.ReadWriteCloser, backendReader io.Reader) string {
+	var lastActivity int64
+	touch := func() {
+		atomic.StoreInt64(&lastActivity, time.Now().UnixNano())
+	}
+	touch()
+
+	var once sync.Once
+	reason := upgradeClosed
+	closeWith := func(r string) {
+		once.Do(func() {
+			reason = r
+			clientConn.Close()
+			backendConn.Close()
+		})
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	if u.options.Timeout > 0 {
+		timer := time.AfterFunc(u.options.Timeout, func() {
+			closeWith(upgradeTimeout)
+		})
+		defer timer.Stop()
+	}
+
+	if u.options.IdleTimeout > 0 {
+		go func() {
+			ticker := time.NewTicker(idleCheckInterval(u.options.IdleTimeout))
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					if time.Since(time.Unix(0, atomic.LoadInt64(&lastActivity))) >= u.options.IdleTimeout {
+						closeWith(upgradeIdleTimeout)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		errc <- copyWithActivity(backendConn, clientReader, touch)
+	}()
+	go func() {
+		errc <- copyWithActivity(clientConn, backendReader, touch)
+	}()
+	<-errc
+	closeWith(upgradeClosed)
+	<-errc
+
+	return reason
+}
+
+func (u *UpgradeForwarder) outRequest(req *http.Request) *http.Request {
+	outReq := new(http.Request)
+	*outReq = *req
+	outReq.Header = make(http.Header)
+	utils.CopyHeaders(outReq.Header, req.Header)
+	outReq.URL = utils.CopyURL(req.URL)
+	outReq.RequestURI = ""
+	outReq.Proto = "HTTP/1.1"
+	outReq.ProtoMajor = 1
+	outReq.ProtoMinor = 1
+	if !u.options.PassHostHeader {
+		outReq.Host = req.URL.Host
+	}
+	if u.rewriter != nil {
+		u.rewriter.Rewrite(outReq)
+	}
+	// hop-by-hop headers are not forwarded, except the ones requesting the upgrade
+	utils.RemoveHeaders(outReq.Header, forward.HopHeaders...)
+	outReq.Header.Set("Connection", "Upgrade")
+	outReq.Header.Set("Upgrade", req.Header.Get("Upgrade"))
+	return outReq
+}
+
+func (u *UpgradeForwarder) updateOpenConns(protocol string, delta int) {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	u.openConns[protocol] += delta
+	u.openConnsGauge.With("backend", u.backendName, "protocol", protocol).Set(float64(u.openConns[protocol]))
+}
+
+// getUpgradeProtocol returns the protocol requested by the Upgrade header, limited to well
+// known values so that it can be used as a metric label.
+func getUpgradeProtocol(req *http.Request) string {
+	upgrade := strings.ToLower(req.Header.Get("Upgrade"))
+	switch {
+	case strings.HasPrefix(upgrade, "spdy/"):
+		return "spdy"
+	case upgrade == protoWebsocket:
+		return protoWebsocket
+	case strings.HasPrefix(upgrade, "h2c"):
+		return "h2c"
+	default:
+		return "other"
+	}
+}
+
+func copyWithActivity(dst io.Writer, src io.Reader, activity func()) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			activity()
+			if _, errWrite := dst.Write(buf[:n]); errWrite != nil {
+				return errWrite
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func idleCheckInterval(idleTimeout time.Duration) time.Duration {
+	interval := idleTimeout / 10
+	if interval < 10*time.Millisecond {
+		return 10 * time.Millisecond
+	}
+	if interval > time.Second {
+		return time.Second
+	}
+	return interval
+}
+
+// upgradeDialer opens the backend connections of upgrade requests, which only speak HTTP/1.1
+// as HTTP/2 has no connection upgrade mechanism.
+type upgradeDialer struct {
+	dialContext           func(ctx context.Context, network, addr string) (net.Conn, error)
+	tlsClientConfig       *tls.Config
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+}
+
+// newUpgradeDialer returns a dialer using the settings of the transport, if the round tripper is one.
+func newUpgradeDialer(roundTripper http.RoundTripper) *upgradeDialer {
+	dialer := &upgradeDialer{dialContext: (&net.Dialer{}).DialContext}
+	transport, ok := roundTripper.(*http.Transport)
+	if !ok {
+		return dialer
+	}
+	if transport.DialContext != nil {
+		dialer.dialContext = transport.DialContext
+	}
+	if transport.TLSClientConfig != nil {
+		dialer.tlsClientConfig = transport.TLSClientConfig.Clone()
+	}
+	dialer.tlsHandshakeTimeout = transport.TLSHandshakeTimeout
+	dialer.responseHeaderTimeout = transport.ResponseHeaderTimeout
+	return dialer
+}
+
+// dial connects to the backend server of the URL, with TLS for https and wss URLs.
+func (d *upgradeDialer) dial(ctx context.Context, target *url.URL) (net.Conn, error) {
+	secure := target.Scheme == "https" || target.Scheme == "wss"
+	addr := target.Host
+	if target.Port() == "" {
+		if secure {
+			addr = net.JoinHostPort(target.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(target.Hostname(), "80")
+		}
+	}
+	conn, err := d.dialContext(ctx, "tcp", addr)
+	if err != nil || !secure {
+		return conn, err
+	}
+
+	config := &tls.Config{}
+	if d.tlsClientConfig != nil {
+		config = d.tlsClientConfig.Clone()
+	}
+	config.NextProtos = []string{"http/1.1"}
+	if config.ServerName == "" {
+		config.ServerName = target.Hostname()
+	}
+	tlsConn := tls.Client(conn, config)
+	if d.tlsHandshakeTimeout > 0 {
+		tlsConn.SetDeadline(time.Now().Add(d.tlsHandshakeTimeout))
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake with %s: %v", addr, err)
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// roundTrip sends the request on the backend connection and reads its response.
+func (d *upgradeDialer) roundTrip(conn net.Conn, reader *bufio.Reader, req *http.Request) (*http.Response, error) {
+	if d.responseHeaderTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(d.responseHeaderTimeout))
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Time{})
+	return res, nil
+}
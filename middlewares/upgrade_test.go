@@ -0,0 +1,207 @@
+package middlewares
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeForwarder(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Upgrade") != "SPDY/3.1" || req.Header.Get("X-Stream-Protocol-Version") != "v4.channel.k8s.io" {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+		conn, brw, err := rw.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: SPDY/3.1\r\nX-Stream-Protocol-Version: v4.channel.k8s.io\r\n\r\n")
+		brw.Flush()
+		// echo the stream back to the client
+		io.Copy(conn, brw)
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		desc        string
+		options     UpgradeOptions
+		send        bool
+		wantPayload string
+	}{
+		{
+			desc:        "echo",
+			send:        true,
+			wantPayload: "exec stream",
+		},
+		{
+			desc:    "idle timeout",
+			options: UpgradeOptions{IdleTimeout: 50 * time.Millisecond},
+		},
+		{
+			desc:        "timeout",
+			options:     UpgradeOptions{Timeout: 50 * time.Millisecond},
+			send:        true,
+			wantPayload: "exec stream",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			proxy := newUpgradeTestProxy(t, backend.URL, test.options)
+			defer proxy.Close()
+
+			conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("POST /api/v1/namespaces/john/pods/nodejs/exec?command=sh HTTP/1.1\r\n" +
+				"Host: oso-proxy\r\nConnection: Upgrade\r\nUpgrade: SPDY/3.1\r\nX-Stream-Protocol-Version: v4.channel.k8s.io\r\n\r\n"))
+			require.NoError(t, err)
+
+			reader := bufio.NewReader(conn)
+			res, err := http.ReadResponse(reader, nil)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+			assert.Equal(t, "v4.channel.k8s.io", res.Header.Get("X-Stream-Protocol-Version"))
+
+			if test.send {
+				_, err = conn.Write([]byte("exec stream"))
+				require.NoError(t, err)
+			}
+
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			payload := make([]byte, len(test.wantPayload))
+			_, err = io.ReadFull(reader, payload)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantPayload, string(payload))
+
+			if test.options.IdleTimeout > 0 || test.options.Timeout > 0 {
+				// the proxy closes the connection
+				_, err = reader.ReadByte()
+				assert.Equal(t, io.EOF, err)
+			}
+		})
+	}
+}
+
+func TestUpgradeForwarderTLS(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		conn, brw, err := rw.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+		// the first frame is sent along with the upgrade response
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\nwelcome")
+		brw.Flush()
+		io.Copy(conn, brw)
+	}))
+	defer backend.Close()
+	target, err := url.Parse(backend.URL)
+	require.NoError(t, err)
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNotImplemented)
+	})
+	responseModifier := func(res *http.Response) error {
+		res.Header.Set("X-Frontend", "modified")
+		return nil
+	}
+	forwarder := NewUpgradeForwarder(next, backend.Client().Transport, nil, responseModifier, nil, UpgradeOptions{}, metrics.NewVoidRegistry(), "backend1")
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		forwarder.ServeHTTP(rw, req)
+	}))
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, err = conn.Write([]byte("GET /api/v1/namespaces/john/pods?watch=true HTTP/1.1\r\n" +
+		"Host: oso-proxy\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n"))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	assert.Equal(t, "modified", res.Header.Get("X-Frontend"))
+
+	_, err = conn.Write([]byte(" back"))
+	require.NoError(t, err)
+	payload := make([]byte, len("welcome back"))
+	_, err = io.ReadFull(reader, payload)
+	require.NoError(t, err)
+	assert.Equal(t, "welcome back", string(payload))
+}
+
+func TestUpgradeForwarderRefused(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusForbidden)
+		rw.Write([]byte("forbidden"))
+	}))
+	defer backend.Close()
+
+	proxy := newUpgradeTestProxy(t, backend.URL, UpgradeOptions{})
+	defer proxy.Close()
+
+	req, err := http.NewRequest(http.MethodPost, proxy.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "SPDY/3.1")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+func TestUpgradeForwarderNotUpgrade(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	})
+	forwarder := NewUpgradeForwarder(next, http.DefaultTransport, nil, nil, nil, UpgradeOptions{}, metrics.NewVoidRegistry(), "backend1")
+
+	rw := httptest.NewRecorder()
+	forwarder.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+	assert.Equal(t, http.StatusTeapot, rw.Code)
+}
+
+func TestGetUpgradeProtocol(t *testing.T) {
+	tests := map[string]string{
+		"SPDY/3.1":  "spdy",
+		"websocket": "websocket",
+		"WebSocket": "websocket",
+		"h2c":       "h2c",
+		"foo/1.0":   "other",
+	}
+	for upgrade, want := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+		req.Header.Set("Upgrade", upgrade)
+		assert.Equal(t, want, getUpgradeProtocol(req), "upgrade=%s", upgrade)
+	}
+}
+
+// newUpgradeTestProxy creates a proxy which, like the load-balancer, sends all requests to backendURL.
+func newUpgradeTestProxy(t *testing.T, backendURL string, options UpgradeOptions) *httptest.Server {
+	target, err := url.Parse(backendURL)
+	require.NoError(t, err)
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNotImplemented)
+	})
+	forwarder := NewUpgradeForwarder(next, http.DefaultTransport, nil, nil, nil, options, metrics.NewVoidRegistry(), "backend1")
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		forwarder.ServeHTTP(rw, req)
+	}))
+}
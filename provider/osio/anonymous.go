@@ -0,0 +1,44 @@
+package osio
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AnonymousRoute lets requests reach a cluster without a token, e.g. for the public
+// version, health and discovery endpoints of the clusters.
+type AnonymousRoute struct {
+	Name    string   `description:"Anonymous route name" export:"true"`
+	Methods []string `description:"Allowed request methods, GET and HEAD by default" export:"true"`
+	Path    string   `description:"Request path, or path prefix when ending with '*'" export:"true"`
+	Cluster string   `description:"API URL of the target cluster, the default backend when empty" export:"true"`
+}
+
+// DefaultAnonymousMethods are the methods allowed when an anonymous route defines none.
+var DefaultAnonymousMethods = []string{"GET", "HEAD"}
+
+// Validate checks that the anonymous route can be used by the middleware.
+func (r *AnonymousRoute) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("anonymous route with path %q has no name", r.Path)
+	}
+	if !strings.HasPrefix(r.Path, "/") {
+		return fmt.Errorf("anonymous route %q: path %q must start with '/'", r.Name, r.Path)
+	}
+	if strings.Contains(strings.TrimSuffix(r.Path, "*"), "*") {
+		return fmt.Errorf("anonymous route %q: '*' is only allowed at the end of path %q", r.Name, r.Path)
+	}
+	for _, method := range r.Methods {
+		if method == "" || method != strings.ToUpper(method) {
+			return fmt.Errorf("anonymous route %q: invalid method %q", r.Name, method)
+		}
+	}
+	if r.Cluster != "" {
+		u, err := url.Parse(r.Cluster)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("anonymous route %q: invalid cluster URL %q", r.Name, r.Cluster)
+		}
+	}
+	return nil
+}
@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 )
 
 type Client interface {
 	GetToken(tokenURL string, tokenReq *TokenRequest) (*TokenResponse, error)
 	GetClusters(clustersURL string, tokenResp *TokenResponse) (*clusterResponse, error)
+	GetClusterToken(tokenURL, clusterURL string, tokenResp *TokenResponse) (string, error)
 }
 
 func NewClient() Client {
@@ -98,3 +100,27 @@ func (client *authClient) GetClusters(clustersURL string, tokenResp *TokenRespon
 	}
 	return clusters, nil
 }
+
+// GetClusterToken returns the (encrypted) token of the cluster from the auth token API.
+func (client *authClient) GetClusterToken(tokenURL, clusterURL string, tokenResp *TokenResponse) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, tokenURL+"?for="+url.QueryEscape(clusterURL), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(authorization, fmt.Sprintf("%s %s", tokenResp.TokenType, tokenResp.AccessToken))
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Failed to get cluster Token, code:%d, error:%s", resp.StatusCode, resp.Status)
+	}
+
+	var clusterTokenResp TokenResponse
+	err = json.NewDecoder(resp.Body).Decode(&clusterTokenResp)
+	if err != nil {
+		return "", err
+	}
+	return clusterTokenResp.AccessToken, nil
+}
@@ -0,0 +1,123 @@
+package osio
+
+import (
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// ClusterTokenStore holds the API URLs of the known clusters and their decrypted tokens.
+// It is filled by the provider on each clusters poll and read by the osio middleware.
+type ClusterTokenStore struct {
+	mux      sync.RWMutex
+	tokens   map[string]clusterToken
+	clusters []string
+	now      func() time.Time
+}
+
+type clusterToken struct {
+	token string
+	// expiry is zero for tokens which do not expire
+	expiry time.Time
+}
+
+// NewClusterTokenStore creates an empty ClusterTokenStore.
+func NewClusterTokenStore() *ClusterTokenStore {
+	return &ClusterTokenStore{tokens: make(map[string]clusterToken), now: time.Now}
+}
+
+// Get returns the token of the cluster, if it is known and not expired.
+func (s *ClusterTokenStore) Get(clusterURL string) (string, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	t, ok := s.tokens[normalizeURL(clusterURL)]
+	if !ok || s.expired(t, 0) {
+		return "", false
+	}
+	return t.token, true
+}
+
+// Set stores the token of the cluster. It expires with the token 'exp' claim, if any.
+func (s *ClusterTokenStore) Set(clusterURL, token string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.tokens[normalizeURL(clusterURL)] = clusterToken{token: token, expiry: tokenExpiry(token)}
+}
+
+// Invalidate removes the token of the cluster, e.g. after the cluster rejected it.
+func (s *ClusterTokenStore) Invalidate(clusterURL string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.tokens, normalizeURL(clusterURL))
+}
+
+// Retain records the listed clusters as the known ones and removes the tokens of the other clusters.
+func (s *ClusterTokenStore) Retain(clusterURLs []string) {
+	listed := make(map[string]bool)
+	var clusters []string
+	for _, clusterURL := range clusterURLs {
+		listed[normalizeURL(clusterURL)] = true
+		clusters = append(clusters, normalizeURL(clusterURL))
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.clusters = clusters
+
+	for clusterURL := range s.tokens {
+		if !listed[clusterURL] {
+			delete(s.tokens, clusterURL)
+		}
+	}
+}
+
+// Clusters returns the API URLs of the clusters listed by the last poll.
+func (s *ClusterTokenStore) Clusters() []string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return append([]string(nil), s.clusters...)
+}
+
+// IsKnownCluster reports whether the cluster was listed by the last poll.
+func (s *ClusterTokenStore) IsKnownCluster(clusterURL string) bool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, cluster := range s.clusters {
+		if cluster == normalizeURL(clusterURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// needsRefresh reports whether the token of the cluster is missing or expires within the given duration.
+func (s *ClusterTokenStore) needsRefresh(clusterURL string, within time.Duration) bool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	t, ok := s.tokens[normalizeURL(clusterURL)]
+	return !ok || s.expired(t, within)
+}
+
+func (s *ClusterTokenStore) expired(t clusterToken, within time.Duration) bool {
+	return !t.expiry.IsZero() && !s.now().Add(within).Before(t.expiry)
+}
+
+// tokenExpiry returns the 'exp' claim of a JWT token, or zero for other tokens.
+func tokenExpiry(token string) time.Time {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err != nil {
+		return time.Time{}
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		return time.Unix(int64(exp), 0)
+	}
+	return time.Time{}
+}
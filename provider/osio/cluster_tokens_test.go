@@ -0,0 +1,104 @@
+package osio
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+)
+
+func encryptToken(t *testing.T, token, passphrase string) string {
+	buf := new(bytes.Buffer)
+	w, err := openpgp.SymmetricallyEncrypt(buf, []byte(passphrase), nil, nil)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(token))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+type clusterTokenClient struct {
+	testClient
+	tokens map[string]string
+	calls  map[string]int
+}
+
+func (c *clusterTokenClient) GetClusterToken(tokenURL, clusterURL string, tokenResp *TokenResponse) (string, error) {
+	c.calls[clusterURL]++
+	return c.tokens[clusterURL], nil
+}
+
+func TestClusterTokenStore(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	store := NewClusterTokenStore()
+	store.now = func() time.Time { return now }
+
+	_, ok := store.Get("https://api.cluster1.com")
+	assert.False(t, ok)
+
+	store.Set("https://api.cluster1.com/", "token1")
+	token, ok := store.Get("https://api.cluster1.com")
+	assert.True(t, ok)
+	assert.Equal(t, "token1", token)
+	assert.False(t, store.needsRefresh("https://api.cluster1.com", time.Hour))
+
+	jwtToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"exp": now.Add(time.Minute).Unix()}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	store.Set("https://api.cluster2.com", jwtToken)
+	_, ok = store.Get("https://api.cluster2.com")
+	assert.True(t, ok)
+	assert.False(t, store.needsRefresh("https://api.cluster2.com", 30*time.Second))
+	assert.True(t, store.needsRefresh("https://api.cluster2.com", 2*time.Minute))
+	now = now.Add(time.Minute)
+	_, ok = store.Get("https://api.cluster2.com")
+	assert.False(t, ok, "expired token")
+
+	store.Invalidate("https://api.cluster1.com")
+	_, ok = store.Get("https://api.cluster1.com")
+	assert.False(t, ok)
+
+	store.Set("https://api.cluster1.com", "token1")
+	store.Set("https://api.cluster3.com", "token3")
+	store.Retain([]string{"https://api.cluster3.com/"})
+	_, ok = store.Get("https://api.cluster1.com")
+	assert.False(t, ok)
+	_, ok = store.Get("https://api.cluster3.com")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"https://api.cluster3.com"}, store.Clusters())
+	assert.True(t, store.IsKnownCluster("https://api.cluster3.com/"))
+	assert.False(t, store.IsKnownCluster("https://api.cluster1.com"))
+}
+
+func TestLoadClusterTokens(t *testing.T) {
+	client := &clusterTokenClient{
+		tokens: map[string]string{
+			"https://api.cluster1.com": encryptToken(t, "cluster1_token", "foo"),
+			"https://api.cluster2.com": encryptToken(t, "cluster2_token", "foo"),
+		},
+		calls: make(map[string]int),
+	}
+	store := NewClusterTokenStore()
+	provider := &Provider{RefreshSeconds: 60, client: client, tokenResp: &TokenResponse{"1111", "bearer"}}
+	provider.Keyring(NewPassphraseKeyring("foo"))
+	provider.ClusterTokens(store)
+
+	clusters := &clusterResponse{Clusters: []clusterData{{APIURL: "https://api.cluster1.com"}, {APIURL: "https://api.cluster2.com"}}}
+	provider.loadClusterTokens(clusters)
+	provider.loadClusterTokens(clusters)
+
+	assert.Equal(t, map[string]int{"https://api.cluster1.com": 1, "https://api.cluster2.com": 1}, client.calls, "tokens are fetched once per cluster")
+	token, ok := store.Get("https://api.cluster2.com")
+	assert.True(t, ok)
+	assert.Equal(t, "cluster2_token", token)
+
+	provider.loadClusterTokens(&clusterResponse{Clusters: []clusterData{{APIURL: "https://api.cluster1.com"}}})
+	_, ok = store.Get("https://api.cluster2.com")
+	assert.False(t, ok, "unlisted cluster")
+	_, ok = store.Get("https://api.cluster1.com")
+	assert.True(t, ok)
+}
@@ -0,0 +1,23 @@
+package osio
+
+import "fmt"
+
+// DefaultDebugHeader is the request header enabling the debug response headers.
+const DefaultDebugHeader = "X-OSIO-Debug"
+
+// Debug lets privileged clients ask for response headers describing how the osio middleware routed their request.
+type Debug struct {
+	Header string `description:"Request header holding the debug secret" export:"true"`
+	Secret string `description:"Secret enabling the debug response headers"`
+}
+
+// Validate checks the debug configuration and sets the defaults.
+func (d *Debug) Validate() error {
+	if d.Secret == "" {
+		return fmt.Errorf("debug secret is missing")
+	}
+	if d.Header == "" {
+		d.Header = DefaultDebugHeader
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package osio
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Issuer is an identity realm trusted by the osio middleware, selected by the 'iss' claim of the tokens.
+// Each issuer has its own auth service (key set, token endpoint), tenant service and service account.
+type Issuer struct {
+	Issuer               string `description:"Value of the 'iss' claim of the tokens of this issuer" export:"true"`
+	AuthURL              string `description:"Auth service URL, serving the token keys and the token endpoint" export:"true"`
+	TenantURL            string `description:"Tenant service URL" export:"true"`
+	ServiceAccountID     string `description:"Service account ID of the proxy in this auth service" export:"true"`
+	ServiceAccountSecret string `description:"Service account secret of the proxy in this auth service"`
+	// TokenTypes maps the 'service_accountname' claim of service tokens to the token type, rh-che to che by default.
+	// Being a map it is set from the file only.
+	TokenTypes map[string]string `export:"true"`
+}
+
+// Validate checks that the issuer can be used by the middleware.
+func (i *Issuer) Validate() error {
+	if i.Issuer == "" {
+		return fmt.Errorf("issuer with auth URL %q has no 'iss' value", i.AuthURL)
+	}
+	for name, value := range map[string]string{"auth URL": i.AuthURL, "tenant URL": i.TenantURL} {
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("issuer %q: invalid %s %q", i.Issuer, name, value)
+		}
+	}
+	if i.ServiceAccountID == "" || i.ServiceAccountSecret == "" {
+		return fmt.Errorf("issuer %q: missing service account ID or secret", i.Issuer)
+	}
+	for accountName, tokenType := range i.TokenTypes {
+		if tokenType == "" {
+			return fmt.Errorf("issuer %q: no token type for service account %q", i.Issuer, accountName)
+		}
+	}
+	return nil
+}
+
+// ValidateIssuers checks the issuers and that each 'iss' value is configured once.
+func ValidateIssuers(issuers []*Issuer) error {
+	seen := make(map[string]bool)
+	for _, issuer := range issuers {
+		if err := issuer.Validate(); err != nil {
+			return err
+		}
+		if seen[issuer.Issuer] {
+			return fmt.Errorf("issuer %q is configured more than once", issuer.Issuer)
+		}
+		seen[issuer.Issuer] = true
+	}
+	return nil
+}
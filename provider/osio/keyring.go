@@ -0,0 +1,306 @@
+package osio
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/safe"
+	gokitmetrics "github.com/go-kit/kit/metrics"
+	"golang.org/x/crypto/openpgp"
+	"gopkg.in/fsnotify.v1"
+)
+
+const (
+	// DefaultKeyGeneration is the generation of the AUTH_TOKEN_KEY passphrase.
+	DefaultKeyGeneration = "default"
+	noKeyGeneration      = "none"
+)
+
+// KeyGeneration is a key able to decrypt the cluster tokens: either a symmetric passphrase,
+// or an OpenPGP private key, optionally protected by a passphrase.
+type KeyGeneration struct {
+	Generation     string `toml:"generation"`
+	Passphrase     string `toml:"passphrase"`
+	PrivateKey     string `toml:"privateKey"`
+	PrivateKeyFile string `toml:"privateKeyFile"`
+}
+
+type keyringFile struct {
+	Keys []*KeyGeneration `toml:"keys"`
+}
+
+type keyringKey struct {
+	generation string
+	passphrase []byte
+	entities   openpgp.EntityList
+}
+
+// Keyring holds the active and previous keys used to decrypt the cluster tokens,
+// so that the key shared with the auth service can be rotated without a synchronized restart.
+// The keys are attempted in order: the active key first, then the previous ones.
+type Keyring struct {
+	mux  sync.RWMutex
+	keys []*keyringKey
+
+	// DecryptionsCounter counts the decrypted tokens by key generation.
+	DecryptionsCounter gokitmetrics.Counter
+}
+
+// NewKeyring creates a Keyring from the given keys, in attempt order.
+func NewKeyring(generations []*KeyGeneration) (*Keyring, error) {
+	k := &Keyring{}
+	if err := k.setKeys(generations); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// NewPassphraseKeyring creates a Keyring with the single passphrase, e.g. AUTH_TOKEN_KEY.
+func NewPassphraseKeyring(passphrase string) *Keyring {
+	return &Keyring{keys: []*keyringKey{{generation: DefaultKeyGeneration, passphrase: []byte(passphrase)}}}
+}
+
+// LoadKeyring creates a Keyring from a TOML file listing the keys as [[keys]] tables.
+func LoadKeyring(filename string) (*Keyring, error) {
+	k := &Keyring{}
+	if err := k.Reload(filename); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// NewPreConfiguredKeyring creates the Keyring from the AUTH_TOKEN_KEYRING file if set, from the AUTH_TOKEN_KEY passphrase otherwise.
+func NewPreConfiguredKeyring() (*Keyring, error) {
+	if filename := os.Getenv("AUTH_TOKEN_KEYRING"); filename != "" {
+		return LoadKeyring(filename)
+	}
+	authTokenKey := os.Getenv("AUTH_TOKEN_KEY")
+	if authTokenKey == "" {
+		return nil, errors.New("missing AUTH_TOKEN_KEY or AUTH_TOKEN_KEYRING")
+	}
+	return NewPassphraseKeyring(authTokenKey), nil
+}
+
+// Reload replaces the keys by the ones of the file. The keys are left unchanged if the file is invalid.
+func (k *Keyring) Reload(filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var file keyringFile
+	if _, err := toml.Decode(string(content), &file); err != nil {
+		return fmt.Errorf("invalid keyring %s: %v", filename, err)
+	}
+	for _, generation := range file.Keys {
+		if generation.PrivateKeyFile != "" && !filepath.IsAbs(generation.PrivateKeyFile) {
+			generation.PrivateKeyFile = filepath.Join(filepath.Dir(filename), generation.PrivateKeyFile)
+		}
+	}
+	if err := k.setKeys(file.Keys); err != nil {
+		return fmt.Errorf("invalid keyring %s: %v", filename, err)
+	}
+	return nil
+}
+
+// Watch reloads the keys when the file changes. The directory of the file is watched,
+// as Kubernetes updates mounted secrets by swapping a symlink.
+func (k *Keyring) Watch(pool *safe.Pool, filename string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating keyring watcher: %s", err)
+	}
+	if err = watcher.Add(filepath.Dir(filename)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("error adding keyring watcher: %s", err)
+	}
+
+	pool.Go(func(stop chan bool) {
+		defer watcher.Close()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-watcher.Events:
+				if _, err := os.Stat(filename); err != nil {
+					log.Debugf("Unable to watch keyring %s: %v", filename, err)
+					continue
+				}
+				if err := k.Reload(filename); err != nil {
+					log.Errorf("Error reloading keyring, keeping the previous keys: %v", err)
+					continue
+				}
+				log.Infof("Reloaded keyring %s with generations %s", filename, strings.Join(k.Generations(), ", "))
+			case err := <-watcher.Errors:
+				log.Errorf("Keyring watcher event error: %s", err)
+			}
+		}
+	})
+	return nil
+}
+
+// Generations returns the key generations, in attempt order.
+func (k *Keyring) Generations() []string {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+
+	var generations []string
+	for _, key := range k.keys {
+		generations = append(generations, key.generation)
+	}
+	return generations
+}
+
+// Decrypt decrypts a base64 encoded token, encrypted either symmetrically with one of the
+// passphrases or to one of the private keys. It returns the generation of the key used.
+func (k *Keyring) Decrypt(base64Body string) (string, string, error) {
+	k.mux.RLock()
+	keys := k.keys
+	k.mux.RUnlock()
+
+	token, generation, err := decrypt(keys, base64Body)
+	if k.DecryptionsCounter != nil {
+		if err != nil {
+			k.DecryptionsCounter.With("generation", noKeyGeneration).Add(1)
+		} else {
+			k.DecryptionsCounter.With("generation", generation).Add(1)
+		}
+	}
+	return token, generation, err
+}
+
+func decrypt(keys []*keyringKey, base64Body string) (string, string, error) {
+	decodedEnc, err := base64.StdEncoding.DecodeString(base64Body)
+	if err != nil {
+		return "", "", err
+	}
+
+	var entities openpgp.EntityList
+	var passphrases []*keyringKey
+	for _, key := range keys {
+		if len(key.entities) > 0 {
+			entities = append(entities, key.entities...)
+		} else {
+			passphrases = append(passphrases, key)
+		}
+	}
+
+	// the prompt is called again, with the next passphrase, as long as the previous one is wrong
+	var attempted *keyringKey
+	next := 0
+	md, err := openpgp.ReadMessage(bytes.NewBuffer(decodedEnc), entities, func(_ []openpgp.Key, symmetric bool) ([]byte, error) {
+		if !symmetric || next >= len(passphrases) {
+			return nil, errors.New("unable to decrypt token with the keyring")
+		}
+		attempted = passphrases[next]
+		next++
+		return attempted.passphrase, nil
+	}, nil)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return "", "", err
+	}
+
+	generation := ""
+	if md.DecryptedWith.Entity != nil {
+		generation = entityGeneration(keys, md.DecryptedWith.Entity)
+	} else if attempted != nil {
+		generation = attempted.generation
+	}
+	return string(body), generation, nil
+}
+
+func entityGeneration(keys []*keyringKey, entity *openpgp.Entity) string {
+	for _, key := range keys {
+		for _, e := range key.entities {
+			if e == entity {
+				return key.generation
+			}
+		}
+	}
+	return ""
+}
+
+func (k *Keyring) setKeys(generations []*KeyGeneration) error {
+	if len(generations) == 0 {
+		return errors.New("no keys")
+	}
+	var keys []*keyringKey
+	for i, generation := range generations {
+		key, err := newKeyringKey(generation)
+		if err != nil {
+			return fmt.Errorf("key %d: %v", i, err)
+		}
+		keys = append(keys, key)
+	}
+
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	k.keys = keys
+	return nil
+}
+
+func newKeyringKey(generation *KeyGeneration) (*keyringKey, error) {
+	if generation.Generation == "" {
+		return nil, errors.New("missing generation")
+	}
+	key := &keyringKey{generation: generation.Generation, passphrase: []byte(generation.Passphrase)}
+
+	armored := generation.PrivateKey
+	if generation.PrivateKeyFile != "" {
+		content, err := ioutil.ReadFile(generation.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		armored = string(content)
+	}
+	if armored == "" {
+		if generation.Passphrase == "" {
+			return nil, fmt.Errorf("generation %s has neither a passphrase nor a private key", generation.Generation)
+		}
+		return key, nil
+	}
+
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armored))
+	if err != nil {
+		return nil, fmt.Errorf("generation %s: %v", generation.Generation, err)
+	}
+	for _, entity := range entities {
+		if err := decryptEntity(entity, key.passphrase); err != nil {
+			return nil, fmt.Errorf("generation %s: %v", generation.Generation, err)
+		}
+	}
+	key.entities = entities
+	return key, nil
+}
+
+// decryptEntity decrypts the private keys of the entity, so that no passphrase is needed to decrypt the tokens.
+func decryptEntity(entity *openpgp.Entity, passphrase []byte) error {
+	if entity.PrivateKey == nil {
+		return errors.New("not a private key")
+	}
+	if entity.PrivateKey.Encrypted {
+		if err := entity.PrivateKey.Decrypt(passphrase); err != nil {
+			return err
+		}
+	}
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			if err := subkey.PrivateKey.Decrypt(passphrase); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
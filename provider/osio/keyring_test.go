@@ -0,0 +1,162 @@
+package osio
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func encryptTokenTo(t *testing.T, token string, entity *openpgp.Entity) string {
+	// the vendored openpgp defaults to RIPEMD160, which is not compiled in
+	for _, identity := range entity.Identities {
+		identity.SelfSignature.PreferredHash = []uint8{8} // SHA256
+	}
+	buf := new(bytes.Buffer)
+	w, err := openpgp.Encrypt(buf, []*openpgp.Entity{entity}, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(token))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func armoredPrivateKey(t *testing.T, entity *openpgp.Entity) string {
+	buf := new(bytes.Buffer)
+	w, err := armor.Encode(buf, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+func TestKeyringDecrypt(t *testing.T) {
+	entity, err := openpgp.NewEntity("oso-proxy", "", "oso-proxy@example.com", nil)
+	require.NoError(t, err)
+
+	keyring, err := NewKeyring([]*KeyGeneration{
+		{Generation: "2018-06", Passphrase: "new"},
+		{Generation: "2018-03", PrivateKey: armoredPrivateKey(t, entity)},
+		{Generation: "2018-01", Passphrase: "old"},
+	})
+	require.NoError(t, err)
+	counter := &testhelpers.CollectingCounter{}
+	keyring.DecryptionsCounter = counter
+	assert.Equal(t, []string{"2018-06", "2018-03", "2018-01"}, keyring.Generations())
+
+	tests := []struct {
+		desc               string
+		encrypted          string
+		expectedGeneration string
+		expectedErr        bool
+	}{
+		{desc: "active passphrase", encrypted: encryptToken(t, "token1", "new"), expectedGeneration: "2018-06"},
+		{desc: "previous passphrase", encrypted: encryptToken(t, "token1", "old"), expectedGeneration: "2018-01"},
+		{desc: "private key", encrypted: encryptTokenTo(t, "token1", entity), expectedGeneration: "2018-03"},
+		{desc: "unknown passphrase", encrypted: encryptToken(t, "token1", "other"), expectedErr: true},
+		{desc: "not base64", encrypted: "%%%", expectedErr: true},
+	}
+
+	for _, test := range tests {
+		token, generation, err := keyring.Decrypt(test.encrypted)
+		if test.expectedErr {
+			assert.Error(t, err, test.desc)
+			continue
+		}
+		require.NoError(t, err, test.desc)
+		assert.Equal(t, "token1", token, test.desc)
+		assert.Equal(t, test.expectedGeneration, generation, test.desc)
+		assert.Equal(t, []string{"generation", test.expectedGeneration}, counter.LastLabelValues, test.desc)
+	}
+	assert.Equal(t, float64(len(tests)), counter.CounterValue)
+	assert.Equal(t, []string{"generation", "none"}, counter.LastLabelValues)
+}
+
+func TestPassphraseKeyring(t *testing.T) {
+	token, generation, err := NewPassphraseKeyring("foo").Decrypt(encryptToken(t, "cluster1_token", "foo"))
+	require.NoError(t, err)
+	assert.Equal(t, "cluster1_token", token)
+	assert.Equal(t, DefaultKeyGeneration, generation)
+
+	_, _, err = NewPassphraseKeyring("bar").Decrypt(encryptToken(t, "cluster1_token", "foo"))
+	assert.Error(t, err)
+}
+
+func TestKeyringInvalid(t *testing.T) {
+	tests := map[string][]*KeyGeneration{
+		"no keys":       nil,
+		"no generation": {{Passphrase: "foo"}},
+		"no key":        {{Generation: "2018-06"}},
+		"invalid key":   {{Generation: "2018-06", PrivateKey: "not a key"}},
+		"missing file":  {{Generation: "2018-06", PrivateKeyFile: "/nonexistent/key.asc"}},
+	}
+	for desc, generations := range tests {
+		_, err := NewKeyring(generations)
+		assert.Error(t, err, desc)
+	}
+}
+
+func TestKeyringReload(t *testing.T) {
+	entity, err := openpgp.NewEntity("oso-proxy", "", "oso-proxy@example.com", nil)
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "keyring")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "key.asc"), []byte(armoredPrivateKey(t, entity)), 0600))
+	filename := filepath.Join(dir, "keyring.toml")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`
+[[keys]]
+  generation = "2018-03"
+  privateKeyFile = "key.asc"
+[[keys]]
+  generation = "2018-01"
+  passphrase = "old"
+`), 0600))
+
+	keyring, err := LoadKeyring(filename)
+	require.NoError(t, err)
+	_, generation, err := keyring.Decrypt(encryptTokenTo(t, "token1", entity))
+	require.NoError(t, err)
+	assert.Equal(t, "2018-03", generation)
+
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+	require.NoError(t, keyring.Watch(pool, filename))
+
+	// invalid content is ignored
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`[[keys]]`), 0600))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, []string{"2018-03", "2018-01"}, keyring.Generations())
+
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`
+[[keys]]
+  generation = "2018-06"
+  passphrase = "new"
+[[keys]]
+  generation = "2018-03"
+  privateKeyFile = "key.asc"
+`), 0600))
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && keyring.Generations()[0] != "2018-06" {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, []string{"2018-06", "2018-03"}, keyring.Generations())
+
+	_, generation, err = keyring.Decrypt(encryptToken(t, "token1", "new"))
+	require.NoError(t, err)
+	assert.Equal(t, "2018-06", generation)
+	_, _, err = keyring.Decrypt(encryptToken(t, "token1", "old"))
+	assert.Error(t, err)
+}
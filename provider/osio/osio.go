@@ -24,12 +24,20 @@ const (
 type Provider struct {
 	provider.BaseProvider `mapstructure:",squash" export:"true"`
 
-	RefreshSeconds int    `description:"Polling interval (in seconds)" export:"false"`
-	TokenURL       string `description:"Auth Token URL" export:"true"`
-	ClustersURL    string `description:"Clusters details URL" export:"true"`
+	RefreshSeconds int               `description:"Polling interval (in seconds)" export:"false"`
+	TokenURL       string            `description:"Auth Token URL" export:"true"`
+	ClustersURL    string            `description:"Clusters details URL" export:"true"`
+	Routes         []*Route          `description:"Request routes, by path prefix, to per-cluster endpoints" export:"true"`
+	Passthrough    *Passthrough      `description:"Forward native OpenShift tokens to their cluster" export:"true"`
+	Issuers        []*Issuer         `description:"Additional token issuers trusted by the osio middleware" export:"true"`
+	Anonymous      []*AnonymousRoute `description:"Requests reaching the clusters without a token, by method and path" export:"true"`
+	Warmup         *Warmup           `description:"Pre-resolve the cache of the osio middleware from the recently active identities" export:"true"`
+	Debug          *Debug            `description:"Response headers describing the routing of the requests holding the debug secret" export:"true"`
 
 	serviceAccountID     string
 	serviceAccountSecret string
+	keyring              *Keyring
+	clusterTokens        *ClusterTokenStore
 
 	client            Client
 	tokenResp         *TokenResponse
@@ -44,10 +52,48 @@ func (p *Provider) ServiceAccountSecret(saSecret string) {
 	p.serviceAccountSecret = saSecret
 }
 
+// Keyring sets the keys used to decrypt the cluster tokens.
+func (p *Provider) Keyring(keyring *Keyring) {
+	p.keyring = keyring
+}
+
+// ClusterTokens sets the store filled with the cluster tokens on each clusters poll.
+func (p *Provider) ClusterTokens(store *ClusterTokenStore) {
+	p.clusterTokens = store
+}
+
 // Provide allows the osio provider to provide configurations to traefik
 // using the given configuration channel.
 func (p *Provider) Provide(configChan chan<- types.ConfigMessage, pool *safe.Pool, constraints types.Constraints) error {
 	log.Debugf("Configuring %s provider", providerName)
+	for _, route := range p.routes() {
+		if err := route.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Passthrough != nil {
+		if err := p.Passthrough.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := ValidateIssuers(p.Issuers); err != nil {
+		return err
+	}
+	for _, route := range p.Anonymous {
+		if err := route.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Warmup != nil {
+		if err := p.Warmup.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Debug != nil {
+		if err := p.Debug.Validate(); err != nil {
+			return err
+		}
+	}
 	p.init(configChan)
 	p.schedule(configChan, pool)
 	return nil
@@ -143,9 +189,40 @@ func (p *Provider) loadConfig() (*types.Configuration, error) {
 	if err != nil {
 		return nil, err
 	}
+	p.loadClusterTokens(clusterResponse)
 	return p.loadRules(clusterResponse), nil
 }
 
+// loadClusterTokens records the listed clusters, fetches and decrypts the tokens of the ones
+// which are not known yet or expire before the next poll, and forgets the unlisted ones.
+func (p *Provider) loadClusterTokens(clusterResp *clusterResponse) {
+	if p.clusterTokens == nil {
+		return
+	}
+	var clusterURLs []string
+	for _, cluster := range clusterResp.Clusters {
+		if cluster.APIURL == "" {
+			continue
+		}
+		clusterURLs = append(clusterURLs, cluster.APIURL)
+		if p.keyring == nil || !p.clusterTokens.needsRefresh(cluster.APIURL, time.Duration(p.RefreshSeconds)*time.Second) {
+			continue
+		}
+		encryptedToken, err := p.client.GetClusterToken(p.TokenURL, cluster.APIURL, p.tokenResp)
+		if err != nil {
+			log.Errorf("Failed to get token of cluster %s: %v", cluster.APIURL, err)
+			continue
+		}
+		token, _, err := p.keyring.Decrypt(encryptedToken)
+		if err != nil {
+			log.Errorf("Failed to decrypt token of cluster %s: %v", cluster.APIURL, err)
+			continue
+		}
+		p.clusterTokens.Set(cluster.APIURL, token)
+	}
+	p.clusterTokens.Retain(clusterURLs)
+}
+
 func (p *Provider) loadRules(clusterResp *clusterResponse) *types.Configuration {
 	config := &types.Configuration{
 		Frontends: make(map[string]*types.Frontend),
@@ -161,13 +238,17 @@ func (p *Provider) loadRules(clusterResp *clusterResponse) *types.Configuration
 			defaultBackendExist = true
 		}
 		configInd := fmt.Sprintf("%d", ind+1)
-		if cluster.APIURL != "" {
-			config.Frontends["api"+configInd] = createFrontend(cluster.APIURL, "api"+configInd)
-			config.Backends["api"+configInd] = createBackend(cluster.APIURL)
-		}
-		if cluster.MetricsURL != "" {
-			config.Frontends["metrics"+configInd] = createFrontend(cluster.MetricsURL, "metrics"+configInd)
-			config.Backends["metrics"+configInd] = createBackend(cluster.MetricsURL)
+		clusterFields := make(map[string]bool)
+		for _, route := range p.routes() {
+			if route.Redirect || route.ClusterField == "" || clusterFields[route.ClusterField] {
+				continue
+			}
+			clusterFields[route.ClusterField] = true
+			clusterURL := clusterURLFields[route.ClusterField](cluster)
+			if clusterURL != "" {
+				config.Frontends[route.Name+configInd] = createFrontend(clusterURL, route.Name+configInd)
+				config.Backends[route.Name+configInd] = createBackend(clusterURL)
+			}
 		}
 	}
 	if !defaultBackendExist {
@@ -183,6 +264,13 @@ func (p *Provider) loadRules(clusterResp *clusterResponse) *types.Configuration
 	return config
 }
 
+func (p *Provider) routes() []*Route {
+	if len(p.Routes) == 0 {
+		return DefaultRoutes()
+	}
+	return p.Routes
+}
+
 func createFrontend(clusterURL string, backend string) *types.Frontend {
 	clusterURL = normalizeURL(clusterURL)
 	routes := make(map[string]types.Route)
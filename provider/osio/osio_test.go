@@ -33,6 +33,10 @@ func (fc *testClient) GetClusters(clusterAPIURL string, tokenResp *TokenResponse
 	return &clusterResponse{}, nil
 }
 
+func (fc *testClient) GetClusterToken(tokenURL, clusterURL string, tokenResp *TokenResponse) (string, error) {
+	return "", nil
+}
+
 func TestScheduleConfigPull(t *testing.T) {
 	fp := &testProvider{}
 	fp.RefreshSeconds = 100
@@ -105,6 +109,37 @@ func TestLoadRulesDefaultChange(t *testing.T) {
 	}
 }
 
+func TestLoadRulesWithRoutes(t *testing.T) {
+	provider := &Provider{Routes: []*Route{
+		{Name: "api", PathPrefix: "/api/", TargetField: "cluster-url", ClusterField: "api-url"},
+		{Name: "api", PathPrefix: "/api/api", TargetField: "cluster-url", ClusterField: "api-url", StripPrefix: "/api"},
+		{Name: "apps", PathPrefix: "/apps", TargetField: "cluster-app-domain", ClusterField: "app-dns", StripPrefix: "/apps"},
+		{Name: "console", PathPrefix: "/console", TargetField: "cluster-console-url", Redirect: true},
+	}}
+	clusters := []clusterData{
+		{
+			APIURL:     "https://api.starter-us-east-2.openshift.com",
+			AppDNS:     "8a09.starter-us-east-2.openshiftapps.com",
+			ConsoleURL: "https://console.starter-us-east-2.openshift.com/console",
+		},
+	}
+	config := provider.loadRules(&clusterResponse{Clusters: clusters})
+	checkConfig(t, config, 3)
+
+	apps := config.Frontends["apps1"]
+	require.NotNil(t, apps)
+	assert.Equal(t, "Headers:Target,https://8a09.starter-us-east-2.openshiftapps.com", apps.Routes["test_1"].Rule)
+	assert.Equal(t, "https://8a09.starter-us-east-2.openshiftapps.com", config.Backends["apps1"].Servers["server1"].URL)
+}
+
+func TestRouteValidate(t *testing.T) {
+	for _, route := range DefaultRoutes() {
+		assert.NoError(t, route.Validate(), "route=%+v", route)
+	}
+	invalid := &Route{Name: "console", PathPrefix: "/console", TargetField: "cluster-console-url", ClusterField: "console-url", Redirect: true}
+	assert.Error(t, invalid.Validate())
+}
+
 func TestCreateFrontend(t *testing.T) {
 	url := "https://api.starter-us-east-2.openshift.com"
 	backend := "backend1"
@@ -142,3 +177,77 @@ func checkDefaultBackendURL(t *testing.T, config *types.Configuration, expectedU
 	server1URL := defaultBackend.Servers["server1"].URL
 	assert.Equal(t, expectedURL, server1URL)
 }
+
+func TestPassthroughValidate(t *testing.T) {
+	valid := &Passthrough{Hosts: map[string]string{"api.cluster1.com": "https://api.cluster1.com"}, Discover: true, CacheSeconds: 60}
+	assert.NoError(t, valid.Validate())
+
+	invalid := map[string]*Passthrough{
+		"relative cluster URL": {Hosts: map[string]string{"api.cluster1.com": "api.cluster1.com"}},
+		"negative cache":       {CacheSeconds: -1},
+	}
+	for desc, passthrough := range invalid {
+		assert.Error(t, passthrough.Validate(), desc)
+	}
+}
+
+func TestValidateIssuers(t *testing.T) {
+	preview := &Issuer{
+		Issuer:               "https://auth.prod-preview.openshift.io",
+		AuthURL:              "https://auth.prod-preview.openshift.io",
+		TenantURL:            "https://tenant.prod-preview.openshift.io",
+		ServiceAccountID:     "oso-proxy",
+		ServiceAccountSecret: "secret",
+		TokenTypes:           map[string]string{"rh-che": "che"},
+	}
+	assert.NoError(t, ValidateIssuers([]*Issuer{preview}))
+
+	invalid := map[string][]*Issuer{
+		"duplicated issuer":  {preview, preview},
+		"missing iss":        {{AuthURL: "https://auth.openshift.io", TenantURL: "https://tenant.openshift.io", ServiceAccountID: "id", ServiceAccountSecret: "secret"}},
+		"relative auth URL":  {{Issuer: "https://auth.openshift.io", AuthURL: "auth", TenantURL: "https://tenant.openshift.io", ServiceAccountID: "id", ServiceAccountSecret: "secret"}},
+		"missing secret":     {{Issuer: "https://auth.openshift.io", AuthURL: "https://auth.openshift.io", TenantURL: "https://tenant.openshift.io", ServiceAccountID: "id"}},
+		"missing token type": {{Issuer: "https://auth.openshift.io", AuthURL: "https://auth.openshift.io", TenantURL: "https://tenant.openshift.io", ServiceAccountID: "id", ServiceAccountSecret: "secret", TokenTypes: map[string]string{"rh-che": ""}}},
+	}
+	for desc, issuers := range invalid {
+		assert.Error(t, ValidateIssuers(issuers), desc)
+	}
+}
+
+func TestAnonymousRouteValidate(t *testing.T) {
+	valid := &AnonymousRoute{Name: "discovery", Methods: []string{"GET"}, Path: "/.well-known/*", Cluster: "https://api.cluster1.com"}
+	assert.NoError(t, valid.Validate())
+
+	invalid := map[string]*AnonymousRoute{
+		"no name":          {Path: "/version"},
+		"relative path":    {Name: "version", Path: "version"},
+		"inner wildcard":   {Name: "apis", Path: "/apis/*/version"},
+		"empty method":     {Name: "version", Path: "/version", Methods: []string{""}},
+		"relative cluster": {Name: "version", Path: "/version", Cluster: "api.cluster1.com"},
+	}
+	for desc, route := range invalid {
+		assert.Error(t, route.Validate(), desc)
+	}
+}
+
+func TestWarmupValidate(t *testing.T) {
+	warmup := &Warmup{File: "/var/lib/traefik/identities.json"}
+	require.NoError(t, warmup.Validate())
+	assert.Equal(t, &Warmup{
+		File:            "/var/lib/traefik/identities.json",
+		SnapshotSeconds: DefaultWarmupSnapshotSeconds,
+		MaxAgeSeconds:   DefaultWarmupMaxAgeSeconds,
+		Concurrency:     DefaultWarmupConcurrency,
+	}, warmup)
+
+	assert.Error(t, (&Warmup{}).Validate(), "no file")
+	assert.Error(t, (&Warmup{File: "identities.json", Concurrency: -1}).Validate(), "negative concurrency")
+}
+
+func TestDebugValidate(t *testing.T) {
+	debug := &Debug{Secret: "debug_secret"}
+	require.NoError(t, debug.Validate())
+	assert.Equal(t, DefaultDebugHeader, debug.Header)
+
+	assert.Error(t, (&Debug{Header: "X-Debug"}).Validate(), "no secret")
+}
@@ -0,0 +1,33 @@
+package osio
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// DefaultPassthroughClusterHeader is the request header naming the target cluster of a native OpenShift token.
+const DefaultPassthroughClusterHeader = "X-OSO-Cluster"
+
+// Passthrough configures the forwarding of native OpenShift tokens (as opposed to OSIO tokens),
+// which are sent untouched to the cluster they belong to.
+type Passthrough struct {
+	ClusterHeader string `description:"Request header holding the API URL of the target cluster" export:"true"`
+	// Hosts maps request hosts to the API URL of their cluster; being a map it is set from the file only.
+	Hosts        map[string]string `export:"true"`
+	Discover     bool              `description:"Find the target cluster by asking each known cluster who the token belongs to" export:"true"`
+	CacheSeconds int               `description:"How long the discovered cluster of a token is cached (in seconds)" export:"true"`
+}
+
+// Validate checks the host mappings.
+func (p *Passthrough) Validate() error {
+	for host, clusterURL := range p.Hosts {
+		u, err := url.Parse(clusterURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("passthrough host %q: invalid cluster URL %q", host, clusterURL)
+		}
+	}
+	if p.CacheSeconds < 0 {
+		return fmt.Errorf("passthrough cache duration must not be negative")
+	}
+	return nil
+}
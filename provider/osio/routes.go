@@ -0,0 +1,74 @@
+package osio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Route maps requests with a path prefix to one of the per-cluster endpoints.
+// It is shared by the osio provider, which generates frontends and backends
+// from ClusterField, and the osio middleware, which routes requests to TargetField.
+type Route struct {
+	Name          string `description:"Request type name" export:"true"`
+	PathPrefix    string `description:"Request path prefix handled by this route" export:"true"`
+	TargetField   string `description:"Tenant namespace field holding the target URL (e.g. cluster-url, cluster-metrics-url)" export:"true"`
+	ClusterField  string `description:"Cluster field used to generate frontends and backends (e.g. api-url, app-dns)" export:"true"`
+	StripPrefix   string `description:"Path prefix stripped from the request before it is forwarded" export:"true"`
+	Redirect      bool   `description:"Redirect the client to the target instead of proxying the request" export:"true"`
+	RedirectQuery bool   `description:"Keep the request query string when redirecting" export:"true"`
+}
+
+// DefaultRoutes returns the routes used when none are configured.
+func DefaultRoutes() []*Route {
+	return []*Route{
+		// extra '/' (slash at end) to make sure other prefix like '/apis' should not match with this route
+		{Name: "api", PathPrefix: "/api/", TargetField: "cluster-url", ClusterField: "api-url"},
+		{Name: "api", PathPrefix: "/api/api", TargetField: "cluster-url", ClusterField: "api-url", StripPrefix: "/api"},
+		{Name: "api", PathPrefix: "/api/oapi", TargetField: "cluster-url", ClusterField: "api-url", StripPrefix: "/api"},
+		{Name: "metrics", PathPrefix: "/metrics", TargetField: "cluster-metrics-url", ClusterField: "metrics-url", StripPrefix: "/metrics"},
+		{Name: "console", PathPrefix: "/console", TargetField: "cluster-console-url", StripPrefix: "/console", Redirect: true},
+		{Name: "logs", PathPrefix: "/logs", TargetField: "cluster-logging-url", StripPrefix: "/logs", Redirect: true, RedirectQuery: true},
+	}
+}
+
+// Validate checks that the route can be used by the provider and the middleware.
+func (r *Route) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("route with path prefix %q has no name", r.PathPrefix)
+	}
+	if !strings.HasPrefix(r.PathPrefix, "/") {
+		return fmt.Errorf("route %q: path prefix %q must start with '/'", r.Name, r.PathPrefix)
+	}
+	if r.StripPrefix != "" && !strings.HasPrefix(r.PathPrefix, r.StripPrefix) {
+		return fmt.Errorf("route %q: strip prefix %q is not a prefix of %q", r.Name, r.StripPrefix, r.PathPrefix)
+	}
+	if r.TargetField == "" {
+		return fmt.Errorf("route %q has no target field", r.Name)
+	}
+	if r.ClusterField != "" {
+		if _, ok := clusterURLFields[r.ClusterField]; !ok {
+			return fmt.Errorf("route %q: unknown cluster field %q", r.Name, r.ClusterField)
+		}
+	}
+	if r.Redirect && r.ClusterField != "" {
+		return fmt.Errorf("route %q: redirect routes must not define a cluster field", r.Name)
+	}
+	return nil
+}
+
+var clusterURLFields = map[string]func(clusterData) string{
+	"api-url":     func(c clusterData) string { return c.APIURL },
+	"app-dns":     func(c clusterData) string { return HostURL(c.AppDNS) },
+	"console-url": func(c clusterData) string { return c.ConsoleURL },
+	"logging-url": func(c clusterData) string { return c.LoggingURL },
+	"metrics-url": func(c clusterData) string { return c.MetricsURL },
+}
+
+// HostURL turns a bare host name (e.g. a cluster app DNS) into an https URL.
+// Values which already have a scheme are returned unchanged.
+func HostURL(host string) string {
+	if host == "" || strings.Contains(host, "://") {
+		return host
+	}
+	return "https://" + host
+}
@@ -0,0 +1,40 @@
+package osio
+
+import "fmt"
+
+// Defaults of the cache warm-up.
+const (
+	DefaultWarmupSnapshotSeconds = 60
+	DefaultWarmupMaxAgeSeconds   = 3600
+	DefaultWarmupConcurrency     = 4
+)
+
+// Warmup configures the snapshot of the recently active identities (never their tokens), from which the
+// osio middleware pre-resolves on startup the cache entries which do not need a user token. A pre-resolved
+// entry is only used once the tenant service authorized the token of the request.
+type Warmup struct {
+	File            string `description:"File holding the snapshot of the recently active identities" export:"true"`
+	SnapshotSeconds int    `description:"Interval between two snapshots (in seconds)" export:"true"`
+	MaxAgeSeconds   int    `description:"Identities inactive for longer are neither snapshotted nor pre-resolved (in seconds)" export:"true"`
+	Concurrency     int    `description:"Maximum number of concurrent resolutions while warming up" export:"true"`
+}
+
+// Validate checks the warm-up configuration and sets the defaults.
+func (w *Warmup) Validate() error {
+	if w.File == "" {
+		return fmt.Errorf("warm-up snapshot file is missing")
+	}
+	if w.SnapshotSeconds < 0 || w.MaxAgeSeconds < 0 || w.Concurrency < 0 {
+		return fmt.Errorf("warm-up durations and concurrency must not be negative")
+	}
+	if w.SnapshotSeconds == 0 {
+		w.SnapshotSeconds = DefaultWarmupSnapshotSeconds
+	}
+	if w.MaxAgeSeconds == 0 {
+		w.MaxAgeSeconds = DefaultWarmupMaxAgeSeconds
+	}
+	if w.Concurrency == 0 {
+		w.Concurrency = DefaultWarmupConcurrency
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A rule expression combines matchers with operators, from the loosest to the tightest:
+//
+//	expression := or { ";" or }
+//	or         := and { "||" and }
+//	and        := unary { "&&" unary }
+//	unary      := "!" unary | "(" expression ")" | matcher
+//	matcher    := Function ":" argument { "," argument }
+//
+// ";" is the historical separator of rules which must all match, so that
+// "Host:foo.bar;Path:/test" keeps its meaning. Arguments run up to the next operator
+// or, inside parentheses, unbalanced ')', anything between braces (e.g. the regular
+// expressions of HostRegexp or Path) being taken as is.
+
+// ruleNode is a node of the tree of a rule expression.
+type ruleNode interface{}
+
+// ruleCall is a matcher, the call of a rule function.
+type ruleCall struct {
+	name string
+	args []string
+	pos  int
+}
+
+type ruleAnd struct {
+	operands []ruleNode
+}
+
+type ruleOr struct {
+	operands []ruleNode
+}
+
+type ruleNot struct {
+	operand ruleNode
+}
+
+// parseError is an error located in a rule expression.
+type parseError struct {
+	expression string
+	// pos is the 1-based position of the error in the expression
+	pos     int
+	message string
+}
+
+func (e *parseError) Error() string {
+	return fmt.Sprintf("%s at position %d in '%s'", e.message, e.pos, e.expression)
+}
+
+type ruleParser struct {
+	expression string
+	pos        int
+	// depth is the number of parentheses the parser is in
+	depth int
+}
+
+// parseExpression parses a rule expression into its tree.
+func parseExpression(expression string) (ruleNode, error) {
+	p := &ruleParser{expression: expression}
+	p.skipSpaces()
+	if p.done() {
+		return nil, p.errorf(p.pos, "empty rule")
+	}
+	node, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf(p.pos, "unexpected '%c'", p.expression[p.pos])
+	}
+	return node, nil
+}
+
+func (p *ruleParser) parseExpression() (ruleNode, error) {
+	var operands []ruleNode
+	for {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, node)
+		if !p.consume(";") {
+			break
+		}
+		// empty rules between ';' were always ignored
+		for p.consume(";") {
+		}
+		if p.done() || p.peek(")") {
+			break
+		}
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return &ruleAnd{operands: operands}, nil
+}
+
+func (p *ruleParser) parseOr() (ruleNode, error) {
+	var operands []ruleNode
+	for {
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, node)
+		if !p.consume("||") {
+			break
+		}
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return &ruleOr{operands: operands}, nil
+}
+
+func (p *ruleParser) parseAnd() (ruleNode, error) {
+	var operands []ruleNode
+	for {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, node)
+		if !p.consume("&&") {
+			break
+		}
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return &ruleAnd{operands: operands}, nil
+}
+
+func (p *ruleParser) parseUnary() (ruleNode, error) {
+	p.skipSpaces()
+	start := p.pos
+	switch {
+	case p.consume("!"):
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &ruleNot{operand: operand}, nil
+	case p.consume("("):
+		if p.peek(")") {
+			return nil, p.errorf(p.pos, "empty parentheses")
+		}
+		p.depth++
+		node, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		p.depth--
+		if !p.consume(")") {
+			return nil, p.errorf(start, "missing ')' closing this '('")
+		}
+		return node, nil
+	}
+	return p.parseCall(p.depth)
+}
+
+// parseCall parses a matcher, its arguments ending at an unbalanced ')' only inside parentheses
+// (depth > 0), as the arguments of the rules without operators may hold any ')'.
+func (p *ruleParser) parseCall(depth int) (*ruleCall, error) {
+	start := p.pos
+	for !p.done() && isNameChar(p.expression[p.pos]) {
+		p.pos++
+	}
+	name := p.expression[start:p.pos]
+	if name == "" {
+		if p.done() {
+			return nil, p.errorf(p.pos, "expected a matcher")
+		}
+		return nil, p.errorf(p.pos, "expected a matcher, got '%c'", p.expression[p.pos])
+	}
+	if !p.consume(":") {
+		return nil, p.errorf(p.pos, "expected ':' after '%s'", name)
+	}
+
+	argsStart := p.pos
+	braces, parens := 0, 0
+scan:
+	for ; !p.done(); p.pos++ {
+		switch c := p.expression[p.pos]; {
+		case c == '{':
+			braces++
+		case c == '}' && braces > 0:
+			braces--
+		case braces > 0:
+		case c == '(':
+			parens++
+		case c == ')' && parens > 0:
+			parens--
+		case c == ')' && depth > 0:
+			break scan
+		case c == ';' || p.peek("&&") || p.peek("||"):
+			break scan
+		}
+	}
+	if braces > 0 {
+		return nil, p.errorf(argsStart, "missing '}' in the arguments of '%s'", name)
+	}
+
+	var args []string
+	for _, arg := range splitArgs(p.expression[argsStart:p.pos]) {
+		// empty arguments were always ignored
+		if arg = strings.TrimSpace(arg); arg != "" {
+			args = append(args, arg)
+		}
+	}
+	if len(args) == 0 {
+		return nil, p.errorf(argsStart, "missing arguments of '%s'", name)
+	}
+	return &ruleCall{name: name, args: args, pos: start + 1}, nil
+}
+
+// splitArgs splits the arguments of a matcher at the commas which are not between braces.
+func splitArgs(s string) []string {
+	var args []string
+	braces, start := 0, 0
+	for i, c := range s {
+		switch {
+		case c == '{':
+			braces++
+		case c == '}' && braces > 0:
+			braces--
+		case c == ',' && braces == 0:
+			args = append(args, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(args, s[start:])
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *ruleParser) done() bool {
+	return p.pos >= len(p.expression)
+}
+
+func (p *ruleParser) skipSpaces() {
+	for !p.done() && (p.expression[p.pos] == ' ' || p.expression[p.pos] == '\t' || p.expression[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+// peek tells whether the expression continues with the token, at the current position.
+func (p *ruleParser) peek(token string) bool {
+	return strings.HasPrefix(p.expression[p.pos:], token)
+}
+
+// consume skips the spaces and the token, if the expression continues with it.
+func (p *ruleParser) consume(token string) bool {
+	p.skipSpaces()
+	if !p.peek(token) {
+		return false
+	}
+	p.pos += len(token)
+	p.skipSpaces()
+	return true
+}
+
+// errorf creates an error located at the 0-based position pos.
+func (p *ruleParser) errorf(pos int, format string, args ...interface{}) error {
+	return &parseError{expression: p.expression, pos: pos + 1, message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,118 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpression(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		expression string
+		expected   ruleNode
+	}{
+		{
+			desc:       "legacy conjunction",
+			expression: "Host: Foo.Bar ; Path:/FOObar;",
+			expected: &ruleAnd{operands: []ruleNode{
+				&ruleCall{name: "Host", args: []string{"Foo.Bar"}, pos: 1},
+				&ruleCall{name: "Path", args: []string{"/FOObar"}, pos: 17},
+			}},
+		},
+		{
+			desc:       "arguments",
+			expression: "Host:foo.bar, ,test.bar:8080",
+			expected:   &ruleCall{name: "Host", args: []string{"foo.bar", "test.bar:8080"}, pos: 1},
+		},
+		{
+			desc:       "precedence",
+			expression: "Host:a || Host:b && !Path:/c",
+			expected: &ruleOr{operands: []ruleNode{
+				&ruleCall{name: "Host", args: []string{"a"}, pos: 1},
+				&ruleAnd{operands: []ruleNode{
+					&ruleCall{name: "Host", args: []string{"b"}, pos: 11},
+					&ruleNot{operand: &ruleCall{name: "Path", args: []string{"/c"}, pos: 22}},
+				}},
+			}},
+		},
+		{
+			desc:       "grouping",
+			expression: "(Host:a || Host:b) && PathPrefix:/api;Method:GET",
+			expected: &ruleAnd{operands: []ruleNode{
+				&ruleAnd{operands: []ruleNode{
+					&ruleOr{operands: []ruleNode{
+						&ruleCall{name: "Host", args: []string{"a"}, pos: 2},
+						&ruleCall{name: "Host", args: []string{"b"}, pos: 12},
+					}},
+					&ruleCall{name: "PathPrefix", args: []string{"/api"}, pos: 23},
+				}},
+				&ruleCall{name: "Method", args: []string{"GET"}, pos: 39},
+			}},
+		},
+		{
+			desc:       "legacy parenthesis in an argument",
+			expression: "Path:/a)b;Host:(c",
+			expected: &ruleAnd{operands: []ruleNode{
+				&ruleCall{name: "Path", args: []string{"/a)b"}, pos: 1},
+				&ruleCall{name: "Host", args: []string{"(c"}, pos: 11},
+			}},
+		},
+		{
+			desc:       "parenthesis in an argument of a group",
+			expression: "(Path:/a(b) || Path:/c)",
+			expected: &ruleOr{operands: []ruleNode{
+				&ruleCall{name: "Path", args: []string{"/a(b)"}, pos: 2},
+				&ruleCall{name: "Path", args: []string{"/c"}, pos: 16},
+			}},
+		},
+		{
+			desc:       "regular expressions",
+			expression: "!(HostRegexp:{subdomain:(foo\\.)?bar\\.com} || Path:/{id:[0-9]{1,3}})",
+			expected: &ruleNot{operand: &ruleOr{operands: []ruleNode{
+				&ruleCall{name: "HostRegexp", args: []string{"{subdomain:(foo\\.)?bar\\.com}"}, pos: 3},
+				&ruleCall{name: "Path", args: []string{"/{id:[0-9]{1,3}}"}, pos: 46},
+			}}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			node, err := parseExpression(test.expression)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, node)
+		})
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   string
+	}{
+		{expression: " ", expected: "empty rule at position 2 in ' '"},
+		{expression: "Host", expected: "expected ':' after 'Host' at position 5 in 'Host'"},
+		{expression: "Host:foo.bar &&", expected: "expected a matcher at position 16 in 'Host:foo.bar &&'"},
+		{expression: "Host:a || || Host:b", expected: "expected a matcher, got '|' at position 11 in 'Host:a || || Host:b'"},
+		{expression: "Path: , ", expected: "missing arguments of 'Path' at position 7 in 'Path: , '"},
+		{expression: "(Host:a || Host:b", expected: "missing ')' closing this '(' at position 1 in '(Host:a || Host:b'"},
+		{expression: "(Host:a))", expected: "unexpected ')' at position 9 in '(Host:a))'"},
+		{expression: "!()", expected: "empty parentheses at position 3 in '!()'"},
+		{expression: "Path:/{id", expected: "missing '}' in the arguments of 'Path' at position 6 in 'Path:/{id'"},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseExpression(test.expression)
+			require.Error(t, err)
+			assert.Equal(t, test.expected, err.Error())
+		})
+	}
+}
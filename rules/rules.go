@@ -1,7 +1,6 @@
 package rules
 
 import (
-	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -12,12 +11,17 @@ import (
 	"github.com/BurntSushi/ty/fun"
 	"github.com/containous/mux"
 	"github.com/containous/traefik/types"
+	"github.com/containous/traefik/whitelist"
+	jwt "github.com/dgrijalva/jwt-go"
 )
 
 // Rules holds rule parsing and configuration
 type Rules struct {
 	Route *types.ServerRoute
-	err   error
+	// TrustedProxies are trusted to set the X-Forwarded-For header, the ClientIP matcher
+	// using the remote address of the requests when nil
+	TrustedProxies *whitelist.IP
+	err            error
 }
 
 func (r *Rules) host(hosts ...string) *mux.Route {
@@ -168,8 +172,8 @@ func (r *Rules) query(query ...string) *mux.Route {
 	return r.Route.Route.Queries(queries...)
 }
 
-func (r *Rules) parseRules(expression string, onRule func(functionName string, function interface{}, arguments []string) error) error {
-	functions := map[string]interface{}{
+func (r *Rules) functions() map[string]interface{} {
+	return map[string]interface{}{
 		"Host":                 r.host,
 		"HostRegexp":           r.hostRegexp,
 		"Path":                 r.path,
@@ -185,83 +189,280 @@ func (r *Rules) parseRules(expression string, onRule func(functionName string, f
 		"ReplacePath":          r.replacePath,
 		"ReplacePathRegex":     r.replacePathRegex,
 		"Query":                r.query,
+		"ClientIP":             r.clientIP,
+		"Cookie":               r.cookie,
+		"JWTClaim":             r.jwtClaim,
 	}
+}
+
+// matchers are the functions only matching requests, which can be combined with '||' and '!'.
+// The other functions also modify the requests and can only be in the conjunction of the rule.
+var matchers = map[string]bool{
+	"Host":          true,
+	"HostRegexp":    true,
+	"Path":          true,
+	"PathPrefix":    true,
+	"Method":        true,
+	"Headers":       true,
+	"HeadersRegexp": true,
+	"Query":         true,
+	"ClientIP":      true,
+	"Cookie":        true,
+	"JWTClaim":      true,
+}
 
-	if len(expression) == 0 {
-		return errors.New("empty rule")
+// clientIP matches the requests whose client address is in one of the ranges.
+func (r *Rules) clientIP(ranges ...string) *mux.Route {
+	ips, err := whitelist.NewIP(ranges, false, false)
+	if err != nil {
+		r.err = err
+		return r.Route.Route
 	}
+	return r.Route.Route.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
+		ip := clientIP(req, r.TrustedProxies)
+		return ip != nil && ips.ContainsIP(ip)
+	})
+}
 
-	f := func(c rune) bool {
-		return c == ':'
+// clientIP returns the address of the client of the request, skipping the trusted proxies
+// of the X-Forwarded-For header from the last one.
+func clientIP(req *http.Request, trustedProxies *whitelist.IP) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if trustedProxies == nil {
+		return ip
 	}
 
-	// Allow multiple rules separated by ;
-	splitRule := func(c rune) bool {
-		return c == ';'
+	var forwardedFor []string
+	for _, header := range req.Header[whitelist.XForwardedFor] {
+		forwardedFor = append(forwardedFor, strings.Split(header, ",")...)
 	}
+	for i := len(forwardedFor) - 1; i >= 0 && ip != nil && trustedProxies.ContainsIP(ip); i-- {
+		addr := strings.TrimSpace(forwardedFor[i])
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+		ip = net.ParseIP(addr)
+	}
+	return ip
+}
 
-	parsedRules := strings.FieldsFunc(expression, splitRule)
+// cookie matches the requests with the named cookie set to one of the values.
+func (r *Rules) cookie(args ...string) *mux.Route {
+	if len(args) < 2 {
+		r.err = fmt.Errorf("cookie %s: missing value", args[0])
+		return r.Route.Route
+	}
+	name, values := args[0], stringSet(args[1:])
+	return r.Route.Route.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
+		cookie, err := req.Cookie(name)
+		return err == nil && values[cookie.Value]
+	})
+}
 
-	for _, rule := range parsedRules {
-		// get function
-		parsedFunctions := strings.FieldsFunc(rule, f)
-		if len(parsedFunctions) == 0 {
-			return fmt.Errorf("error parsing rule: '%s'", rule)
+// jwtClaim matches the claims of the bearer token. The claims are not verified: routes are
+// matched before the token is authenticated, so a forged token can select the route, and
+// the claims must not be relied upon to grant access.
+func (r *Rules) jwtClaim(args ...string) *mux.Route {
+	if len(args) < 2 {
+		r.err = fmt.Errorf("claim %s: missing value", args[0])
+		return r.Route.Route
+	}
+	name, values := args[0], stringSet(args[1:])
+	return r.Route.Route.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(strings.ToLower(auth), "bearer ") {
+			return false
 		}
-
-		functionName := strings.TrimSpace(parsedFunctions[0])
-		parsedFunction, ok := functions[functionName]
+		claims := jwt.MapClaims{}
+		if _, _, err := new(jwt.Parser).ParseUnverified(strings.TrimSpace(auth[len("bearer "):]), claims); err != nil {
+			return false
+		}
+		// the values of a list claim (e.g. groups) match on their own
+		claim, ok := claims[name].([]interface{})
 		if !ok {
-			return fmt.Errorf("error parsing rule: '%s'. Unknown function: '%s'", rule, parsedFunctions[0])
+			claim = []interface{}{claims[name]}
 		}
-		parsedFunctions = append(parsedFunctions[:0], parsedFunctions[1:]...)
-
-		// get function
-		fargs := func(c rune) bool {
-			return c == ','
+		for _, value := range claim {
+			if value != nil && values[fmt.Sprint(value)] {
+				return true
+			}
 		}
-		parsedArgs := strings.FieldsFunc(strings.Join(parsedFunctions, ":"), fargs)
-		if len(parsedArgs) == 0 {
-			return fmt.Errorf("error parsing args from rule: '%s'", rule)
+		return false
+	})
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// parseRules parses the expression and returns the operands of its conjunction.
+func (r *Rules) parseRules(expression string) ([]ruleNode, error) {
+	node, err := parseExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	functions := r.functions()
+	var check func(node ruleNode, combined bool) error
+	check = func(node ruleNode, combined bool) error {
+		switch n := node.(type) {
+		case *ruleCall:
+			if _, ok := functions[n.name]; !ok {
+				return &parseError{expression: expression, pos: n.pos, message: fmt.Sprintf("unknown function '%s'", n.name)}
+			}
+			if combined && !matchers[n.name] {
+				return &parseError{expression: expression, pos: n.pos, message: fmt.Sprintf("'%s' cannot be combined with '||' or '!'", n.name)}
+			}
+		case *ruleAnd:
+			for _, operand := range n.operands {
+				if err := check(operand, combined); err != nil {
+					return err
+				}
+			}
+		case *ruleOr:
+			for _, operand := range n.operands {
+				if err := check(operand, true); err != nil {
+					return err
+				}
+			}
+		case *ruleNot:
+			return check(n.operand, true)
 		}
+		return nil
+	}
+	if err := check(node, false); err != nil {
+		return nil, err
+	}
+	return conjunction(node), nil
+}
 
-		for i := range parsedArgs {
-			parsedArgs[i] = strings.TrimSpace(parsedArgs[i])
+// conjunction flattens the nested conjunctions of the node.
+func conjunction(node ruleNode) []ruleNode {
+	and, ok := node.(*ruleAnd)
+	if !ok {
+		return []ruleNode{node}
+	}
+	var operands []ruleNode
+	for _, operand := range and.operands {
+		operands = append(operands, conjunction(operand)...)
+	}
+	return operands
+}
+
+// call calls the function of the matcher on the route of the rules.
+func (r *Rules) call(call *ruleCall) (*mux.Route, error) {
+	inputs := make([]reflect.Value, len(call.args))
+	for i := range call.args {
+		inputs[i] = reflect.ValueOf(call.args[i])
+	}
+	method := reflect.ValueOf(r.functions()[call.name])
+	if !method.IsValid() {
+		return nil, fmt.Errorf("method not found: '%s'", call.name)
+	}
+	route := method.Call(inputs)[0].Interface().(*mux.Route)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if route.GetError() != nil {
+		return nil, route.GetError()
+	}
+	return route, nil
+}
+
+// match builds the function matching the requests of a combination of matchers,
+// each of them being evaluated on its own route.
+func (r *Rules) match(node ruleNode) (func(*http.Request) bool, error) {
+	switch n := node.(type) {
+	case *ruleCall:
+		rules := &Rules{Route: &types.ServerRoute{Route: &mux.Route{}}, TrustedProxies: r.TrustedProxies}
+		route, err := rules.call(n)
+		if err != nil {
+			return nil, err
 		}
+		return func(req *http.Request) bool {
+			return route.Match(req, &mux.RouteMatch{})
+		}, nil
+	case *ruleNot:
+		operand, err := r.match(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		return func(req *http.Request) bool {
+			return !operand(req)
+		}, nil
+	case *ruleAnd:
+		operands, err := r.matchAll(n.operands)
+		if err != nil {
+			return nil, err
+		}
+		return func(req *http.Request) bool {
+			for _, operand := range operands {
+				if !operand(req) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case *ruleOr:
+		operands, err := r.matchAll(n.operands)
+		if err != nil {
+			return nil, err
+		}
+		return func(req *http.Request) bool {
+			for _, operand := range operands {
+				if operand(req) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected rule node %T", node)
+}
 
-		err := onRule(functionName, parsedFunction, parsedArgs)
+func (r *Rules) matchAll(nodes []ruleNode) ([]func(*http.Request) bool, error) {
+	matches := make([]func(*http.Request) bool, len(nodes))
+	for i, node := range nodes {
+		m, err := r.match(node)
 		if err != nil {
-			return fmt.Errorf("parsing error on rule: %v", err)
+			return nil, err
 		}
+		matches[i] = m
 	}
-	return nil
+	return matches, nil
 }
 
 // Parse parses rules expressions
 func (r *Rules) Parse(expression string) (*mux.Route, error) {
-	var resultRoute *mux.Route
+	operands, err := r.parseRules(expression)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing rule: %v", err)
+	}
 
-	err := r.parseRules(expression, func(functionName string, function interface{}, arguments []string) error {
-		inputs := make([]reflect.Value, len(arguments))
-		for i := range arguments {
-			inputs[i] = reflect.ValueOf(arguments[i])
-		}
-		method := reflect.ValueOf(function)
-		if method.IsValid() {
-			resultRoute = method.Call(inputs)[0].Interface().(*mux.Route)
-			if r.err != nil {
-				return r.err
-			}
-			if resultRoute.GetError() != nil {
-				return resultRoute.GetError()
+	var resultRoute *mux.Route
+	for _, operand := range operands {
+		// the matchers of the conjunction apply to the route itself, as they always did
+		if call, ok := operand.(*ruleCall); ok {
+			if resultRoute, err = r.call(call); err != nil {
+				return nil, fmt.Errorf("error parsing rule: parsing error on rule: %v", err)
 			}
-		} else {
-			return fmt.Errorf("method not found: '%s'", functionName)
+			continue
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error parsing rule: %v", err)
+		m, err := r.match(operand)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing rule: parsing error on rule: %v", err)
+		}
+		resultRoute = r.Route.Route.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
+			return m(req)
+		})
 	}
 
 	return resultRoute, nil
@@ -269,17 +470,33 @@ func (r *Rules) Parse(expression string) (*mux.Route, error) {
 
 // ParseDomains parses rules expressions and returns domains
 func (r *Rules) ParseDomains(expression string) ([]string, error) {
-	var domains []string
-
-	err := r.parseRules(expression, func(functionName string, function interface{}, arguments []string) error {
-		if functionName == "Host" {
-			domains = append(domains, arguments...)
-		}
-		return nil
-	})
+	operands, err := r.parseRules(expression)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing domains: %v", err)
 	}
 
+	// the hosts of negated matchers are not served
+	var domains []string
+	var collect func(node ruleNode)
+	collect = func(node ruleNode) {
+		switch n := node.(type) {
+		case *ruleCall:
+			if n.name == "Host" {
+				domains = append(domains, n.args...)
+			}
+		case *ruleAnd:
+			for _, operand := range n.operands {
+				collect(operand)
+			}
+		case *ruleOr:
+			for _, operand := range n.operands {
+				collect(operand)
+			}
+		}
+	}
+	for _, operand := range operands {
+		collect(operand)
+	}
+
 	return fun.Map(types.CanonicalDomain, domains).([]string), nil
 }
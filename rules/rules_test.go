@@ -8,6 +8,8 @@ import (
 	"github.com/containous/mux"
 	"github.com/containous/traefik/testhelpers"
 	"github.com/containous/traefik/types"
+	"github.com/containous/traefik/whitelist"
+	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -50,6 +52,22 @@ func TestParseTwoRules(t *testing.T) {
 	assert.True(t, routeMatch, "Rule %s don't match.", expression)
 }
 
+func TestParseLegacyParenthesis(t *testing.T) {
+	router := mux.NewRouter()
+	route := router.NewRoute()
+	serverRoute := &types.ServerRoute{Route: route}
+	rules := &Rules{Route: serverRoute}
+
+	expression := "Path:/a)b"
+	routeResult, err := rules.Parse(expression)
+	require.NoError(t, err, "Error while building route for %s", expression)
+
+	request := testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar/a)b", nil)
+	routeMatch := routeResult.Match(request, &mux.RouteMatch{Route: routeResult})
+
+	assert.True(t, routeMatch, "Rule %s don't match.", expression)
+}
+
 func TestParseDomains(t *testing.T) {
 	rules := &Rules{}
 
@@ -73,6 +91,10 @@ func TestParseDomains(t *testing.T) {
 			expression: "Host: Foo.Bar ;Path:/test",
 			domain:     []string{"foo.bar"},
 		},
+		{
+			expression: "(Host:foo.bar || Host:test.bar) && !Host:admin.bar",
+			domain:     []string{"foo.bar", "test.bar"},
+		},
 	}
 
 	for _, test := range tests {
@@ -88,6 +110,76 @@ func TestParseDomains(t *testing.T) {
 	}
 }
 
+func TestParseExpressions(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		expression string
+		urls       map[string]bool
+	}{
+		{
+			desc:       "or",
+			expression: "Host:foo.bar || Host:test.bar",
+			urls: map[string]bool{
+				"http://foo.bar/api":  true,
+				"http://test.bar/api": true,
+				"http://bar/api":      false,
+			},
+		},
+		{
+			desc:       "not",
+			expression: "(Host:foo.bar || Host:test.bar) && PathPrefix:/api && !PathPrefix:/api/v1/secrets",
+			urls: map[string]bool{
+				"http://foo.bar/api/v1/pods":     true,
+				"http://test.bar/api/v1/pods":    true,
+				"http://foo.bar/api/v1/secrets":  false,
+				"http://foo.bar/oapi/v1/secrets": false,
+				"http://bar/api/v1/pods":         false,
+			},
+		},
+		{
+			desc:       "legacy conjunction",
+			expression: "Host:foo.bar;Path:/{id:[0-9]+} || Query:id={id:[0-9]+}",
+			urls: map[string]bool{
+				"http://foo.bar/12":        true,
+				"http://foo.bar/list?id=1": true,
+				"http://foo.bar/list":      false,
+				"http://test.bar/12":       false,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rules := &Rules{Route: &types.ServerRoute{Route: mux.NewRouter().NewRoute()}}
+			route, err := rules.Parse(test.expression)
+			require.NoError(t, err)
+
+			for testURL, expected := range test.urls {
+				req := testhelpers.MustNewRequest(http.MethodGet, testURL, nil)
+				assert.Equal(t, expected, route.Match(req, &mux.RouteMatch{}), testURL)
+			}
+		})
+	}
+}
+
+func TestParseStripInExpression(t *testing.T) {
+	rules := &Rules{Route: &types.ServerRoute{Route: mux.NewRouter().NewRoute()}}
+	route, err := rules.Parse("(Host:foo.bar || Host:test.bar) && PathPrefixStrip:/api")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/api"}, rules.Route.StripPrefixes)
+	assert.True(t, route.Match(testhelpers.MustNewRequest(http.MethodGet, "http://test.bar/api/pods", nil), &mux.RouteMatch{}))
+
+	rules = &Rules{Route: &types.ServerRoute{Route: mux.NewRouter().NewRoute()}}
+	_, err = rules.Parse("Host:foo.bar || PathPrefixStrip:/api")
+	assert.EqualError(t, err, "error parsing rule: 'PathPrefixStrip' cannot be combined with '||' or '!' at position 17 in 'Host:foo.bar || PathPrefixStrip:/api'")
+
+	_, err = rules.Parse("Host:foo.bar && Hots:test.bar")
+	assert.EqualError(t, err, "error parsing rule: unknown function 'Hots' at position 17 in 'Host:foo.bar && Hots:test.bar'")
+}
+
 func TestPriorites(t *testing.T) {
 	router := mux.NewRouter()
 	router.StrictSlash(true)
@@ -257,3 +349,123 @@ func TestPathPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestClientIP(t *testing.T) {
+	trustedProxies, err := whitelist.NewIP([]string{"10.0.0.1"}, false, false)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc           string
+		trustedProxies *whitelist.IP
+		remoteAddr     string
+		forwardedFor   []string
+		expected       bool
+	}{
+		{desc: "remote address", remoteAddr: "192.168.1.10:34567", expected: true},
+		{desc: "other network", remoteAddr: "192.168.2.10:34567", expected: false},
+		{desc: "untrusted forwarded header", remoteAddr: "10.0.0.1:34567", forwardedFor: []string{"192.168.1.10"}, expected: false},
+		{desc: "trusted proxy", trustedProxies: trustedProxies, remoteAddr: "10.0.0.1:34567", forwardedFor: []string{"192.168.1.10"}, expected: true},
+		{desc: "spoofed forwarded header", trustedProxies: trustedProxies, remoteAddr: "10.0.0.1:34567", forwardedFor: []string{"192.168.1.10, 172.16.0.5"}, expected: false},
+		{desc: "untrusted proxy", trustedProxies: trustedProxies, remoteAddr: "10.0.0.2:34567", forwardedFor: []string{"192.168.1.10"}, expected: false},
+		{desc: "several headers", trustedProxies: trustedProxies, remoteAddr: "10.0.0.1:34567", forwardedFor: []string{"172.16.0.5", "192.168.1.10:4567, 10.0.0.1"}, expected: true},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rules := &Rules{Route: &types.ServerRoute{Route: mux.NewRouter().NewRoute()}, TrustedProxies: test.trustedProxies}
+			route, err := rules.Parse("ClientIP:192.168.1.0/24, 2001:db8::/32")
+			require.NoError(t, err)
+
+			req := testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header["X-Forwarded-For"] = test.forwardedFor
+			assert.Equal(t, test.expected, route.Match(req, &mux.RouteMatch{}))
+		})
+	}
+
+	rules := &Rules{Route: &types.ServerRoute{Route: mux.NewRouter().NewRoute()}}
+	_, err = rules.Parse("ClientIP:192.168.1.0/33")
+	assert.Error(t, err)
+}
+
+func TestCookie(t *testing.T) {
+	rules := &Rules{Route: &types.ServerRoute{Route: mux.NewRouter().NewRoute()}}
+	route, err := rules.Parse("Cookie:canary,internal,beta")
+	require.NoError(t, err)
+
+	for cookie, expected := range map[string]bool{
+		"":                     false,
+		"canary=internal":      true,
+		"other=1; canary=beta": true,
+		"canary=public":        false,
+		"other=internal":       false,
+	} {
+		req := testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar", nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		assert.Equal(t, expected, route.Match(req, &mux.RouteMatch{}), cookie)
+	}
+
+	_, err = (&Rules{Route: &types.ServerRoute{Route: mux.NewRouter().NewRoute()}}).Parse("Cookie:canary")
+	assert.Error(t, err, "missing value")
+}
+
+func TestJWTClaim(t *testing.T) {
+	rules := &Rules{Route: &types.ServerRoute{Route: mux.NewRouter().NewRoute()}}
+	route, err := rules.Parse("JWTClaim:groups,osio-internal || JWTClaim:email_verified,true")
+	require.NoError(t, err)
+
+	token := func(claims jwt.MapClaims) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		require.NoError(t, err)
+		return "Bearer " + signed
+	}
+	for desc, test := range map[string]struct {
+		auth     string
+		expected bool
+	}{
+		"no token":       {auth: "", expected: false},
+		"invalid token":  {auth: "Bearer abc", expected: false},
+		"basic auth":     {auth: "Basic YWxhZGRpbjpvcGVuc2VzYW1l", expected: false},
+		"list claim":     {auth: token(jwt.MapClaims{"groups": []string{"users", "osio-internal"}}), expected: true},
+		"boolean claim":  {auth: token(jwt.MapClaims{"groups": []string{"users"}, "email_verified": true}), expected: true},
+		"other values":   {auth: token(jwt.MapClaims{"groups": []string{"users"}, "email_verified": false}), expected: false},
+		"missing claims": {auth: token(jwt.MapClaims{"sub": "john"}), expected: false},
+	} {
+		req := testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar", nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		assert.Equal(t, test.expected, route.Match(req, &mux.RouteMatch{}), desc)
+	}
+}
+
+func TestCanaryPriority(t *testing.T) {
+	router := mux.NewRouter()
+	parse := func(expression string, handler http.Handler) {
+		rules := &Rules{Route: &types.ServerRoute{Route: router.NewRoute()}}
+		route, err := rules.Parse(expression)
+		require.NoError(t, err)
+		// as the server does, the routes are sorted by the length of their rule
+		route.Priority(len(expression)).Handler(handler)
+	}
+	stableHandler := &fakeHandler{name: "stable"}
+	canaryHandler := &fakeHandler{name: "canary"}
+	parse("Host:foo.bar", stableHandler)
+	parse("Host:foo.bar;Cookie:canary,internal", canaryHandler)
+	router.SortRoutes()
+
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar", nil)
+	match := &mux.RouteMatch{}
+	require.True(t, router.Match(req, match))
+	assert.Equal(t, stableHandler, match.Handler)
+
+	req.Header.Set("Cookie", "canary=internal")
+	match = &mux.RouteMatch{}
+	require.True(t, router.Match(req, match))
+	assert.Equal(t, canaryHandler, match.Handler)
+}
@@ -23,6 +23,7 @@ import (
 
 	proxyproto "github.com/armon/go-proxyproto"
 	"github.com/containous/mux"
+	"github.com/containous/traefik/api"
 	"github.com/containous/traefik/cluster"
 	"github.com/containous/traefik/configuration"
 	"github.com/containous/traefik/healthcheck"
@@ -31,12 +32,15 @@ import (
 	"github.com/containous/traefik/middlewares"
 	"github.com/containous/traefik/middlewares/accesslog"
 	mauth "github.com/containous/traefik/middlewares/auth"
+	"github.com/containous/traefik/middlewares/balancer"
+	"github.com/containous/traefik/middlewares/cache"
 	"github.com/containous/traefik/middlewares/errorpages"
 	osio "github.com/containous/traefik/middlewares/osio"
 	"github.com/containous/traefik/middlewares/redirect"
 	"github.com/containous/traefik/middlewares/tracing"
 	"github.com/containous/traefik/provider"
 	"github.com/containous/traefik/provider/acme"
+	osioprovider "github.com/containous/traefik/provider/osio"
 	"github.com/containous/traefik/rules"
 	"github.com/containous/traefik/safe"
 	"github.com/containous/traefik/server/cookie"
@@ -145,7 +149,30 @@ func NewServer(globalConfiguration configuration.GlobalConfiguration, provider p
 
 	// TODO: Expose via config?
 	log.Info("Initialize OSIO Auth middleware")
-	server.osioMiddleware = osio.NewPreConfiguredOSIOAuth()
+	keyring, err := osioprovider.NewPreConfiguredKeyring()
+	if err != nil {
+		log.Fatalf("Error loading OSIO keyring: %v", err)
+	}
+	keyring.DecryptionsCounter = server.metricsRegistry.OSIOTokenDecryptionsCounter()
+	if filename := os.Getenv("AUTH_TOKEN_KEYRING"); filename != "" {
+		if err := keyring.Watch(server.routinesPool, filename); err != nil {
+			log.Errorf("Error watching OSIO keyring: %v", err)
+		}
+	}
+	server.osioMiddleware, err = osio.NewFromProvider(globalConfiguration.OSIO, keyring)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server.osioMiddleware.RequestsCounter = server.metricsRegistry.OSIORequestsCounter()
+	if globalConfiguration.OSIO != nil && globalConfiguration.OSIO.Warmup != nil {
+		if err := server.osioMiddleware.SetWarmup(globalConfiguration.OSIO.Warmup, server.routinesPool); err != nil {
+			log.Fatalf("Error configuring OSIO cache warm-up: %v", err)
+		}
+	}
+	if server.globalConfiguration.API != nil {
+		server.globalConfiguration.API.OSIOCache = api.NewOSIOCacheHandler(server.osioMiddleware, os.Getenv("OSIO_ADMIN_TOKEN"), os.Getenv("OSIO_ADMIN_PEERS"))
+		server.globalConfiguration.API.HealthCheck = healthcheck.GetHealthCheck(server.metricsRegistry)
+	}
 	return server
 }
 
@@ -344,6 +371,15 @@ func (s *Server) setupServerEntryPoint(newServerEntryPointName string, newServer
 		}
 	}
 
+	// preflight requests carry no credentials, they are answered before the authentication
+	corsMiddleware, err := middlewares.NewCORS(s.globalConfiguration.EntryPoints[newServerEntryPointName].CORS)
+	if err != nil {
+		log.Fatal("Error starting server: ", err)
+	}
+	if corsMiddleware != nil {
+		serverMiddlewares = append(serverMiddlewares, corsMiddleware)
+	}
+
 	if s.globalConfiguration.EntryPoints[newServerEntryPointName].Auth != nil {
 		authMiddleware, err := mauth.NewAuthenticator(s.globalConfiguration.EntryPoints[newServerEntryPointName].Auth, s.tracingMiddleware)
 		if err != nil {
@@ -937,6 +973,7 @@ func (s *Server) loadConfig(configurations types.Configurations, globalConfigura
 	backends := map[string]http.Handler{}
 	backendsHealthCheck := map[string]*healthcheck.BackendHealthCheck{}
 	var errorPageHandlers []*errorpages.Handler
+	var mirrors []*middlewares.Mirror
 
 	errorHandler := NewRecordingErrorHandler(middlewares.DefaultNetErrorRecorder{})
 
@@ -968,7 +1005,7 @@ func (s *Server) loadConfig(configurations types.Configurations, globalConfigura
 
 				newServerRoute := &types.ServerRoute{Route: serverEntryPoints[entryPointName].httpRouter.GetHandler().NewRoute().Name(frontendName)}
 				for routeName, route := range frontend.Routes {
-					err := getRoute(newServerRoute, &route)
+					err := getRoute(newServerRoute, &route, globalConfiguration.EntryPoints[entryPointName].ForwardedHeaders)
 					if err != nil {
 						log.Errorf("Error creating route for frontend %s: %v", frontendName, err)
 						log.Errorf("Skipping frontend %s...", frontendName)
@@ -978,289 +1015,426 @@ func (s *Server) loadConfig(configurations types.Configurations, globalConfigura
 				}
 
 				entryPoint := globalConfiguration.EntryPoints[entryPointName]
-				n := negroni.New()
-				if entryPoint.Redirect != nil && entryPointName != entryPoint.Redirect.EntryPoint {
-					if redirectHandlers[entryPointName] != nil {
-						n.Use(redirectHandlers[entryPointName])
-					} else if handler, err := s.buildRedirectHandler(entryPointName, entryPoint.Redirect); err != nil {
-						log.Errorf("Error loading entrypoint configuration for frontend %s: %v", frontendName, err)
+				var split *middlewares.Split
+				if frontend.Split != nil {
+					var err error
+					if split, err = s.buildSplit(frontendName, frontend.Split); err != nil {
+						log.Errorf("Error creating split for frontend %s: %v", frontendName, err)
 						log.Errorf("Skipping frontend %s...", frontendName)
 						continue frontend
-					} else {
-						handlerToUse := s.wrapNegroniHandlerWithAccessLog(handler, fmt.Sprintf("entrypoint redirect for %s", frontendName))
-						n.Use(handlerToUse)
-						redirectHandlers[entryPointName] = handlerToUse
 					}
 				}
-				if backends[entryPointName+providerName+frontend.Backend] == nil {
-					log.Debugf("Creating backend %s", frontend.Backend)
-
-					roundTripper, err := s.getRoundTripper(entryPointName, globalConfiguration, frontend.PassTLSCert, entryPoint.TLS)
-					if err != nil {
-						log.Errorf("Failed to create RoundTripper for frontend %s: %v", frontendName, err)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					}
-
-					rewriter, err := NewHeaderRewriter(entryPoint.ForwardedHeaders.TrustedIPs, entryPoint.ForwardedHeaders.Insecure)
-					if err != nil {
-						log.Errorf("Error creating rewriter for frontend %s: %v", frontendName, err)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					}
+				for _, frontend := range backendFrontends(frontend) {
+					if backends[entryPointName+providerName+frontend.Backend] == nil {
+						log.Debugf("Creating backend %s", frontend.Backend)
 
-					headerMiddleware := middlewares.NewHeaderFromStruct(frontend.Headers)
-					secureMiddleware := middlewares.NewSecure(frontend.Headers)
-
-					var responseModifier = buildModifyResponse(secureMiddleware, headerMiddleware)
-					var fwd http.Handler
+						roundTripper, err := s.getRoundTripper(entryPointName, globalConfiguration, frontend.PassTLSCert, entryPoint.TLS)
+						if err != nil {
+							log.Errorf("Failed to create RoundTripper for frontend %s: %v", frontendName, err)
+							log.Errorf("Skipping frontend %s...", frontendName)
+							continue frontend
+						}
 
-					fwd, err = forward.New(
-						forward.Stream(true),
-						forward.PassHostHeader(frontend.PassHostHeader),
-						forward.RoundTripper(roundTripper),
-						forward.ErrorHandler(errorHandler),
-						forward.Rewriter(rewriter),
-						forward.ResponseModifier(responseModifier),
-						forward.BufferPool(s.bufferPool),
-					)
+						rewriter, err := NewHeaderRewriter(entryPoint.ForwardedHeaders.TrustedIPs, entryPoint.ForwardedHeaders.Insecure)
+						if err != nil {
+							log.Errorf("Error creating rewriter for frontend %s: %v", frontendName, err)
+							log.Errorf("Skipping frontend %s...", frontendName)
+							continue frontend
+						}
 
-					if err != nil {
-						log.Errorf("Error creating forwarder for frontend %s: %v", frontendName, err)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					}
+						// the response modifier is the one of the frontend of the request, the backend being shared
+						var responseModifier = middlewares.ModifyResponse
+						var fwd http.Handler
 
-					if s.tracingMiddleware.IsEnabled() {
-						tm := s.tracingMiddleware.NewForwarderMiddleware(frontendName, frontend.Backend)
+						fwd, err = forward.New(
+							forward.Stream(true),
+							forward.PassHostHeader(frontend.PassHostHeader),
+							forward.RoundTripper(roundTripper),
+							forward.ErrorHandler(errorHandler),
+							forward.Rewriter(rewriter),
+							forward.ResponseModifier(responseModifier),
+							forward.BufferPool(s.bufferPool),
+						)
 
-						next := fwd
-						fwd = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-							tm.ServeHTTP(w, r, next.ServeHTTP)
-						})
-					}
+						if err != nil {
+							log.Errorf("Error creating forwarder for frontend %s: %v", frontendName, err)
+							log.Errorf("Skipping frontend %s...", frontendName)
+							continue frontend
+						}
 
-					var rr *roundrobin.RoundRobin
-					var saveFrontend http.Handler
-					if s.accessLoggerMiddleware != nil {
-						saveBackend := accesslog.NewSaveBackend(fwd, frontend.Backend)
-						saveFrontend = accesslog.NewSaveFrontend(saveBackend, frontendName)
-						rr, _ = roundrobin.New(saveFrontend)
-					} else {
-						rr, _ = roundrobin.New(fwd)
-					}
+						fwd = middlewares.NewUpgradeForwarder(fwd, roundTripper, rewriter, responseModifier, errorHandler,
+							buildUpgradeOptions(frontend.PassHostHeader, globalConfiguration.ForwardingTimeouts), s.metricsRegistry, frontend.Backend)
 
-					if config.Backends[frontend.Backend] == nil {
-						log.Errorf("Undefined backend '%s' for frontend %s", frontend.Backend, frontendName)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					}
+						if s.tracingMiddleware.IsEnabled() {
+							tm := s.tracingMiddleware.NewForwarderMiddleware(frontendName, frontend.Backend)
 
-					lbMethod, err := types.NewLoadBalancerMethod(config.Backends[frontend.Backend].LoadBalancer)
-					if err != nil {
-						log.Errorf("Error loading load balancer method '%+v' for frontend %s: %v", config.Backends[frontend.Backend].LoadBalancer, frontendName, err)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					}
+							next := fwd
+							fwd = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+								tm.ServeHTTP(w, r, next.ServeHTTP)
+							})
+						}
 
-					var sticky *roundrobin.StickySession
-					var cookieName string
-					if stickiness := config.Backends[frontend.Backend].LoadBalancer.Stickiness; stickiness != nil {
-						cookieName = cookie.GetName(stickiness.CookieName, frontend.Backend)
-						sticky = roundrobin.NewStickySession(cookieName)
-					}
+						var passiveHealthCheck *healthcheck.PassiveHealthCheck
+						if backend := config.Backends[frontend.Backend]; backend != nil && backend.PassiveHealthCheck != nil {
+							passiveHealthCheck, err = buildPassiveHealthCheck(frontend.Backend, backend.PassiveHealthCheck)
+							if err != nil {
+								log.Errorf("Error creating passive health check for frontend %s: %v", frontendName, err)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							fwd = passiveHealthCheck.Handler(fwd)
+						}
 
-					var lb http.Handler
-					switch lbMethod {
-					case types.Drr:
-						log.Debugf("Creating load-balancer drr")
-						rebalancer, _ := roundrobin.NewRebalancer(rr)
-						if sticky != nil {
-							log.Debugf("Sticky session with cookie %v", cookieName)
-							rebalancer, _ = roundrobin.NewRebalancer(rr, roundrobin.RebalancerStickySession(sticky))
+						var rr *roundrobin.RoundRobin
+						var saveFrontend http.Handler
+						if s.accessLoggerMiddleware != nil {
+							saveBackend := accesslog.NewSaveBackend(fwd, frontend.Backend)
+							saveFrontend = accesslog.NewSaveFrontend(saveBackend, frontendName)
+							rr, _ = roundrobin.New(saveFrontend)
+						} else {
+							rr, _ = roundrobin.New(fwd)
 						}
-						lb = rebalancer
-						if err := s.configureLBServers(rebalancer, config, frontend); err != nil {
+
+						if config.Backends[frontend.Backend] == nil {
+							log.Errorf("Undefined backend '%s' for frontend %s", frontend.Backend, frontendName)
 							log.Errorf("Skipping frontend %s...", frontendName)
 							continue frontend
 						}
-						hcOpts := parseHealthCheckOptions(rebalancer, frontend.Backend, config.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
-						if hcOpts != nil {
-							log.Debugf("Setting up backend health check %s", *hcOpts)
-							hcOpts.Transport = s.defaultForwardingRoundTripper
-							backendsHealthCheck[entryPointName+frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts, frontend.Backend)
-						}
-						lb = middlewares.NewEmptyBackendHandler(rebalancer, lb)
-					case types.Wrr:
-						log.Debugf("Creating load-balancer wrr")
-						if sticky != nil {
-							log.Debugf("Sticky session with cookie %v", cookieName)
-							if s.accessLoggerMiddleware != nil {
-								rr, _ = roundrobin.New(saveFrontend, roundrobin.EnableStickySession(sticky))
-							} else {
-								rr, _ = roundrobin.New(fwd, roundrobin.EnableStickySession(sticky))
-							}
-						}
-						lb = rr
-						if err := s.configureLBServers(rr, config, frontend); err != nil {
+
+						lbMethod, err := types.NewLoadBalancerMethod(config.Backends[frontend.Backend].LoadBalancer)
+						if err != nil {
+							log.Errorf("Error loading load balancer method '%+v' for frontend %s: %v", config.Backends[frontend.Backend].LoadBalancer, frontendName, err)
 							log.Errorf("Skipping frontend %s...", frontendName)
 							continue frontend
 						}
-						hcOpts := parseHealthCheckOptions(rr, frontend.Backend, config.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
-						if hcOpts != nil {
-							log.Debugf("Setting up backend health check %s", *hcOpts)
-							hcOpts.Transport = s.defaultForwardingRoundTripper
-							backendsHealthCheck[entryPointName+frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts, frontend.Backend)
+
+						var sticky *roundrobin.StickySession
+						var cookieName string
+						if stickiness := config.Backends[frontend.Backend].LoadBalancer.Stickiness; stickiness != nil {
+							cookieName = cookie.GetName(stickiness.CookieName, frontend.Backend)
+							sticky = roundrobin.NewStickySession(cookieName)
 						}
-						lb = middlewares.NewEmptyBackendHandler(rr, lb)
-					}
 
-					if len(frontend.Errors) > 0 {
-						for errorPageName, errorPage := range frontend.Errors {
-							if frontend.Backend == errorPage.Backend {
-								log.Errorf("Error when creating error page %q for frontend %q: error pages backend %q is the same as backend for the frontend (infinite call risk).",
-									errorPageName, frontendName, errorPage.Backend)
-							} else if config.Backends[errorPage.Backend] == nil {
-								log.Errorf("Error when creating error page %q for frontend %q: the backend %q doesn't exist.",
-									errorPageName, frontendName, errorPage.Backend)
-							} else {
-								errorPagesHandler, err := errorpages.NewHandler(errorPage, entryPointName+providerName+errorPage.Backend)
-								if err != nil {
-									log.Errorf("Error creating error pages: %v", err)
+						var lb http.Handler
+						switch lbMethod {
+						case types.Drr:
+							log.Debugf("Creating load-balancer drr")
+							rebalancer, _ := roundrobin.NewRebalancer(rr)
+							if sticky != nil {
+								log.Debugf("Sticky session with cookie %v", cookieName)
+								rebalancer, _ = roundrobin.NewRebalancer(rr, roundrobin.RebalancerStickySession(sticky))
+							}
+							lb = rebalancer
+							if err := s.configureLBServers(rebalancer, config, frontend); err != nil {
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							hcOpts := parseHealthCheckOptions(rebalancer, frontend.Backend, config.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
+							hcOpts = withPassiveHealthCheck(hcOpts, rebalancer, passiveHealthCheck)
+							if hcOpts != nil {
+								log.Debugf("Setting up backend health check %s", *hcOpts)
+								hcOpts.Transport = s.defaultForwardingRoundTripper
+								backendsHealthCheck[entryPointName+frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts, frontend.Backend)
+							}
+							lb = middlewares.NewEmptyBackendHandler(rebalancer, lb)
+						case types.Wrr:
+							log.Debugf("Creating load-balancer wrr")
+							if sticky != nil {
+								log.Debugf("Sticky session with cookie %v", cookieName)
+								if s.accessLoggerMiddleware != nil {
+									rr, _ = roundrobin.New(saveFrontend, roundrobin.EnableStickySession(sticky))
 								} else {
-									if errorPageServer, ok := config.Backends[errorPage.Backend].Servers["error"]; ok {
-										errorPagesHandler.FallbackURL = errorPageServer.URL
-									}
-
-									errorPageHandlers = append(errorPageHandlers, errorPagesHandler)
-									n.Use(errorPagesHandler)
+									rr, _ = roundrobin.New(fwd, roundrobin.EnableStickySession(sticky))
 								}
 							}
+							lb = rr
+							if err := s.configureLBServers(rr, config, frontend); err != nil {
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							hcOpts := parseHealthCheckOptions(rr, frontend.Backend, config.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
+							hcOpts = withPassiveHealthCheck(hcOpts, rr, passiveHealthCheck)
+							if hcOpts != nil {
+								log.Debugf("Setting up backend health check %s", *hcOpts)
+								hcOpts.Transport = s.defaultForwardingRoundTripper
+								backendsHealthCheck[entryPointName+frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts, frontend.Backend)
+							}
+							lb = middlewares.NewEmptyBackendHandler(rr, lb)
+						case types.LeastConn, types.Ewma, types.ConsistentHash:
+							next := fwd
+							if s.accessLoggerMiddleware != nil {
+								next = saveFrontend
+							}
+							customLB, err := buildBalancer(lbMethod, next, config.Backends[frontend.Backend].LoadBalancer, sticky)
+							if err != nil {
+								log.Errorf("Error creating load-balancer for frontend %s: %v", frontendName, err)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							lb = customLB
+							if err := s.configureLBServers(customLB, config, frontend); err != nil {
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							hcOpts := parseHealthCheckOptions(customLB, frontend.Backend, config.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
+							hcOpts = withPassiveHealthCheck(hcOpts, customLB, passiveHealthCheck)
+							if hcOpts != nil {
+								log.Debugf("Setting up backend health check %s", *hcOpts)
+								hcOpts.Transport = s.defaultForwardingRoundTripper
+								backendsHealthCheck[entryPointName+frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts, frontend.Backend)
+							}
+							lb = middlewares.NewEmptyBackendHandler(customLB, lb)
 						}
-					}
 
-					if frontend.RateLimit != nil && len(frontend.RateLimit.RateSet) > 0 {
-						lb, err = s.buildRateLimiter(lb, frontend.RateLimit)
-						if err != nil {
-							log.Errorf("Error creating rate limiter: %v", err)
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
+						if frontend.RateLimit != nil && len(frontend.RateLimit.RateSet) > 0 {
+							lb, err = s.buildRateLimiter(lb, frontend.RateLimit)
+							if err != nil {
+								log.Errorf("Error creating rate limiter: %v", err)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							lb = s.wrapHTTPHandlerWithAccessLog(lb, fmt.Sprintf("rate limit for %s", frontendName))
 						}
-						lb = s.wrapHTTPHandlerWithAccessLog(lb, fmt.Sprintf("rate limit for %s", frontendName))
-					}
 
-					maxConns := config.Backends[frontend.Backend].MaxConn
-					if maxConns != nil && maxConns.Amount != 0 {
-						extractFunc, err := utils.NewExtractor(maxConns.ExtractorFunc)
-						if err != nil {
-							log.Errorf("Error creating connection limit: %v", err)
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
-						}
+						maxConns := config.Backends[frontend.Backend].MaxConn
+						if maxConns != nil && maxConns.Amount != 0 {
+							extractFunc, err := osio.NewExtractor(maxConns.ExtractorFunc)
+							if err != nil {
+								log.Errorf("Error creating connection limit: %v", err)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
 
-						log.Debugf("Creating load-balancer connection limit")
+							log.Debugf("Creating load-balancer connection limit")
 
-						lb, err = connlimit.New(lb, extractFunc, maxConns.Amount)
-						if err != nil {
-							log.Errorf("Error creating connection limit: %v", err)
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
+							lb, err = connlimit.New(lb, extractFunc, maxConns.Amount,
+								connlimit.ErrorHandler(middlewares.ConnLimitErrorHandler{RetryAfter: time.Second}))
+							if err != nil {
+								log.Errorf("Error creating connection limit: %v", err)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							lb = s.wrapHTTPHandlerWithAccessLog(lb, fmt.Sprintf("connection limit for %s", frontendName))
 						}
-						lb = s.wrapHTTPHandlerWithAccessLog(lb, fmt.Sprintf("connection limit for %s", frontendName))
-					}
 
-					if globalConfiguration.Retry != nil {
-						countServers := len(config.Backends[frontend.Backend].Servers)
-						lb = s.buildRetryMiddleware(lb, globalConfiguration, countServers, frontend.Backend)
-					}
+						if globalConfiguration.Retry != nil || config.Backends[frontend.Backend].Retry != nil {
+							countServers := len(config.Backends[frontend.Backend].Servers)
+							lb, err = s.buildRetryMiddleware(lb, globalConfiguration, config.Backends[frontend.Backend].Retry, countServers, frontend.Backend)
+							if err != nil {
+								log.Errorf("Error creating retry: %v", err)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+						}
+
+						n := negroni.New()
+						if config.Backends[frontend.Backend].Buffering != nil {
+							bufferedLb, err := s.buildBufferingMiddleware(lb, config.Backends[frontend.Backend].Buffering)
+
+							if err != nil {
+								log.Errorf("Error setting up buffering middleware: %s", err)
+							} else {
+								lb = bufferedLb
+							}
+						}
 
-					if s.metricsRegistry.IsEnabled() {
-						n.Use(middlewares.NewBackendMetricsMiddleware(s.metricsRegistry, frontend.Backend))
+						if config.Backends[frontend.Backend].CircuitBreaker != nil {
+							log.Debugf("Creating circuit breaker %s", config.Backends[frontend.Backend].CircuitBreaker.Expression)
+							expression := config.Backends[frontend.Backend].CircuitBreaker.Expression
+							circuitBreaker, err := middlewares.NewCircuitBreaker(lb, expression, middlewares.NewCircuitBreakerOptions(expression))
+							if err != nil {
+								log.Errorf("Error creating circuit breaker: %v", err)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							n.Use(s.tracingMiddleware.NewNegroniHandlerWrapper("Circuit breaker", circuitBreaker, false))
+						} else {
+							n.UseHandler(lb)
+						}
+						backends[entryPointName+providerName+frontend.Backend] = n
+					} else {
+						log.Debugf("Reusing backend %s", frontend.Backend)
 					}
+				}
 
-					ipWhitelistMiddleware, err := buildIPWhiteLister(frontend.WhiteList, frontend.WhitelistSourceRange)
-					if err != nil {
-						log.Errorf("Error creating IP Whitelister: %s", err)
-					} else if ipWhitelistMiddleware != nil {
-						n.Use(
-							s.tracingMiddleware.NewNegroniHandlerWrapper(
-								"IP whitelist",
-								s.wrapNegroniHandlerWithAccessLog(ipWhitelistMiddleware, fmt.Sprintf("ipwhitelister for %s", frontendName)),
-								false))
-						log.Debugf("Configured IP Whitelists: %s", frontend.WhitelistSourceRange)
+				// The middlewares of the frontend are kept out of the backend handlers, which are shared
+				// by all the frontends of a backend.
+				n := negroni.New()
+				if entryPoint.Redirect != nil && entryPointName != entryPoint.Redirect.EntryPoint {
+					if redirectHandlers[entryPointName] != nil {
+						n.Use(redirectHandlers[entryPointName])
+					} else if handler, err := s.buildRedirectHandler(entryPointName, entryPoint.Redirect); err != nil {
+						log.Errorf("Error loading entrypoint configuration for frontend %s: %v", frontendName, err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
+					} else {
+						handlerToUse := s.wrapNegroniHandlerWithAccessLog(handler, fmt.Sprintf("entrypoint redirect for %s", frontendName))
+						n.Use(handlerToUse)
+						redirectHandlers[entryPointName] = handlerToUse
 					}
+				}
 
-					if frontend.Redirect != nil && entryPointName != frontend.Redirect.EntryPoint {
-						rewrite, err := s.buildRedirectHandler(entryPointName, frontend.Redirect)
-						if err != nil {
-							log.Errorf("Error creating Frontend Redirect: %v", err)
+				if len(frontend.Errors) > 0 {
+					for errorPageName, errorPage := range frontend.Errors {
+						if usesBackend(frontend, errorPage.Backend) {
+							log.Errorf("Error when creating error page %q for frontend %q: error pages backend %q is the same as backend for the frontend (infinite call risk).",
+								errorPageName, frontendName, errorPage.Backend)
+						} else if config.Backends[errorPage.Backend] == nil {
+							log.Errorf("Error when creating error page %q for frontend %q: the backend %q doesn't exist.",
+								errorPageName, frontendName, errorPage.Backend)
 						} else {
-							n.Use(s.wrapNegroniHandlerWithAccessLog(rewrite, fmt.Sprintf("frontend redirect for %s", frontendName)))
-							log.Debugf("Frontend %s redirect created", frontendName)
+							errorPagesHandler, err := errorpages.NewHandler(errorPage, entryPointName+providerName+errorPage.Backend)
+							if err != nil {
+								log.Errorf("Error creating error pages: %v", err)
+							} else {
+								if errorPageServer, ok := config.Backends[errorPage.Backend].Servers["error"]; ok {
+									errorPagesHandler.FallbackURL = errorPageServer.URL
+								}
+
+								errorPageHandlers = append(errorPageHandlers, errorPagesHandler)
+								n.Use(errorPagesHandler)
+							}
 						}
 					}
+				}
 
-					if headerMiddleware != nil {
-						log.Debugf("Adding header middleware for frontend %s", frontendName)
-						n.Use(s.tracingMiddleware.NewNegroniHandlerWrapper("Header", headerMiddleware, false))
-					}
+				// the backends of a split are only known once the middlewares of the frontend ran
+				if s.metricsRegistry.IsEnabled() && split == nil {
+					n.Use(middlewares.NewBackendMetricsMiddleware(s.metricsRegistry, frontend.Backend))
+				}
 
-					if secureMiddleware != nil {
-						log.Debugf("Adding secure middleware for frontend %s", frontendName)
-						n.UseFunc(secureMiddleware.HandlerFuncWithNextForRequestOnly)
+				if frontend.Limits != nil {
+					var listener middlewares.SizeLimitListener
+					if s.accessLoggerMiddleware != nil {
+						listener = &accesslog.SaveRejection{}
 					}
+					sizeLimit, err := middlewares.NewSizeLimit(frontend.Limits, listener)
+					if err != nil {
+						log.Errorf("Error creating size limits for frontend %s: %v", frontendName, err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
+					}
+					n.Use(s.tracingMiddleware.NewNegroniHandlerWrapper("Size limit", sizeLimit, false))
+				}
 
-					if len(frontend.BasicAuth) > 0 {
-						users := types.Users{}
-						for _, user := range frontend.BasicAuth {
-							users = append(users, user)
-						}
+				ipWhitelistMiddleware, err := buildIPWhiteLister(frontend.WhiteList, frontend.WhitelistSourceRange)
+				if err != nil {
+					log.Errorf("Error creating IP Whitelister: %s", err)
+				} else if ipWhitelistMiddleware != nil {
+					n.Use(
+						s.tracingMiddleware.NewNegroniHandlerWrapper(
+							"IP whitelist",
+							s.wrapNegroniHandlerWithAccessLog(ipWhitelistMiddleware, fmt.Sprintf("ipwhitelister for %s", frontendName)),
+							false))
+					log.Debugf("Configured IP Whitelists: %s", frontend.WhitelistSourceRange)
+				}
 
-						auth := &types.Auth{}
-						auth.Basic = &types.Basic{
-							Users: users,
-						}
-						authMiddleware, err := mauth.NewAuthenticator(auth, s.tracingMiddleware)
-						if err != nil {
-							log.Errorf("Error creating Auth: %s", err)
-						} else {
-							n.Use(s.wrapNegroniHandlerWithAccessLog(authMiddleware, fmt.Sprintf("Auth for %s", frontendName)))
-						}
+				if frontend.Redirect != nil && entryPointName != frontend.Redirect.EntryPoint {
+					rewrite, err := s.buildRedirectHandler(entryPointName, frontend.Redirect)
+					if err != nil {
+						log.Errorf("Error creating Frontend Redirect: %v", err)
+					} else {
+						n.Use(s.wrapNegroniHandlerWithAccessLog(rewrite, fmt.Sprintf("frontend redirect for %s", frontendName)))
+						log.Debugf("Frontend %s redirect created", frontendName)
 					}
+				}
+
+				corsMiddleware, err := middlewares.NewCORSFromStruct(frontend.Headers)
+				if err != nil {
+					log.Errorf("Error creating CORS middleware for frontend %s: %v", frontendName, err)
+					log.Errorf("Skipping frontend %s...", frontendName)
+					continue frontend
+				}
+				if corsMiddleware != nil {
+					log.Debugf("Adding CORS middleware for frontend %s", frontendName)
+					n.Use(s.tracingMiddleware.NewNegroniHandlerWrapper("CORS", corsMiddleware, false))
+				}
 
-					if config.Backends[frontend.Backend].Buffering != nil {
-						bufferedLb, err := s.buildBufferingMiddleware(lb, config.Backends[frontend.Backend].Buffering)
+				headerMiddleware := middlewares.NewHeaderFromStruct(frontend.Headers)
+				if headerMiddleware != nil {
+					log.Debugf("Adding header middleware for frontend %s", frontendName)
+					n.Use(s.tracingMiddleware.NewNegroniHandlerWrapper("Header", headerMiddleware, false))
+				}
 
-						if err != nil {
-							log.Errorf("Error setting up buffering middleware: %s", err)
-						} else {
-							lb = bufferedLb
-						}
+				secureMiddleware := middlewares.NewSecure(frontend.Headers)
+				if secureMiddleware != nil {
+					log.Debugf("Adding secure middleware for frontend %s", frontendName)
+					n.UseFunc(secureMiddleware.HandlerFuncWithNextForRequestOnly)
+				}
+
+				if headerMiddleware != nil || secureMiddleware != nil {
+					n.Use(middlewares.NewResponseModifier(buildModifyResponse(secureMiddleware, headerMiddleware)))
+				}
+
+				if len(frontend.BasicAuth) > 0 {
+					users := types.Users{}
+					for _, user := range frontend.BasicAuth {
+						users = append(users, user)
 					}
 
-					if config.Backends[frontend.Backend].CircuitBreaker != nil {
-						log.Debugf("Creating circuit breaker %s", config.Backends[frontend.Backend].CircuitBreaker.Expression)
-						expression := config.Backends[frontend.Backend].CircuitBreaker.Expression
-						circuitBreaker, err := middlewares.NewCircuitBreaker(lb, expression, middlewares.NewCircuitBreakerOptions(expression))
-						if err != nil {
-							log.Errorf("Error creating circuit breaker: %v", err)
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
-						}
-						n.Use(s.tracingMiddleware.NewNegroniHandlerWrapper("Circuit breaker", circuitBreaker, false))
+					auth := &types.Auth{}
+					auth.Basic = &types.Basic{
+						Users: users,
+					}
+					authMiddleware, err := mauth.NewAuthenticator(auth, s.tracingMiddleware)
+					if err != nil {
+						log.Errorf("Error creating Auth: %s", err)
 					} else {
-						n.UseHandler(lb)
+						n.Use(s.wrapNegroniHandlerWithAccessLog(authMiddleware, fmt.Sprintf("Auth for %s", frontendName)))
 					}
-					backends[entryPointName+providerName+frontend.Backend] = n
-				} else {
-					log.Debugf("Reusing backend %s", frontend.Backend)
 				}
+
+				if frontend.Mirror != nil {
+					roundTripper, err := s.getRoundTripper(entryPointName, globalConfiguration, frontend.PassTLSCert, entryPoint.TLS)
+					if err != nil {
+						log.Errorf("Failed to create RoundTripper for frontend %s: %v", frontendName, err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
+					}
+					rewriter, err := NewHeaderRewriter(entryPoint.ForwardedHeaders.TrustedIPs, entryPoint.ForwardedHeaders.Insecure)
+					if err != nil {
+						log.Errorf("Error creating rewriter for frontend %s: %v", frontendName, err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
+					}
+					mirror, err := s.buildMirror(frontend.Mirror, config, entryPointName+providerName, rewriter, roundTripper, errorHandler, frontend.PassHostHeader)
+					if err != nil {
+						log.Errorf("Error creating mirror for frontend %s: %v", frontendName, err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
+					}
+					mirrors = append(mirrors, mirror)
+					n.Use(s.tracingMiddleware.NewNegroniHandlerWrapper("Mirror", mirror, false))
+				}
+
+				if frontend.Cache != nil {
+					responseCache, err := cache.New(frontendName, frontend.Cache, s.metricsRegistry)
+					if err != nil {
+						log.Errorf("Error creating cache for frontend %s: %v", frontendName, err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
+					}
+					n.Use(s.tracingMiddleware.NewNegroniHandlerWrapper("Cache", responseCache, false))
+				}
+
 				if frontend.Priority > 0 {
 					newServerRoute.Route.Priority(frontend.Priority)
 				}
-				s.wireFrontendBackend(newServerRoute, backends[entryPointName+providerName+frontend.Backend])
+				if split != nil {
+					split.PostLoad(func(backendName string) http.Handler {
+						handler := backends[entryPointName+providerName+backendName]
+						if s.metricsRegistry.IsEnabled() && handler != nil {
+							handler = negroni.New(middlewares.NewBackendMetricsMiddleware(s.metricsRegistry, backendName), negroni.Wrap(handler))
+						}
+						return osio.NewDebugRoute(handler, frontendName, backendName)
+					})
+					n.UseHandler(split)
+				} else {
+					n.UseHandler(osio.NewDebugRoute(backends[entryPointName+providerName+frontend.Backend], frontendName, frontend.Backend))
+				}
+				s.wireFrontendBackend(newServerRoute, n)
 
-				err := newServerRoute.Route.GetError()
+				err = newServerRoute.Route.GetError()
 				if err != nil {
 					log.Errorf("Error building route: %s", err)
 				}
@@ -1276,6 +1450,10 @@ func (s *Server) loadConfig(configurations types.Configurations, globalConfigura
 		}
 	}
 
+	for _, mirror := range mirrors {
+		mirror.PostLoad(backends[mirror.BackendName])
+	}
+
 	healthcheck.GetHealthCheck(s.metricsRegistry).SetBackendsConfiguration(s.routinesPool.Ctx(), backendsHealthCheck)
 
 	// Get new certificates list sorted per entrypoints
@@ -1293,6 +1471,43 @@ func (s *Server) loadConfig(configurations types.Configurations, globalConfigura
 	return serverEntryPoints, err
 }
 
+// backendFrontends returns the frontend with each of the backends of its split,
+// the frontend itself if it has a single backend.
+func backendFrontends(frontend *types.Frontend) []*types.Frontend {
+	if frontend.Split == nil {
+		return []*types.Frontend{frontend}
+	}
+	var frontends []*types.Frontend
+	for _, backend := range frontend.Split.Backends {
+		backendFrontend := *frontend
+		backendFrontend.Backend = backend.Backend
+		frontends = append(frontends, &backendFrontend)
+	}
+	return frontends
+}
+
+// usesBackend returns whether the backend is the backend of the frontend or one of the backends of its split.
+func usesBackend(frontend *types.Frontend, backend string) bool {
+	for _, backendFrontend := range backendFrontends(frontend) {
+		if backendFrontend.Backend == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// buildSplit creates the split of a frontend between its backends.
+func (s *Server) buildSplit(frontendName string, splitConfig *types.Split) (*middlewares.Split, error) {
+	var hashKey utils.SourceExtractor
+	if splitConfig.HashKey != "" {
+		var err error
+		if hashKey, err = osio.NewExtractor(splitConfig.HashKey); err != nil {
+			return nil, err
+		}
+	}
+	return middlewares.NewSplit(frontendName, splitConfig, hashKey, s.metricsRegistry)
+}
+
 func (s *Server) configureLBServers(lb healthcheck.LoadBalancer, config *types.Configuration, frontend *types.Frontend) error {
 	for name, srv := range config.Backends[frontend.Backend].Servers {
 		u, err := url.Parse(srv.URL)
@@ -1411,16 +1626,153 @@ func parseHealthCheckOptions(lb healthcheck.LoadBalancer, backend string, hc *ty
 		}
 	}
 
+	var timeout time.Duration
+	if hc.Timeout != "" {
+		timeoutOverride, err := time.ParseDuration(hc.Timeout)
+		switch {
+		case err != nil:
+			log.Errorf("Illegal healthcheck timeout for backend '%s': %s", backend, err)
+		case timeoutOverride <= 0:
+			log.Errorf("Healthcheck timeout smaller than zero for backend '%s'", backend)
+		default:
+			timeout = timeoutOverride
+		}
+	}
+
+	var status types.HTTPCodeRanges
+	if len(hc.Status) > 0 {
+		var err error
+		if status, err = types.NewHTTPCodeRanges(hc.Status); err != nil {
+			log.Errorf("Illegal healthcheck status for backend '%s': %s", backend, err)
+		}
+	}
+
 	return &healthcheck.Options{
-		Path:     hc.Path,
-		Port:     hc.Port,
-		Interval: interval,
-		LB:       lb,
+		Path:               hc.Path,
+		Port:               hc.Port,
+		Interval:           interval,
+		Timeout:            timeout,
+		Method:             strings.ToUpper(hc.Method),
+		Hostname:           hc.Hostname,
+		Headers:            hc.Headers,
+		Status:             status,
+		HealthyThreshold:   hc.HealthyThreshold,
+		UnhealthyThreshold: hc.UnhealthyThreshold,
+		TLSServerName:      hc.TLSServerName,
+		LB:                 lb,
+	}
+}
+
+// buildMirror creates the mirror of a frontend, falling back to a plain load-balancer on the
+// servers of the shadow backend when no frontend of the entry point uses it.
+func (s *Server) buildMirror(mirrorConfig *types.Mirror, config *types.Configuration, backendPrefix string,
+	rewriter forward.ReqRewriter, roundTripper http.RoundTripper, errorHandler *RecordingErrorHandler, passHostHeader bool) (*middlewares.Mirror, error) {
+	shadow := config.Backends[mirrorConfig.Backend]
+	if shadow == nil {
+		return nil, fmt.Errorf("undefined mirror backend '%s'", mirrorConfig.Backend)
+	}
+	mirror, err := middlewares.NewMirror(mirrorConfig, backendPrefix+mirrorConfig.Backend, s.metricsRegistry)
+	if err != nil {
+		return nil, err
+	}
+
+	fwd, err := forward.New(
+		forward.Stream(true),
+		forward.PassHostHeader(passHostHeader),
+		forward.RoundTripper(roundTripper),
+		forward.ErrorHandler(errorHandler),
+		forward.Rewriter(rewriter),
+		forward.BufferPool(s.bufferPool),
+	)
+	if err != nil {
+		return nil, err
+	}
+	rr, err := roundrobin.New(fwd)
+	if err != nil {
+		return nil, err
+	}
+	for name, srv := range shadow.Servers {
+		u, err := url.Parse(srv.URL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing server %s URL %s: %v", name, srv.URL, err)
+		}
+		if err := rr.UpsertServer(u, roundrobin.Weight(srv.Weight)); err != nil {
+			return nil, err
+		}
+	}
+	mirror.Fallback = middlewares.NewEmptyBackendHandler(rr, rr)
+	return mirror, nil
+}
+
+// buildBalancer creates the load-balancer of the methods which are not provided by oxy.
+func buildBalancer(method types.LoadBalancerMethod, next http.Handler, lbConfig *types.LoadBalancer, sticky *roundrobin.StickySession) (*balancer.Balancer, error) {
+	var options []balancer.Option
+	if sticky != nil && method != types.ConsistentHash {
+		options = append(options, balancer.EnableStickySession(sticky))
+	}
+
+	switch method {
+	case types.LeastConn:
+		log.Debugf("Creating load-balancer leastconn")
+		return balancer.NewLeastConn(next, options...), nil
+	case types.Ewma:
+		log.Debugf("Creating load-balancer ewma")
+		return balancer.NewEWMA(next, options...), nil
+	case types.ConsistentHash:
+		hashKey := "client.ip"
+		if lbConfig != nil && lbConfig.HashKey != "" {
+			hashKey = lbConfig.HashKey
+		}
+		log.Debugf("Creating load-balancer consistenthash on %s", hashKey)
+		key, err := osio.NewExtractor(hashKey)
+		if err != nil {
+			return nil, err
+		}
+		return balancer.NewConsistentHash(next, key, options...), nil
+	}
+	return nil, fmt.Errorf("unsupported load-balancing method %d", method)
+}
+
+// withPassiveHealthCheck adds the passive health check, if any, to the health check options of the load balancer.
+func withPassiveHealthCheck(hcOpts *healthcheck.Options, lb healthcheck.LoadBalancer, passive *healthcheck.PassiveHealthCheck) *healthcheck.Options {
+	if passive == nil {
+		return hcOpts
+	}
+	if hcOpts == nil {
+		hcOpts = &healthcheck.Options{LB: lb}
+	}
+	hcOpts.Passive = passive
+	return hcOpts
+}
+
+func buildPassiveHealthCheck(backend string, phc *types.PassiveHealthCheck) (*healthcheck.PassiveHealthCheck, error) {
+	options := healthcheck.PassiveOptions{
+		ConsecutiveFailures: phc.ConsecutiveFailures,
+		MaxEjectedPercent:   phc.MaxEjectedPercent,
+	}
+	var err error
+	if phc.BaseEjectionTime != "" {
+		if options.BaseEjectionTime, err = time.ParseDuration(phc.BaseEjectionTime); err != nil {
+			return nil, fmt.Errorf("illegal base ejection time: %v", err)
+		}
+	}
+	if phc.MaxEjectionTime != "" {
+		if options.MaxEjectionTime, err = time.ParseDuration(phc.MaxEjectionTime); err != nil {
+			return nil, fmt.Errorf("illegal max ejection time: %v", err)
+		}
 	}
+	return healthcheck.NewPassiveHealthCheck(options, backend)
 }
 
-func getRoute(serverRoute *types.ServerRoute, route *types.Route) error {
+func getRoute(serverRoute *types.ServerRoute, route *types.Route, forwardedHeaders *configuration.ForwardedHeaders) error {
 	rules := rules.Rules{Route: serverRoute}
+	if forwardedHeaders != nil && (forwardedHeaders.Insecure || len(forwardedHeaders.TrustedIPs) > 0) {
+		trustedProxies, err := whitelist.NewIP(forwardedHeaders.TrustedIPs, forwardedHeaders.Insecure, false)
+		if err != nil {
+			return err
+		}
+		rules.TrustedProxies = trustedProxies
+	}
 	newRoute, err := rules.Parse(route.Rule)
 	if err != nil {
 		return err
@@ -1518,7 +1870,7 @@ func stopMetricsClients() {
 }
 
 func (s *Server) buildRateLimiter(handler http.Handler, rlConfig *types.RateLimit) (http.Handler, error) {
-	extractFunc, err := utils.NewExtractor(rlConfig.ExtractorFunc)
+	extractFunc, err := osio.NewExtractor(rlConfig.ExtractorFunc)
 	if err != nil {
 		return nil, err
 	}
@@ -1529,12 +1881,12 @@ func (s *Server) buildRateLimiter(handler http.Handler, rlConfig *types.RateLimi
 			return nil, err
 		}
 	}
-	rateLimiter, err := ratelimit.New(handler, extractFunc, rateSet)
+	rateLimiter, err := ratelimit.New(handler, extractFunc, rateSet, ratelimit.ErrorHandler(middlewares.RateLimitErrorHandler{}))
 	return s.tracingMiddleware.NewHTTPHandlerWrapper("Rate limit", rateLimiter, false), err
 
 }
 
-func (s *Server) buildRetryMiddleware(handler http.Handler, globalConfig configuration.GlobalConfiguration, countServers int, backendName string) http.Handler {
+func (s *Server) buildRetryMiddleware(handler http.Handler, globalConfig configuration.GlobalConfiguration, retryConfig *types.Retry, countServers int, backendName string) (http.Handler, error) {
 	retryListeners := middlewares.RetryListeners{}
 	if s.metricsRegistry.IsEnabled() {
 		retryListeners = append(retryListeners, middlewares.NewMetricsRetryListener(s.metricsRegistry, backendName))
@@ -1544,14 +1896,23 @@ func (s *Server) buildRetryMiddleware(handler http.Handler, globalConfig configu
 	}
 
 	retryAttempts := countServers
-	if globalConfig.Retry.Attempts > 0 {
+	if globalConfig.Retry != nil && globalConfig.Retry.Attempts > 0 {
 		retryAttempts = globalConfig.Retry.Attempts
 	}
 
-	log.Debugf("Creating retries max attempts %d", retryAttempts)
+	if retryConfig == nil {
+		log.Debugf("Creating retries max attempts %d", retryAttempts)
+		return s.tracingMiddleware.NewHTTPHandlerWrapper("Retry", middlewares.NewRetry(retryAttempts, handler, retryListeners), false), nil
+	}
 
-	return s.tracingMiddleware.NewHTTPHandlerWrapper("Retry", middlewares.NewRetry(retryAttempts, handler, retryListeners), false)
+	log.Debugf("Creating retries for backend %s: %+v", backendName, *retryConfig)
+	retry, err := middlewares.NewRetryWithPolicy(retryAttempts, retryConfig, handler, retryListeners)
+	if err != nil {
+		return nil, err
+	}
+	return s.tracingMiddleware.NewHTTPHandlerWrapper("Retry", retry, false), nil
 }
+
 func (s *Server) wrapNegroniHandlerWithAccessLog(handler negroni.Handler, frontendName string) negroni.Handler {
 	if s.accessLoggerMiddleware != nil {
 		saveBackend := accesslog.NewSaveNegroniBackend(handler, "Træfik")
@@ -1575,7 +1936,7 @@ func (s *Server) buildBufferingMiddleware(handler http.Handler, config *types.Bu
 		config.MemRequestBodyBytes, config.MaxRequestBodyBytes, config.MemResponseBodyBytes,
 		config.MaxResponseBodyBytes, config.RetryExpression)
 
-	return buffer.New(
+	bufferedHandler, err := buffer.New(
 		handler,
 		buffer.MemRequestBodyBytes(config.MemRequestBodyBytes),
 		buffer.MaxRequestBodyBytes(config.MaxRequestBodyBytes),
@@ -1583,6 +1944,20 @@ func (s *Server) buildBufferingMiddleware(handler http.Handler, config *types.Bu
 		buffer.MaxResponseBodyBytes(config.MaxResponseBodyBytes),
 		buffer.CondSetter(len(config.RetryExpression) > 0, buffer.Retry(config.RetryExpression)),
 	)
+	if err != nil {
+		return nil, err
+	}
+	// upgraded connections are hijacked, their response can not be buffered
+	return middlewares.SkipUpgrade(bufferedHandler, handler), nil
+}
+
+func buildUpgradeOptions(passHostHeader bool, forwardingTimeouts *configuration.ForwardingTimeouts) middlewares.UpgradeOptions {
+	options := middlewares.UpgradeOptions{PassHostHeader: passHostHeader}
+	if forwardingTimeouts != nil {
+		options.IdleTimeout = time.Duration(forwardingTimeouts.UpgradeIdleTimeout)
+		options.Timeout = time.Duration(forwardingTimeouts.UpgradeTimeout)
+	}
+	return options
 }
 
 func buildModifyResponse(secure *secure.Secure, header *middlewares.HeaderStruct) func(res *http.Response) error {
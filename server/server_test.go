@@ -556,6 +556,46 @@ func TestServerParseHealthCheckOptions(t *testing.T) {
 				LB:       lb,
 			},
 		},
+		{
+			desc: "request options",
+			hc: &types.HealthCheck{
+				Path:               "/healthz",
+				Timeout:            "2s",
+				Method:             "head",
+				Hostname:           "cluster.example.com",
+				Headers:            map[string]string{"Authorization": "Bearer token"},
+				Status:             []string{"200-299", "401"},
+				HealthyThreshold:   2,
+				UnhealthyThreshold: 3,
+				TLSServerName:      "api.cluster.example.com",
+			},
+			wantOpts: &healthcheck.Options{
+				Path:               "/healthz",
+				Interval:           globalInterval,
+				Timeout:            2 * time.Second,
+				Method:             http.MethodHead,
+				Hostname:           "cluster.example.com",
+				Headers:            map[string]string{"Authorization": "Bearer token"},
+				Status:             types.HTTPCodeRanges{{200, 299}, {401, 401}},
+				HealthyThreshold:   2,
+				UnhealthyThreshold: 3,
+				TLSServerName:      "api.cluster.example.com",
+				LB:                 lb,
+			},
+		},
+		{
+			desc: "unparseable timeout and status",
+			hc: &types.HealthCheck{
+				Path:    "/path",
+				Timeout: "unparseable",
+				Status:  []string{"2xx"},
+			},
+			wantOpts: &healthcheck.Options{
+				Path:     "/path",
+				Interval: globalInterval,
+				LB:       lb,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -790,6 +830,23 @@ func TestConfigureBackends(t *testing.T) {
 	}
 }
 
+func TestBackendFrontends(t *testing.T) {
+	frontend := &types.Frontend{Backend: "backend1", PassHostHeader: true}
+	assert.Equal(t, []*types.Frontend{frontend}, backendFrontends(frontend))
+
+	frontend.Split = &types.Split{Backends: []types.WeightedBackend{
+		{Backend: "stable", Weight: 90},
+		{Backend: "canary", Weight: 10},
+	}}
+	frontends := backendFrontends(frontend)
+	require.Len(t, frontends, 2)
+	for i, backend := range []string{"stable", "canary"} {
+		assert.Equal(t, backend, frontends[i].Backend)
+		assert.True(t, frontends[i].PassHostHeader)
+	}
+	assert.Equal(t, "backend1", frontend.Backend)
+}
+
 func TestServerEntryPointWhitelistConfig(t *testing.T) {
 	tests := []struct {
 		desc           string
@@ -982,6 +1039,147 @@ func TestServerResponseEmptyBackend(t *testing.T) {
 	}
 }
 
+func TestServerFrontendsSharingBackend(t *testing.T) {
+	var calls int
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		rw.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(rw, "%s %d", req.Header.Get("X-Frontend"), calls)
+	}))
+	defer testServer.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{ForwardedHeaders: &configuration.ForwardedHeaders{Insecure: true}},
+		},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": &types.Configuration{
+			Frontends: map[string]*types.Frontend{
+				"cached": {
+					EntryPoints: []string{"http"},
+					Backend:     "backend",
+					Routes:      map[string]types.Route{"route": {Rule: "Path:/cached"}},
+					Headers: &types.Headers{
+						CustomRequestHeaders:  map[string]string{"X-Frontend": "cached"},
+						CustomResponseHeaders: map[string]string{"X-Served-By": "cached"},
+						FrameDeny:             true,
+					},
+					Cache: &types.Cache{},
+				},
+				"uncached": {
+					EntryPoints: []string{"http"},
+					Backend:     "backend",
+					Routes:      map[string]types.Route{"route": {Rule: "Path:/uncached"}},
+					Headers: &types.Headers{
+						CustomRequestHeaders:  map[string]string{"X-Frontend": "uncached"},
+						CustomResponseHeaders: map[string]string{"X-Served-By": "uncached"},
+					},
+				},
+			},
+			Backends: map[string]*types.Backend{
+				"backend": {
+					Servers:      map[string]types.Server{"server": {URL: testServer.URL}},
+					LoadBalancer: &types.LoadBalancer{Method: "wrr"},
+				},
+			},
+		},
+	}
+
+	srv := NewServer(globalConfig, nil)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, testServer.URL+path, nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		return recorder
+	}
+
+	assert.Equal(t, "cached 1", get("/cached").Body.String())
+	res := get("/cached")
+	assert.Equal(t, "cached 1", res.Body.String())
+	assert.Equal(t, "cached", res.Header().Get("X-Served-By"))
+	assert.Equal(t, "DENY", res.Header().Get("X-Frame-Options"))
+
+	assert.Equal(t, "uncached 2", get("/uncached").Body.String())
+	res = get("/uncached")
+	assert.Equal(t, "uncached 3", res.Body.String())
+	assert.Equal(t, "uncached", res.Header().Get("X-Served-By"))
+	assert.Empty(t, res.Header().Get("X-Frame-Options"))
+}
+
+func TestServerSkipsFrontendWithInvalidMiddleware(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		frontend func(frontend *types.Frontend)
+	}{
+		{
+			desc: "invalid limits",
+			frontend: func(frontend *types.Frontend) {
+				frontend.Limits = &types.Limits{MaxRequestBodyBytes: -1}
+			},
+		},
+		{
+			desc: "invalid CORS origin",
+			frontend: func(frontend *types.Frontend) {
+				frontend.Headers = &types.Headers{AccessControlAllowOrigins: []string{"https://*.*.openshift.io"}}
+			},
+		},
+		{
+			desc: "undefined mirror backend",
+			frontend: func(frontend *types.Frontend) {
+				frontend.Mirror = &types.Mirror{Backend: "undefined"}
+			},
+		},
+		{
+			desc: "invalid cache",
+			frontend: func(frontend *types.Frontend) {
+				frontend.Cache = &types.Cache{DefaultTTL: "invalid"}
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			globalConfig := configuration.GlobalConfiguration{
+				EntryPoints: configuration.EntryPoints{
+					"http": &configuration.EntryPoint{ForwardedHeaders: &configuration.ForwardedHeaders{Insecure: true}},
+				},
+			}
+			frontend := &types.Frontend{
+				EntryPoints: []string{"http"},
+				Backend:     "backend",
+				Routes:      map[string]types.Route{"route": {Rule: "Path:/path"}},
+			}
+			test.frontend(frontend)
+			dynamicConfigs := types.Configurations{
+				"config": &types.Configuration{
+					Frontends: map[string]*types.Frontend{"frontend": frontend},
+					Backends: map[string]*types.Backend{
+						"backend": {
+							Servers:      map[string]types.Server{"server": {URL: "http://localhost"}},
+							LoadBalancer: &types.LoadBalancer{Method: "wrr"},
+						},
+					},
+				},
+			}
+
+			srv := NewServer(globalConfig, nil)
+			entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/path", nil))
+			assert.Equal(t, http.StatusNotFound, recorder.Code)
+		})
+	}
+}
+
 func TestBuildRedirectHandler(t *testing.T) {
 	srv := Server{
 		globalConfiguration: configuration.GlobalConfiguration{
@@ -21,12 +21,14 @@ import (
 
 // Backend holds backend configuration.
 type Backend struct {
-	Servers        map[string]Server `json:"servers,omitempty"`
-	CircuitBreaker *CircuitBreaker   `json:"circuitBreaker,omitempty"`
-	LoadBalancer   *LoadBalancer     `json:"loadBalancer,omitempty"`
-	MaxConn        *MaxConn          `json:"maxConn,omitempty"`
-	HealthCheck    *HealthCheck      `json:"healthCheck,omitempty"`
-	Buffering      *Buffering        `json:"buffering,omitempty"`
+	Servers            map[string]Server   `json:"servers,omitempty"`
+	CircuitBreaker     *CircuitBreaker     `json:"circuitBreaker,omitempty"`
+	LoadBalancer       *LoadBalancer       `json:"loadBalancer,omitempty"`
+	MaxConn            *MaxConn            `json:"maxConn,omitempty"`
+	HealthCheck        *HealthCheck        `json:"healthCheck,omitempty"`
+	PassiveHealthCheck *PassiveHealthCheck `json:"passiveHealthCheck,omitempty"`
+	Buffering          *Buffering          `json:"buffering,omitempty"`
+	Retry              *Retry              `json:"retry,omitempty"`
 }
 
 // MaxConn holds maximum connection configuration
@@ -40,6 +42,9 @@ type LoadBalancer struct {
 	Method     string      `json:"method,omitempty"`
 	Sticky     bool        `json:"sticky,omitempty"` // Deprecated: use Stickiness instead
 	Stickiness *Stickiness `json:"stickiness,omitempty"`
+	// HashKey is the request variable hashed by the ConsistentHash method (e.g. request.header.X-User,
+	// request.cookie.session or osio.user), client.ip by default
+	HashKey string `json:"hashKey,omitempty"`
 }
 
 // Stickiness holds sticky session configuration.
@@ -61,6 +66,20 @@ type Buffering struct {
 	RetryExpression      string `json:"retryExpression,omitempty"`
 }
 
+// Retry holds the retry policy of a backend, which also retries the requests
+// on network errors as the global retry does.
+type Retry struct {
+	Attempts int `json:"attempts,omitempty"`
+	// Status are the response status ranges retried, e.g. "502-504"
+	Status []string `json:"status,omitempty"`
+	// Methods are the idempotent methods retried on a status, GET, HEAD, OPTIONS, TRACE, PUT and DELETE by default
+	Methods         []string       `json:"methods,omitempty"`
+	InitialInterval flaeg.Duration `json:"initialInterval,omitempty"`
+	MaxInterval     flaeg.Duration `json:"maxInterval,omitempty"`
+	// Budget is the maximum percentage of retried requests, 0 for no limit
+	Budget int `json:"budget,omitempty"`
+}
+
 // WhiteList contains white list configuration.
 type WhiteList struct {
 	SourceRange      []string `json:"sourceRange,omitempty"`
@@ -69,9 +88,29 @@ type WhiteList struct {
 
 // HealthCheck holds HealthCheck configuration
 type HealthCheck struct {
-	Path     string `json:"path,omitempty"`
-	Port     int    `json:"port,omitempty"`
-	Interval string `json:"interval,omitempty"`
+	Path     string            `json:"path,omitempty"`
+	Port     int               `json:"port,omitempty"`
+	Interval string            `json:"interval,omitempty"`
+	Timeout  string            `json:"timeout,omitempty"`
+	Method   string            `json:"method,omitempty"`
+	Hostname string            `json:"hostname,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	// Status are the status codes or ranges (e.g. "200-299") of a healthy server, 200 only by default
+	Status []string `json:"status,omitempty"`
+	// HealthyThreshold and UnhealthyThreshold are the consecutive checks changing the status of a server
+	HealthyThreshold   int    `json:"healthyThreshold,omitempty"`
+	UnhealthyThreshold int    `json:"unhealthyThreshold,omitempty"`
+	TLSServerName      string `json:"tlsServerName,omitempty"`
+}
+
+// PassiveHealthCheck holds the passive health check configuration: the servers failing consecutive
+// requests are ejected from the load balancer, until a successful probe once the ejection elapsed.
+type PassiveHealthCheck struct {
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+	// BaseEjectionTime is doubled at each consecutive ejection of a server, up to MaxEjectionTime
+	BaseEjectionTime  string `json:"baseEjectionTime,omitempty"`
+	MaxEjectionTime   string `json:"maxEjectionTime,omitempty"`
+	MaxEjectedPercent int    `json:"maxEjectedPercent,omitempty"`
 }
 
 // Server holds server configuration.
@@ -138,6 +177,13 @@ type Headers struct {
 	PublicKey               string            `json:"publicKey,omitempty"`
 	ReferrerPolicy          string            `json:"referrerPolicy,omitempty"`
 	IsDevelopment           bool              `json:"isDevelopment,omitempty"`
+
+	AccessControlAllowOrigins     []string `json:"accessControlAllowOrigins,omitempty"`
+	AccessControlAllowMethods     []string `json:"accessControlAllowMethods,omitempty"`
+	AccessControlAllowHeaders     []string `json:"accessControlAllowHeaders,omitempty"`
+	AccessControlExposeHeaders    []string `json:"accessControlExposeHeaders,omitempty"`
+	AccessControlAllowCredentials bool     `json:"accessControlAllowCredentials,omitempty"`
+	AccessControlMaxAge           int64    `json:"accessControlMaxAge,omitempty"`
 }
 
 // HasCustomHeadersDefined checks to see if any of the custom header elements have been set
@@ -169,6 +215,22 @@ func (h *Headers) HasSecureHeadersDefined() bool {
 		h.IsDevelopment)
 }
 
+// HasCORSHeadersDefined checks to see if cross-origin requests are allowed from any origin
+func (h *Headers) HasCORSHeadersDefined() bool {
+	return h != nil && len(h.AccessControlAllowOrigins) != 0
+}
+
+// CORS holds the CORS configuration of an entry point, the same as the accessControl* settings
+// of the headers of a frontend.
+type CORS struct {
+	AllowOrigins     []string `json:"allowOrigins,omitempty"`
+	AllowMethods     []string `json:"allowMethods,omitempty"`
+	AllowHeaders     []string `json:"allowHeaders,omitempty"`
+	ExposeHeaders    []string `json:"exposeHeaders,omitempty"`
+	AllowCredentials bool     `json:"allowCredentials,omitempty" export:"true"`
+	MaxAge           int64    `json:"maxAge,omitempty" export:"true"`
+}
+
 // Frontend holds frontend configuration.
 type Frontend struct {
 	EntryPoints          []string              `json:"entryPoints,omitempty"`
@@ -184,6 +246,65 @@ type Frontend struct {
 	Errors               map[string]*ErrorPage `json:"errors,omitempty"`
 	RateLimit            *RateLimit            `json:"ratelimit,omitempty"`
 	Redirect             *Redirect             `json:"redirect,omitempty"`
+	Mirror               *Mirror               `json:"mirror,omitempty"`
+	Split                *Split                `json:"split,omitempty"`
+	Cache                *Cache                `json:"cache,omitempty"`
+	Limits               *Limits               `json:"limits,omitempty"`
+}
+
+// Limits configures the largest requests and responses of a frontend, unlimited when unset.
+type Limits struct {
+	// MaxRequestBodyBytes is the size of the largest request body, rejected with 413
+	MaxRequestBodyBytes int64 `json:"maxRequestBodyBytes,omitempty"`
+	// MaxResponseBodyBytes is the size of the largest response body, replaced with 502 or cut off
+	MaxResponseBodyBytes int64 `json:"maxResponseBodyBytes,omitempty"`
+	// MaxHeaderBytes is the size of the largest request headers, rejected with 431
+	MaxHeaderBytes int `json:"maxHeaderBytes,omitempty"`
+	// MaxURLLength is the length of the longest request URL, rejected with 414
+	MaxURLLength int `json:"maxURLLength,omitempty"`
+}
+
+// Cache configures the caching of the responses of a frontend, following their Cache-Control,
+// Expires, Vary, ETag and Last-Modified headers.
+type Cache struct {
+	// MaxSize is the size in bytes of the cached responses, 16MB by default
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// MaxEntrySize is the size in bytes of the largest response cached, 1MB by default
+	MaxEntrySize int64 `json:"maxEntrySize,omitempty"`
+	// Key lists the parts of the request in the cache key with its method, path and query by default:
+	// path, query, request.host, request.header.X, request.cookie.X, osio.user, osio.cluster...
+	Key []string `json:"key,omitempty"`
+	// DefaultTTL is the freshness of the responses without explicit one, which are not cached by default
+	DefaultTTL string `json:"defaultTTL,omitempty"`
+}
+
+// Split configures the share of the requests of a frontend between several backends by weight,
+// in place of its backend.
+type Split struct {
+	Backends []WeightedBackend `json:"backends,omitempty"`
+	// HashKey, if set, assigns the requests with the same key (e.g. request.header.X-User,
+	// request.cookie.session) to the same backend
+	HashKey string `json:"hashKey,omitempty"`
+	// Cookie, if set, pins the clients to the backend of their first request
+	Cookie string `json:"cookie,omitempty"`
+}
+
+// WeightedBackend is a backend of a split frontend
+type WeightedBackend struct {
+	Backend string `json:"backend,omitempty"`
+	Weight  int    `json:"weight"`
+}
+
+// Mirror configures the copy of a share of the requests of a frontend to a shadow backend,
+// whose responses are discarded.
+type Mirror struct {
+	Backend string `json:"backend,omitempty"`
+	// Percent of the requests mirrored, 100 by default
+	Percent int `json:"percent,omitempty"`
+	// Methods of the requests mirrored, GET and HEAD by default
+	Methods []string `json:"methods,omitempty"`
+	// MaxBodySize is the size of the largest request body mirrored, 1MB by default
+	MaxBodySize int64 `json:"maxBodySize,omitempty"`
 }
 
 // Redirect configures a redirection of an entry point to another, or to an URL
@@ -202,11 +323,20 @@ const (
 	Wrr LoadBalancerMethod = iota
 	// Drr = Dynamic Round Robin
 	Drr
+	// LeastConn = fewest requests in flight
+	LeastConn
+	// Ewma = lowest peak EWMA latency
+	Ewma
+	// ConsistentHash = hash of a request variable
+	ConsistentHash
 )
 
 var loadBalancerMethodNames = []string{
 	"Wrr",
 	"Drr",
+	"LeastConn",
+	"Ewma",
+	"ConsistentHash",
 }
 
 // NewLoadBalancerMethod create a new LoadBalancerMethod from a given LoadBalancer.